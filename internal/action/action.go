@@ -0,0 +1,262 @@
+// Package action runs configured go-snapraid commands on request, e.g. from
+// the dashboard, and streams their output while they run.
+package action
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"slices"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/gi8lino/go-snapraid-web/internal/auth"
+	"github.com/gi8lino/go-snapraid-web/internal/runindex"
+)
+
+// stopTimeout is the time a stopped command gets to exit before it is killed.
+const stopTimeout = 30 * time.Second
+
+var (
+	// ErrNotFound is returned for actions that are not configured.
+	ErrNotFound = errors.New("action not found")
+	// ErrBusy is returned if another command writes into the source of an
+	// action, e.g. another action or a scheduled job.
+	ErrBusy = errors.New("source is busy")
+	// ErrStopped is returned once the Runner is shut down or before it runs.
+	ErrStopped = errors.New("actions are not accepted")
+)
+
+// Status is the outcome of an execution.
+type Status string
+
+const (
+	StatusRunning Status = "running" // the command is still running
+	StatusSuccess Status = "success" // the command exited with status 0
+	StatusFailed  Status = "failed"  // the command failed, timed out or was stopped
+)
+
+// Execution is a run of the command of an action.
+type Execution struct {
+	Start  time.Time
+	End    time.Time // zero while running
+	Status Status
+	RunID  string // run file produced by the command, empty if none appeared
+	Error  string // reason of failed executions
+}
+
+// ActionState is the state of an action.
+type ActionState struct {
+	ActionConfig
+	Running bool
+	Last    *Execution // latest execution, nil if the action never ran
+}
+
+// action is a configured action with its state.
+type action struct {
+	ActionConfig
+	index  *runindex.Index // index of the source receiving the run files, claimed while the command runs
+	logger *slog.Logger
+
+	mu     sync.Mutex
+	last   *Execution
+	output *Output // output of the latest execution
+}
+
+// Runner runs actions on request. It is safe for concurrent use.
+type Runner struct {
+	actions []*action
+
+	mu  sync.Mutex
+	ctx context.Context // context of Run, nil before and after it
+	wg  sync.WaitGroup  // running commands
+
+	Now func() time.Time // current time, defaults to time.Now
+}
+
+// New returns a Runner for the actions of cfg, which must be valid. Each
+// action writes its run files into one of the local sources. An action only
+// starts if no other command, e.g. a scheduled job, writes into its source,
+// so every run file is attributed to the command that produced it.
+func New(cfg Config, sources runindex.Sources, logger *slog.Logger) (*Runner, error) {
+	r := &Runner{Now: time.Now}
+	for _, ac := range cfg.Actions {
+		src, ok := sources.Get(ac.Source)
+		if !ok {
+			return nil, fmt.Errorf("action %s: source %q not found", ac.Name, ac.Source)
+		}
+		if src.Health != nil {
+			return nil, fmt.Errorf("action %s: source %q is mirrored from another instance", ac.Name, src.Name)
+		}
+		ac.Source = src.Name
+		if ac.Role == "" {
+			ac.Role = auth.RoleOperator
+		}
+		r.actions = append(r.actions, &action{
+			ActionConfig: ac,
+			index:        src.Index,
+			logger:       logger.With("action", ac.Name),
+		})
+	}
+	return r, nil
+}
+
+// Run accepts actions until ctx is cancelled. Running commands are stopped
+// on cancellation; Run returns once they have exited.
+func (r *Runner) Run(ctx context.Context) {
+	r.mu.Lock()
+	r.ctx = ctx
+	r.mu.Unlock()
+
+	<-ctx.Done()
+
+	r.mu.Lock()
+	r.ctx = nil
+	r.mu.Unlock()
+	r.wg.Wait()
+}
+
+// Start starts the command of the named action and returns the new state of
+// the action. It returns ErrNotFound for unknown actions and ErrBusy if
+// another command writes into the source of the action.
+func (r *Runner) Start(name string) (ActionState, error) {
+	a, ok := r.action(name)
+	if !ok {
+		return ActionState{}, ErrNotFound
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.ctx == nil {
+		return ActionState{}, ErrStopped
+	}
+	claim, ok := a.index.TryClaim()
+	if !ok {
+		return ActionState{}, ErrBusy
+	}
+
+	output := newOutput()
+	a.mu.Lock()
+	a.last = &Execution{Start: r.Now(), Status: StatusRunning}
+	a.output = output
+	a.mu.Unlock()
+	a.logger.Info("starting action", "command", a.Command)
+
+	ctx := r.ctx
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		defer claim.Release()
+		r.execute(ctx, a, claim, output)
+	}()
+	return r.state(a), nil
+}
+
+// execute runs the command of the action, collecting its output, and
+// records the run file it produced.
+func (r *Runner) execute(ctx context.Context, a *action, claim *runindex.Claim, output *Output) {
+	runCtx := ctx
+	if a.Timeout > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(ctx, a.Timeout)
+		defer cancel()
+	}
+
+	stdout := &lineWriter{out: output, stream: Stdout}
+	stderr := &lineWriter{out: output, stream: Stderr}
+	cmd := exec.CommandContext(runCtx, a.Command[0], a.Command[1:]...)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	cmd.Cancel = func() error { return cmd.Process.Signal(syscall.SIGTERM) } // let go-snapraid stop SnapRAID cleanly
+	cmd.WaitDelay = stopTimeout
+	err := cmd.Run()
+	stdout.flush()
+	stderr.flush()
+
+	a.mu.Lock()
+	e := *a.last
+	a.mu.Unlock()
+	e.End = r.Now()
+	e.Status = StatusSuccess
+	switch {
+	case err == nil:
+	case ctx.Err() != nil:
+		e.Status, e.Error = StatusFailed, "stopped by shutdown"
+	case errors.Is(runCtx.Err(), context.DeadlineExceeded):
+		e.Status, e.Error = StatusFailed, fmt.Sprintf("timed out after %s", a.Timeout)
+	default:
+		e.Status, e.Error = StatusFailed, err.Error()
+	}
+
+	if e.RunID, err = claim.Refresh(); err != nil {
+		a.logger.Error("failed to refresh run files", "error", err)
+	}
+
+	a.mu.Lock()
+	a.last = &e
+	a.mu.Unlock()
+	output.close()
+
+	if e.Status == StatusFailed {
+		a.logger.Error("action failed", "run", e.RunID, "error", e.Error)
+		return
+	}
+	a.logger.Info("action finished", "run", e.RunID, "duration", e.End.Sub(e.Start))
+}
+
+// Actions returns the state of all actions in configured order.
+func (r *Runner) Actions() []ActionState {
+	states := make([]ActionState, 0, len(r.actions))
+	for _, a := range r.actions {
+		states = append(states, r.state(a))
+	}
+	return states
+}
+
+// Action returns the state of the named action.
+func (r *Runner) Action(name string) (ActionState, bool) {
+	a, ok := r.action(name)
+	if !ok {
+		return ActionState{}, false
+	}
+	return r.state(a), true
+}
+
+// Output returns the output of the latest execution of the named action. It
+// reports false for unknown actions and actions that never ran.
+func (r *Runner) Output(name string) (*Output, bool) {
+	a, ok := r.action(name)
+	if !ok {
+		return nil, false
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.output, a.output != nil
+}
+
+// action returns the named action.
+func (r *Runner) action(name string) (*action, bool) {
+	i := slices.IndexFunc(r.actions, func(a *action) bool { return a.Name == name })
+	if i < 0 {
+		return nil, false
+	}
+	return r.actions[i], true
+}
+
+// state returns a snapshot of the state of a.
+func (r *Runner) state(a *action) ActionState {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	s := ActionState{ActionConfig: a.ActionConfig}
+	if a.last != nil {
+		last := *a.last
+		s.Last = &last
+		s.Running = last.Status == StatusRunning
+	}
+	return s
+}
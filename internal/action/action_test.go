@@ -0,0 +1,275 @@
+package action
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gi8lino/go-snapraid-web/internal/auth"
+	"github.com/gi8lino/go-snapraid-web/internal/runindex"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newSources returns a single source indexing dir.
+func newSources(t *testing.T, dir string, logger *slog.Logger) runindex.Sources {
+	t.Helper()
+	index := runindex.New(dir, nil, logger)
+	require.NoError(t, index.Refresh())
+	return runindex.Sources{{Name: "media", Index: index}}
+}
+
+// fakeCommand returns the command of the fake go-snapraid script, see
+// testdata/go-snapraid.sh.
+func fakeCommand(t *testing.T, args ...string) []string {
+	t.Helper()
+	script, err := filepath.Abs(filepath.Join("testdata", "go-snapraid.sh"))
+	require.NoError(t, err)
+	return append([]string{script}, args...)
+}
+
+// runRunner runs r until the test ends and waits until it accepts actions.
+func runRunner(t *testing.T, r *Runner) context.CancelFunc {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		r.Run(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	require.Eventually(t, func() bool {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		return r.ctx != nil
+	}, time.Second, time.Millisecond)
+	return cancel
+}
+
+// readAll reads the output until the command exited.
+func readAll(t *testing.T, out *Output) []Line {
+	t.Helper()
+	var lines []Line
+	next := 0
+	for {
+		more, n, changed, done := out.Read(next)
+		lines, next = append(lines, more...), n
+		if done {
+			return lines
+		}
+		select {
+		case <-changed:
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "command did not exit")
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	sync := ActionConfig{Name: "sync", Command: []string{"go-snapraid"}}
+
+	t.Run("Defaults", func(t *testing.T) {
+		t.Parallel()
+
+		r, err := New(Config{Actions: []ActionConfig{sync}}, newSources(t, t.TempDir(), logger), logger)
+		require.NoError(t, err)
+		state, ok := r.Action("sync")
+		require.True(t, ok)
+		assert.Equal(t, "media", state.Source)
+		assert.Equal(t, auth.RoleOperator, state.Role)
+		assert.False(t, state.Running)
+		assert.Nil(t, state.Last)
+		_, ok = r.Output("sync")
+		assert.False(t, ok)
+	})
+
+	t.Run("Unknown source", func(t *testing.T) {
+		t.Parallel()
+
+		a := sync
+		a.Source = "backup"
+		_, err := New(Config{Actions: []ActionConfig{a}}, newSources(t, t.TempDir(), logger), logger)
+		assert.EqualError(t, err, `action sync: source "backup" not found`)
+	})
+
+	t.Run("Remote source", func(t *testing.T) {
+		t.Parallel()
+
+		sources := newSources(t, t.TempDir(), logger)
+		sources[0].Health = func() runindex.Health { return runindex.Health{} }
+		_, err := New(Config{Actions: []ActionConfig{sync}}, sources, logger)
+		assert.EqualError(t, err, `action sync: source "media" is mirrored from another instance`)
+	})
+}
+
+func TestRunner_Start(t *testing.T) {
+	t.Parallel()
+
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+
+	t.Run("Streams the output and records the run file", func(t *testing.T) {
+		t.Parallel()
+
+		dir := t.TempDir()
+		sources := newSources(t, dir, logger)
+		r, err := New(Config{Actions: []ActionConfig{
+			{Name: "diff", Command: fakeCommand(t, dir, "2025-06-01T03:00:00Z")},
+		}}, sources, logger)
+		require.NoError(t, err)
+		runRunner(t, r)
+
+		state, err := r.Start("diff")
+		require.NoError(t, err)
+		assert.True(t, state.Running)
+		assert.Equal(t, StatusRunning, state.Last.Status)
+
+		out, ok := r.Output("diff")
+		require.True(t, ok)
+		assert.ElementsMatch(t, []Line{
+			{Stream: Stdout, Text: "Running diff..."},
+			{Stream: Stderr, Text: "WARNING! Content file is not up to date"},
+			{Stream: Stdout, Text: "Everything OK"},
+		}, readAll(t, out))
+
+		state, _ = r.Action("diff")
+		assert.False(t, state.Running)
+		assert.Equal(t, StatusSuccess, state.Last.Status)
+		assert.Equal(t, "2025-06-01T03:00:00Z", state.Last.RunID)
+		assert.Empty(t, state.Last.Error)
+		assert.False(t, state.Last.End.Before(state.Last.Start))
+		_, ok = sources[0].Index.Get("2025-06-01T03:00:00Z")
+		assert.True(t, ok, "run file is indexed")
+		assert.False(t, sources[0].Index.Claimed())
+	})
+
+	t.Run("Runs one command per source", func(t *testing.T) {
+		t.Parallel()
+
+		dir := t.TempDir()
+		wait := filepath.Join(t.TempDir(), "continue")
+		sources := newSources(t, dir, logger)
+		r, err := New(Config{Actions: []ActionConfig{
+			{Name: "sync", Command: fakeCommand(t, dir, "2025-06-01T03:00:00Z", "0", wait)},
+			{Name: "scrub", Command: fakeCommand(t, dir, "2025-06-01T04:00:00Z")},
+		}}, sources, logger)
+		require.NoError(t, err)
+		runRunner(t, r)
+
+		_, err = r.Start("sync")
+		require.NoError(t, err)
+		_, err = r.Start("sync")
+		assert.ErrorIs(t, err, ErrBusy)
+		_, err = r.Start("scrub")
+		assert.ErrorIs(t, err, ErrBusy)
+		state, _ := r.Action("scrub")
+		assert.Nil(t, state.Last)
+
+		require.NoError(t, os.WriteFile(wait, nil, 0o600))
+		out, _ := r.Output("sync")
+		readAll(t, out)
+
+		// e.g. a scheduled job
+		claim, ok := sources[0].Index.TryClaim()
+		require.True(t, ok)
+		_, err = r.Start("scrub")
+		assert.ErrorIs(t, err, ErrBusy)
+		claim.Release()
+
+		_, err = r.Start("scrub")
+		require.NoError(t, err)
+		out, _ = r.Output("scrub")
+		readAll(t, out)
+		state, _ = r.Action("scrub")
+		assert.Equal(t, "2025-06-01T04:00:00Z", state.Last.RunID)
+	})
+
+	t.Run("Records failures", func(t *testing.T) {
+		t.Parallel()
+
+		dir := t.TempDir()
+		r, err := New(Config{Actions: []ActionConfig{
+			{Name: "sync", Command: fakeCommand(t, dir, "", "3")},
+		}}, newSources(t, dir, logger), logger)
+		require.NoError(t, err)
+		runRunner(t, r)
+
+		_, err = r.Start("sync")
+		require.NoError(t, err)
+		out, _ := r.Output("sync")
+		readAll(t, out)
+
+		state, _ := r.Action("sync")
+		assert.Equal(t, StatusFailed, state.Last.Status)
+		assert.Equal(t, "exit status 3", state.Last.Error)
+		assert.Empty(t, state.Last.RunID)
+	})
+
+	t.Run("Stops commands after the timeout", func(t *testing.T) {
+		t.Parallel()
+
+		r, err := New(Config{Actions: []ActionConfig{
+			{Name: "sync", Command: []string{"sleep", "10"}, Timeout: 50 * time.Millisecond},
+		}}, newSources(t, t.TempDir(), logger), logger)
+		require.NoError(t, err)
+		runRunner(t, r)
+
+		_, err = r.Start("sync")
+		require.NoError(t, err)
+		out, _ := r.Output("sync")
+		readAll(t, out)
+
+		state, _ := r.Action("sync")
+		assert.Equal(t, StatusFailed, state.Last.Status)
+		assert.Equal(t, "timed out after 50ms", state.Last.Error)
+	})
+
+	t.Run("Stops commands on shutdown", func(t *testing.T) {
+		t.Parallel()
+
+		r, err := New(Config{Actions: []ActionConfig{
+			{Name: "sync", Command: []string{"sleep", "10"}},
+		}}, newSources(t, t.TempDir(), logger), logger)
+		require.NoError(t, err)
+		cancel := runRunner(t, r)
+
+		_, err = r.Start("sync")
+		require.NoError(t, err)
+		cancel()
+		out, _ := r.Output("sync")
+		readAll(t, out)
+
+		state, _ := r.Action("sync")
+		assert.Equal(t, StatusFailed, state.Last.Status)
+		assert.Equal(t, "stopped by shutdown", state.Last.Error)
+		require.Eventually(t, func() bool {
+			_, err := r.Start("sync")
+			return assert.ObjectsAreEqual(ErrStopped, err)
+		}, time.Second, time.Millisecond)
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		t.Parallel()
+
+		r, err := New(Config{Actions: []ActionConfig{
+			{Name: "sync", Command: []string{"true"}},
+		}}, newSources(t, t.TempDir(), logger), logger)
+		require.NoError(t, err)
+
+		_, err = r.Start("sync")
+		assert.ErrorIs(t, err, ErrStopped)
+
+		runRunner(t, r)
+		_, err = r.Start("scrub")
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+}
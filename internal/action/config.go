@@ -0,0 +1,88 @@
+package action
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"time"
+
+	"github.com/gi8lino/go-snapraid-web/internal/auth"
+	"gopkg.in/yaml.v3"
+)
+
+// actionName restricts action names to characters safe in URLs.
+var actionName = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// Config is the content of the actions configuration file.
+type Config struct {
+	Actions []ActionConfig `yaml:"actions"`
+}
+
+// ActionConfig configures a command that can be run on request.
+type ActionConfig struct {
+	Name    string        `yaml:"name"`    // unique name, used in URLs, e.g. diff or sync
+	Command []string      `yaml:"command"` // program and arguments, not run through a shell
+	Source  string        `yaml:"source"`  // source receiving the run files, the first source if empty
+	Timeout time.Duration `yaml:"timeout"` // the command is stopped after this duration, 0 disables
+	Role    auth.Role     `yaml:"role"`    // role required to start the action, operator if empty
+}
+
+// LoadConfig reads and validates the actions configuration file. Unknown
+// keys are rejected, so typos do not silently disable settings.
+func LoadConfig(path string) (Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Config{}, err
+	}
+	defer f.Close() // nolint:errcheck
+
+	var cfg Config
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+		return Config{}, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return Config{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// Validate checks that the configuration contains at least one action and
+// that all actions are valid and uniquely named.
+func (c Config) Validate() error {
+	if len(c.Actions) == 0 {
+		return errors.New("no actions configured")
+	}
+
+	seen := make(map[string]bool, len(c.Actions))
+	for i, a := range c.Actions {
+		if err := a.validate(); err != nil {
+			return fmt.Errorf("action %d: %w", i+1, err)
+		}
+		if seen[a.Name] {
+			return fmt.Errorf("action %d: duplicate name %q", i+1, a.Name)
+		}
+		seen[a.Name] = true
+	}
+	return nil
+}
+
+// validate checks a single action.
+func (a ActionConfig) validate() error {
+	if !actionName.MatchString(a.Name) {
+		return fmt.Errorf("invalid name %q, only letters, digits, '_', '.' and '-' are allowed", a.Name)
+	}
+	if len(a.Command) == 0 || a.Command[0] == "" {
+		return fmt.Errorf("%s: command is required", a.Name)
+	}
+	if a.Timeout < 0 {
+		return fmt.Errorf("%s: timeout must not be negative", a.Name)
+	}
+	if a.Role != "" && a.Role != auth.RoleOperator && a.Role != auth.RoleAdmin {
+		return fmt.Errorf("%s: invalid role %q, expected operator or admin", a.Name, a.Role)
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package action
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gi8lino/go-snapraid-web/internal/auth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadConfig(t *testing.T) {
+	t.Parallel()
+
+	write := func(t *testing.T, content string) string {
+		t.Helper()
+		path := filepath.Join(t.TempDir(), "actions.yaml")
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+		return path
+	}
+
+	t.Run("Valid config", func(t *testing.T) {
+		t.Parallel()
+
+		path := write(t, `
+actions:
+  - name: diff
+    command: [go-snapraid, --config, /etc/go-snapraid-diff.yml]
+    source: media
+    timeout: 1h
+  - name: sync
+    role: admin
+    command: [go-snapraid, --config, /etc/go-snapraid.yml]
+`)
+		cfg, err := LoadConfig(path)
+		require.NoError(t, err)
+		assert.Equal(t, Config{Actions: []ActionConfig{
+			{
+				Name:    "diff",
+				Command: []string{"go-snapraid", "--config", "/etc/go-snapraid-diff.yml"},
+				Source:  "media",
+				Timeout: time.Hour,
+			},
+			{
+				Name:    "sync",
+				Command: []string{"go-snapraid", "--config", "/etc/go-snapraid.yml"},
+				Role:    auth.RoleAdmin,
+			},
+		}}, cfg)
+	})
+
+	t.Run("Unknown key", func(t *testing.T) {
+		t.Parallel()
+
+		path := write(t, "actions:\n  - name: sync\n    schedule: \"0 3 * * *\"\n")
+		_, err := LoadConfig(path)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "field schedule not found")
+	})
+
+	t.Run("Empty file", func(t *testing.T) {
+		t.Parallel()
+
+		path := write(t, "")
+		_, err := LoadConfig(path)
+		assert.EqualError(t, err, path+": no actions configured")
+	})
+
+	t.Run("Missing file", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := LoadConfig(filepath.Join(t.TempDir(), "missing.yaml"))
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
+
+func TestConfig_Validate(t *testing.T) {
+	t.Parallel()
+
+	valid := ActionConfig{Name: "sync", Command: []string{"go-snapraid"}}
+	with := func(modify func(a *ActionConfig)) ActionConfig {
+		a := valid
+		modify(&a)
+		return a
+	}
+
+	tests := []struct {
+		name    string
+		actions []ActionConfig
+		err     string
+	}{
+		{"Valid", []ActionConfig{valid}, ""},
+		{"No actions", nil, "no actions configured"},
+		{"Invalid name", []ActionConfig{with(func(a *ActionConfig) { a.Name = "sync/all" })}, `action 1: invalid name "sync/all", only letters, digits, '_', '.' and '-' are allowed`},
+		{"Duplicate name", []ActionConfig{valid, valid}, `action 2: duplicate name "sync"`},
+		{"Missing command", []ActionConfig{with(func(a *ActionConfig) { a.Command = []string{""} })}, "action 1: sync: command is required"},
+		{"Negative timeout", []ActionConfig{with(func(a *ActionConfig) { a.Timeout = -time.Second })}, "action 1: sync: timeout must not be negative"},
+		{"Invalid role", []ActionConfig{with(func(a *ActionConfig) { a.Role = auth.RoleViewer })}, `action 1: sync: invalid role "viewer", expected operator or admin`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := Config{Actions: tt.actions}.Validate()
+			if tt.err == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, tt.err)
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package action
+
+import (
+	"bytes"
+	"sync"
+)
+
+const (
+	maxLines      = 1000    // output lines kept per execution
+	maxLineLength = 4 << 10 // bytes after which a line is split
+)
+
+// Stream names the output stream a line was written to.
+type Stream string
+
+const (
+	Stdout Stream = "stdout"
+	Stderr Stream = "stderr"
+)
+
+// Line is a line written by a command.
+type Line struct {
+	Stream Stream
+	Text   string
+}
+
+// Output collects the lines written by the command of an execution, so
+// readers joining late see them from the start. Only the last maxLines are
+// kept. It is safe for concurrent use.
+type Output struct {
+	mu      sync.Mutex
+	lines   []Line
+	dropped int           // lines dropped from the start
+	changed chan struct{} // closed and replaced whenever a line is added or the output is closed
+	done    bool
+}
+
+// newOutput returns an empty output.
+func newOutput() *Output {
+	return &Output{changed: make(chan struct{})}
+}
+
+// Read returns the lines from the from-th line on, the position to read
+// from next and a channel closed once more lines are available. Lines that
+// were already dropped are skipped. done reports whether the command has
+// exited, in which case no more lines follow.
+func (o *Output) Read(from int) (lines []Line, next int, changed <-chan struct{}, done bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	start := max(from-o.dropped, 0)
+	if start < len(o.lines) {
+		lines = append(lines, o.lines[start:]...)
+	}
+	return lines, o.dropped + len(o.lines), o.changed, o.done
+}
+
+// add appends a line, dropping the oldest beyond maxLines.
+func (o *Output) add(stream Stream, text string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.lines = append(o.lines, Line{Stream: stream, Text: text})
+	if over := len(o.lines) - maxLines; over > 0 {
+		o.lines = append(o.lines[:0], o.lines[over:]...)
+		o.dropped += over
+	}
+	o.notify()
+}
+
+// close marks the output as complete.
+func (o *Output) close() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.done = true
+	o.notify()
+}
+
+// notify wakes up waiting readers. o.mu must be held.
+func (o *Output) notify() {
+	close(o.changed)
+	o.changed = make(chan struct{})
+}
+
+// lineWriter splits the bytes written to one stream into lines.
+type lineWriter struct {
+	out     *Output
+	stream  Stream
+	partial []byte // bytes written after the last newline
+}
+
+// Write adds every complete line of p to the output. Lines longer than
+// maxLineLength are split.
+func (w *lineWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			w.partial, p = append(w.partial, p...), nil
+		} else {
+			w.partial, p = append(w.partial, p[:i]...), p[i+1:]
+		}
+		for len(w.partial) > maxLineLength {
+			w.out.add(w.stream, string(w.partial[:maxLineLength]))
+			w.partial = w.partial[maxLineLength:]
+		}
+		if i >= 0 {
+			w.add()
+		}
+	}
+	return n, nil
+}
+
+// flush adds the pending partial line, if any, once the command exited.
+func (w *lineWriter) flush() {
+	if len(w.partial) > 0 {
+		w.add()
+	}
+}
+
+// add adds the pending line without the carriage return of CRLF endings.
+func (w *lineWriter) add() {
+	w.out.add(w.stream, string(bytes.TrimSuffix(w.partial, []byte("\r"))))
+	w.partial = w.partial[:0]
+}
@@ -0,0 +1,87 @@
+package action
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutput_Read(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Follows new lines", func(t *testing.T) {
+		t.Parallel()
+
+		out := newOutput()
+		lines, next, changed, done := out.Read(0)
+		assert.Empty(t, lines)
+		assert.Equal(t, 0, next)
+		assert.False(t, done)
+
+		out.add(Stdout, "one")
+		out.add(Stderr, "two")
+		assert.True(t, isClosed(changed))
+
+		lines, next, changed, _ = out.Read(next)
+		assert.Equal(t, []Line{{Stream: Stdout, Text: "one"}, {Stream: Stderr, Text: "two"}}, lines)
+		assert.Equal(t, 2, next)
+		assert.False(t, isClosed(changed))
+
+		out.close()
+		assert.True(t, isClosed(changed))
+		lines, next, _, done = out.Read(next)
+		assert.Empty(t, lines)
+		assert.Equal(t, 2, next)
+		assert.True(t, done)
+	})
+
+	t.Run("Drops the oldest lines", func(t *testing.T) {
+		t.Parallel()
+
+		out := newOutput()
+		for i := range maxLines + 10 {
+			out.add(Stdout, fmt.Sprint(i))
+		}
+
+		lines, next, _, _ := out.Read(0)
+		assert.Len(t, lines, maxLines)
+		assert.Equal(t, "10", lines[0].Text)
+		assert.Equal(t, maxLines+10, next)
+
+		lines, _, _, _ = out.Read(maxLines + 8)
+		assert.Equal(t, []Line{{Stream: Stdout, Text: fmt.Sprint(maxLines + 8)}, {Stream: Stdout, Text: fmt.Sprint(maxLines + 9)}}, lines)
+	})
+}
+
+func TestLineWriter(t *testing.T) {
+	t.Parallel()
+
+	out := newOutput()
+	w := &lineWriter{out: out, stream: Stderr}
+	for _, p := range []string{"Loading", " state...\r\n\nSca", "nning\n", strings.Repeat("x", maxLineLength+1), "\nlast"} {
+		n, err := w.Write([]byte(p))
+		assert.NoError(t, err)
+		assert.Equal(t, len(p), n)
+	}
+	w.flush()
+
+	lines, _, _, _ := out.Read(0)
+	var texts []string
+	for _, l := range lines {
+		assert.Equal(t, Stderr, l.Stream)
+		texts = append(texts, l.Text)
+	}
+	assert.Equal(t, []string{"Loading state...", "", "Scanning", strings.Repeat("x", maxLineLength), "x", "last"}, texts)
+}
+
+// isClosed reports whether ch is closed.
+func isClosed(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
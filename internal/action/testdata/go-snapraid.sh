@@ -0,0 +1,21 @@
+#!/bin/sh
+# Fake go-snapraid for tests. Usage: go-snapraid.sh DIR ID [STATUS [WAIT]]
+#
+# Prints progress to stdout and stderr like go-snapraid, waits until the
+# file WAIT exists if given, writes a run file with the given ID into DIR
+# unless ID is empty and exits with STATUS.
+dir=$1
+id=$2
+status=${3:-0}
+wait=$4
+
+echo "Running diff..."
+echo "WARNING! Content file is not up to date" >&2
+if [ -n "$wait" ]; then
+	while [ ! -e "$wait" ]; do sleep 0.01; done
+fi
+if [ -n "$id" ]; then
+	printf '{"timestamp":"%s","result":{"added_files":["filme/a.mkv"]}}' "$id" >"$dir/$id.json"
+fi
+printf 'Everything OK'
+exit "$status"
@@ -0,0 +1,127 @@
+// Package api holds the JSON representation of runs served by the API, shared
+// by the handlers serving it and the mirror of remote instances reading it.
+package api
+
+import (
+	"github.com/gi8lino/go-snapraid-web/internal/runindex"
+	"github.com/gi8lino/go-snapraid/pkg/snapraid"
+)
+
+// RunCounts holds the number of files per change category of a run.
+type RunCounts struct {
+	Equal    int `json:"equal"`
+	Added    int `json:"added"`
+	Removed  int `json:"removed"`
+	Updated  int `json:"updated"`
+	Moved    int `json:"moved"`
+	Copied   int `json:"copied"`
+	Restored int `json:"restored"`
+	Total    int `json:"total"` // sum of all changed files, excluding equal
+}
+
+// RunTimings holds the per-step durations of a run in seconds.
+type RunTimings struct {
+	Touch float64 `json:"touch_seconds"`
+	Diff  float64 `json:"diff_seconds"`
+	Sync  float64 `json:"sync_seconds"`
+	Scrub float64 `json:"scrub_seconds"`
+	Smart float64 `json:"smart_seconds"`
+	Total float64 `json:"total_seconds"`
+}
+
+// RunSummary is the API representation of a run as listed in the overview.
+type RunSummary struct {
+	ID        string             `json:"id"`   // run ID / RFC3339 timestamp of the run file
+	Date      string             `json:"date"` // timestamp recorded inside the run file
+	Counts    RunCounts          `json:"counts"`
+	Timings   RunTimings         `json:"timings"`
+	Status    runindex.Status    `json:"status"`          // success, failed or partial
+	Error     string             `json:"error,omitempty"` // error message, omitted on success
+	Anomalies []runindex.Anomaly `json:"anomalies"`       // categories exceeding the warning thresholds
+}
+
+// RunFiles holds the changed file paths of a run per category.
+type RunFiles struct {
+	Added    []string `json:"added"`
+	Removed  []string `json:"removed"`
+	Updated  []string `json:"updated"`
+	Moved    []string `json:"moved"`
+	Copied   []string `json:"copied"`
+	Restored []string `json:"restored"`
+}
+
+// RunDetail is the API representation of a single run including its file
+// changes. The files are omitted for users that file paths are hidden from.
+type RunDetail struct {
+	RunSummary
+	Files    RunFiles `json:"files,omitzero"`     // paths with SnapRAID escapes decoded
+	RawFiles RunFiles `json:"raw_files,omitzero"` // shell-escaped paths as written by go-snapraid
+}
+
+// Add increments the counter of the given category and the total.
+func (c *RunCounts) Add(cat runindex.Category) {
+	switch cat {
+	case runindex.CategoryAdded:
+		c.Added++
+	case runindex.CategoryRemoved:
+		c.Removed++
+	case runindex.CategoryUpdated:
+		c.Updated++
+	case runindex.CategoryMoved:
+		c.Moved++
+	case runindex.CategoryCopied:
+		c.Copied++
+	case runindex.CategoryRestored:
+		c.Restored++
+	}
+	c.Total++
+}
+
+// NewRunFiles converts the file lists of a run result into their API representation.
+func NewRunFiles(result snapraid.DiffResult) RunFiles {
+	return RunFiles{
+		Added:    NonNil(result.Added),
+		Removed:  NonNil(result.Removed),
+		Updated:  NonNil(result.Updated),
+		Moved:    NonNil(result.Moved),
+		Copied:   NonNil(result.Copied),
+		Restored: NonNil(result.Restored),
+	}
+}
+
+// NewRunSummary converts an indexed run summary into its API representation.
+func NewRunSummary(s runindex.Summary) RunSummary {
+	return RunSummary{
+		ID:   s.ID,
+		Date: s.Timestamp,
+		Counts: RunCounts{
+			Equal:    s.Equal,
+			Added:    s.Added,
+			Removed:  s.Removed,
+			Updated:  s.Updated,
+			Moved:    s.Moved,
+			Copied:   s.Copied,
+			Restored: s.Restored,
+			Total:    s.Total(),
+		},
+		Timings: RunTimings{
+			Touch: s.Timings.Touch.Seconds(),
+			Diff:  s.Timings.Diff.Seconds(),
+			Sync:  s.Timings.Sync.Seconds(),
+			Scrub: s.Timings.Scrub.Seconds(),
+			Smart: s.Timings.Smart.Seconds(),
+			Total: s.Timings.Total.Seconds(),
+		},
+		Status:    s.Status,
+		Error:     s.Error,
+		Anomalies: NonNil(s.Anomalies),
+	}
+}
+
+// NonNil returns an empty slice for nil so it encodes as [] instead of null.
+func NonNil[T any](s []T) []T {
+	if s == nil {
+		return []T{}
+	}
+	return s
+}
@@ -0,0 +1,37 @@
+package app
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/gi8lino/go-snapraid-web/internal/flag"
+	"github.com/gi8lino/go-snapraid-web/internal/logging"
+	"github.com/gi8lino/go-snapraid-web/internal/prune"
+
+	"github.com/containeroo/tinyflags"
+)
+
+// runPrune runs the prune subcommand: it applies the retention policy to the
+// output directory once and writes a report of the kept and pruned runs to w.
+func runPrune(args []string, version string, w io.Writer) error {
+	flags, err := flag.ParsePruneFlags(args, version)
+	logger := logging.SetupLogger(flags.LogFormat, w)
+	if err != nil {
+		if tinyflags.IsHelpRequested(err) || tinyflags.IsVersionRequested(err) {
+			_, _ = fmt.Fprintf(w, "%s\n", err)
+			return nil
+		}
+		logger.Error("Failed to parse CLI flags", "error", err)
+		return err
+	}
+
+	decisions, err := prune.New(flags.OutputDir, flags.Policy, flags.Action, logger).Prune(flags.DryRun)
+	if reportErr := prune.WriteReport(w, decisions, flags.Action, flags.DryRun); reportErr != nil && err == nil {
+		err = reportErr
+	}
+	if err != nil {
+		logger.Error("Failed to prune run files", "error", err)
+		return err
+	}
+	return nil
+}
@@ -5,11 +5,22 @@ import (
 	"fmt"
 	"io"
 	"io/fs"
+	"log/slog"
+	"os"
 	"os/signal"
+	"path/filepath"
+	"strings"
 	"syscall"
 
+	"github.com/gi8lino/go-snapraid-web/internal/action"
+	"github.com/gi8lino/go-snapraid-web/internal/auth"
 	"github.com/gi8lino/go-snapraid-web/internal/flag"
 	"github.com/gi8lino/go-snapraid-web/internal/logging"
+	"github.com/gi8lino/go-snapraid-web/internal/notify"
+	"github.com/gi8lino/go-snapraid-web/internal/prune"
+	"github.com/gi8lino/go-snapraid-web/internal/remote"
+	"github.com/gi8lino/go-snapraid-web/internal/runindex"
+	"github.com/gi8lino/go-snapraid-web/internal/schedule"
 	"github.com/gi8lino/go-snapraid-web/internal/server"
 
 	"github.com/containeroo/tinyflags"
@@ -17,6 +28,10 @@ import (
 
 // Run is the single entry point for the application.
 func Run(ctx context.Context, webFS fs.FS, version, commit string, args []string, w io.Writer) error {
+	if len(args) > 0 && args[0] == "prune" {
+		return runPrune(args[1:], version, w)
+	}
+
 	// Parse and validate command-line flags.
 	flags, err := flag.ParseFlags(args, version)
 
@@ -40,17 +55,223 @@ func Run(ctx context.Context, webFS fs.FS, version, commit string, args []string
 	ctx, stop := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
 
+	// Load the run history of each source once and keep it up to date in the background
+	thresholds := runindex.Thresholds{
+		runindex.CategoryRemoved: {Count: flags.WarnRemovedCount, Percent: flags.WarnRemovedPercent},
+		runindex.CategoryUpdated: {Count: flags.WarnUpdatedCount, Percent: flags.WarnUpdatedPercent},
+	}
+	sources := make(runindex.Sources, 0, len(flags.Sources))
+	for _, src := range flags.Sources {
+		index := runindex.New(src.Dir, thresholds, logger.With("source", src.Name))
+		if err := index.Refresh(); err != nil {
+			logger.Error("Failed to load run files", "source", src.Name, "error", err)
+			return err
+		}
+		logger.Info("Loaded run files", "source", src.Name, "outputDir", src.Dir, "runs", len(index.IDs()))
+		go index.Watch(ctx, flags.WatchInterval)
+		sources = append(sources, runindex.Source{Name: src.Name, Index: index})
+	}
+
+	// Notify webhooks about new runs
+	if len(flags.Webhooks) > 0 {
+		webhooks, err := loadWebhooks(flags.Webhooks, flags.WebhookTemplate)
+		if err != nil {
+			logger.Error("Failed to load webhook template", "error", err)
+			return err
+		}
+		for _, src := range sources {
+			notifier := notify.New(src.Index, webhooks, logger.With("source", src.Name))
+			if len(flags.Sources)+len(flags.Remotes) > 1 {
+				notifier.Source = src.Name
+			}
+			go notifier.Run(ctx)
+		}
+		logger.Info("Enabled webhook notifications", "webhooks", len(webhooks))
+	}
+
+	// Prune the output directories in the background
+	if flags.PruneInterval > 0 {
+		for _, src := range flags.Sources {
+			go prune.New(src.Dir, flags.Prune, flags.PruneAction, logger.With("source", src.Name)).Run(ctx, flags.PruneInterval)
+		}
+		logger.Info("Enabled pruning of run files", "interval", flags.PruneInterval, "action", flags.PruneAction)
+	}
+
+	// Mirror the runs of other instances, they notify and prune on their own
+	for _, r := range flags.Remotes {
+		dir := filepath.Join(flags.RemoteCacheDir, r.Name)
+		index := runindex.New(dir, thresholds, logger.With("source", r.Name))
+		if err := index.Refresh(); err != nil {
+			logger.Error("Failed to load cached run files", "source", r.Name, "error", err)
+			return err
+		}
+		mirror, err := remote.New(r.URL, dir, index, logger.With("source", r.Name))
+		if err != nil {
+			logger.Error("Failed to set up remote", "source", r.Name, "error", err)
+			return err
+		}
+		if r.TokenFile != "" {
+			token, err := os.ReadFile(r.TokenFile)
+			if err != nil {
+				logger.Error("Failed to read remote token", "source", r.Name, "error", err)
+				return err
+			}
+			mirror.Token = strings.TrimSpace(string(token))
+		}
+		go mirror.Run(ctx, flags.RemoteInterval)
+		logger.Info("Mirroring remote runs", "source", r.Name, "url", mirror.Health().URL, "cacheDir", dir, "runs", len(index.IDs()))
+		sources = append(sources, runindex.Source{Name: r.Name, Index: index, Health: mirror.Health})
+	}
+
+	// Run scheduled jobs until shutdown, waiting for running commands to stop
+	var scheduler *schedule.Scheduler
+	if flags.ScheduleConfig != "" {
+		cfg, err := schedule.LoadConfig(flags.ScheduleConfig)
+		if err != nil {
+			logger.Error("Failed to load schedule config", "error", err)
+			return err
+		}
+		if scheduler, err = schedule.New(cfg, sources, logger); err != nil {
+			logger.Error("Failed to set up scheduler", "error", err)
+			return err
+		}
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			scheduler.Run(ctx)
+		}()
+		defer func() {
+			stop()
+			<-done
+		}()
+		logger.Info("Enabled scheduled jobs", "jobs", len(cfg.Jobs))
+	}
+
+	// Accept actions until shutdown, waiting for running commands to stop
+	var actions *action.Runner
+	if flags.ActionsConfig != "" {
+		cfg, err := action.LoadConfig(flags.ActionsConfig)
+		if err != nil {
+			logger.Error("Failed to load actions config", "error", err)
+			return err
+		}
+		if actions, err = action.New(cfg, sources, logger); err != nil {
+			logger.Error("Failed to set up actions", "error", err)
+			return err
+		}
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			actions.Run(ctx)
+		}()
+		defer func() {
+			stop()
+			<-done
+		}()
+		logger.Info("Enabled actions", "actions", len(cfg.Actions))
+	}
+
+	// Require authentication if any method is configured
+	authenticators, err := loadAuthenticators(ctx, flags, logger)
+	if err != nil {
+		logger.Error("Failed to set up authentication", "error", err)
+		return err
+	}
+	if len(authenticators) > 0 {
+		logger.Info("Enabled authentication", "methods", len(authenticators))
+	}
+
 	// Create server and run forever
 	router := server.NewRouter(
 		webFS,
-		flags.OutputDir,
+		sources,
+		scheduler,
+		actions,
+		authenticators,
+		flags.AuthPolicy,
 		version,
 		logger,
 	)
-	if err := server.Run(ctx, flags.ListenAddr, router, logger); err != nil {
+	if err := server.Run(ctx, flags.ListenAddr, router, server.TLSConfig{
+		CertFile:       flags.TLSCertFile,
+		KeyFile:        flags.TLSKeyFile,
+		ClientCAFile:   flags.TLSClientCAFile,
+		RedirectAddr:   flags.TLSRedirectAddr,
+		ReloadInterval: flags.TLSReloadInterval,
+	}, logger); err != nil {
 		logger.Error("Failed to run go-snapraid-web", "error", err)
 		return err
 	}
 
 	return nil
 }
+
+// loadAuthenticators creates an authenticator for each configured method.
+func loadAuthenticators(ctx context.Context, flags flag.Options, logger *slog.Logger) ([]auth.Authenticator, error) {
+	var authenticators []auth.Authenticator
+	if flags.AuthHtpasswd != "" {
+		basic, err := auth.NewBasic(flags.AuthHtpasswd, logger)
+		if err != nil {
+			return nil, err
+		}
+		authenticators = append(authenticators, basic)
+	}
+	if flags.AuthTokenFile != "" {
+		token, err := auth.LoadToken(flags.AuthTokenFile)
+		if err != nil {
+			return nil, err
+		}
+		authenticators = append(authenticators, token)
+	}
+	if flags.AuthProxyHeader != "" {
+		authenticators = append(authenticators, auth.NewProxy(flags.AuthProxyHeader, flags.AuthProxyCIDRs))
+	}
+	if flags.OIDCIssuer != "" {
+		cfg := auth.OIDCConfig{
+			IssuerURL:     flags.OIDCIssuer,
+			ClientID:      flags.OIDCClientID,
+			ExternalURL:   flags.ExternalURL,
+			Scopes:        flags.OIDCScopes,
+			UsernameClaim: flags.OIDCUsernameClaim,
+			GroupsClaim:   flags.OIDCGroupsClaim,
+			AllowedGroups: flags.OIDCAllowedGroups,
+			SessionTTL:    flags.OIDCSessionTTL,
+		}
+		if flags.OIDCClientSecretFile != "" {
+			secret, err := os.ReadFile(flags.OIDCClientSecretFile)
+			if err != nil {
+				return nil, err
+			}
+			cfg.ClientSecret = strings.TrimSpace(string(secret))
+		}
+		oidc, err := auth.NewOIDC(ctx, cfg, logger)
+		if err != nil {
+			return nil, fmt.Errorf("set up OIDC: %w", err)
+		}
+		authenticators = append(authenticators, oidc)
+	}
+	return authenticators, nil
+}
+
+// loadWebhooks applies the custom body template, if any, to all generic webhooks.
+func loadWebhooks(webhooks []notify.Webhook, templateFile string) ([]notify.Webhook, error) {
+	if templateFile == "" {
+		return webhooks, nil
+	}
+
+	text, err := os.ReadFile(templateFile)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]notify.Webhook, 0, len(webhooks))
+	for _, w := range webhooks {
+		if w.Kind == notify.KindGeneric {
+			if w, err = w.WithTemplate(string(text)); err != nil {
+				return nil, fmt.Errorf("parse %s: %w", templateFile, err)
+			}
+		}
+		out = append(out, w)
+	}
+	return out, nil
+}
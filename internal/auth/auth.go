@@ -0,0 +1,92 @@
+// Package auth authenticates requests to the dashboard and its API.
+package auth
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// Realm is announced in authentication challenges.
+const Realm = "go-snapraid-web"
+
+// Authentication methods, as reported in Identity.Method.
+const (
+	MethodBasic = "basic" // HTTP basic auth against an htpasswd file
+	MethodToken = "token" // static bearer token
+	MethodProxy = "proxy" // header set by a trusted reverse proxy
+	MethodOIDC  = "oidc"  // session of an OpenID Connect login
+)
+
+// Identity is an authenticated user.
+type Identity struct {
+	Name      string   // user name
+	Method    string   // method that authenticated the request
+	Groups    []string // groups of the user, only known with OIDC
+	Role      Role     // role assigned by the Policy
+	HidePaths bool     // whether file paths are hidden from the user
+}
+
+// Authenticator authenticates requests by a single method.
+type Authenticator interface {
+	// Authenticate returns the identity of the request, reporting false if
+	// the request carries no valid credentials for the method.
+	Authenticate(r *http.Request) (Identity, bool)
+
+	// Challenge returns the WWW-Authenticate header value asking for
+	// credentials, or "" if the method has none.
+	Challenge() string
+}
+
+// Login is implemented by authenticators with an interactive login page,
+// which browsers are redirected to instead of being challenged.
+type Login interface {
+	// LoginURL returns the URL to log in at, returning to r afterwards.
+	LoginURL(r *http.Request) string
+}
+
+// identityKey is the context key of the Identity of a request.
+type identityKey struct{}
+
+// WithIdentity returns a copy of ctx carrying id.
+func WithIdentity(ctx context.Context, id Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, id)
+}
+
+// FromContext returns the identity of an authenticated request.
+func FromContext(ctx context.Context) (Identity, bool) {
+	id, ok := ctx.Value(identityKey{}).(Identity)
+	return id, ok
+}
+
+// Require returns a handler passing requests accepted by any of the
+// authenticators, tried in order, to next with their identity in the
+// request context. Browsers navigating to a page are redirected to the first
+// Login, other requests are answered with 401 Unauthorized and the
+// challenges of all authenticators.
+func Require(authenticators []Authenticator, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, a := range authenticators {
+			if id, ok := a.Authenticate(r); ok {
+				next.ServeHTTP(w, r.WithContext(WithIdentity(r.Context(), id)))
+				return
+			}
+		}
+
+		if r.Method == http.MethodGet && strings.Contains(r.Header.Get("Accept"), "text/html") {
+			for _, a := range authenticators {
+				if l, ok := a.(Login); ok {
+					http.Redirect(w, r, l.LoginURL(r), http.StatusFound)
+					return
+				}
+			}
+		}
+
+		for _, a := range authenticators {
+			if c := a.Challenge(); c != "" {
+				w.Header().Add("WWW-Authenticate", c)
+			}
+		}
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+	})
+}
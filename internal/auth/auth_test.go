@@ -0,0 +1,104 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// staticAuth accepts requests with a matching "user" query parameter.
+type staticAuth struct {
+	user      string
+	challenge string
+}
+
+func (s staticAuth) Authenticate(r *http.Request) (Identity, bool) {
+	if r.URL.Query().Get("user") != s.user {
+		return Identity{}, false
+	}
+	return Identity{Name: s.user, Method: "static"}, true
+}
+
+func (s staticAuth) Challenge() string {
+	return s.challenge
+}
+
+// loginAuth is a staticAuth with a login page.
+type loginAuth struct{ staticAuth }
+
+func (l loginAuth) LoginURL(r *http.Request) string {
+	return "/login?next=" + r.URL.Path
+}
+
+func TestFromContext(t *testing.T) {
+	t.Parallel()
+
+	_, ok := FromContext(context.Background())
+	assert.False(t, ok)
+
+	id, ok := FromContext(WithIdentity(context.Background(), Identity{Name: "alice", Method: MethodBasic}))
+	assert.True(t, ok)
+	assert.Equal(t, Identity{Name: "alice", Method: MethodBasic}, id)
+}
+
+func TestRequire(t *testing.T) {
+	t.Parallel()
+
+	handler := Require([]Authenticator{
+		staticAuth{user: "alice", challenge: "First"},
+		staticAuth{user: "bob"},
+		staticAuth{user: "carol", challenge: "Third"},
+	}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, _ := FromContext(r.Context())
+		_, _ = w.Write([]byte(id.Name))
+	}))
+
+	t.Run("Accepted by any authenticator", func(t *testing.T) {
+		t.Parallel()
+
+		for _, user := range []string{"alice", "bob", "carol"} {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/?user="+user, nil))
+
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, user, rec.Body.String())
+		}
+	})
+
+	t.Run("Rejected with all challenges", func(t *testing.T) {
+		t.Parallel()
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/?user=mallory", nil))
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assert.Equal(t, []string{"First", "Third"}, rec.Header().Values("WWW-Authenticate"))
+		assert.Equal(t, "unauthorized\n", rec.Body.String())
+	})
+	t.Run("Browsers are redirected to the login", func(t *testing.T) {
+		t.Parallel()
+
+		handler := Require([]Authenticator{
+			staticAuth{user: "alice", challenge: "First"},
+			loginAuth{staticAuth{user: "bob"}},
+		}, http.NotFoundHandler())
+
+		req := httptest.NewRequest(http.MethodGet, "/runs", nil)
+		req.Header.Set("Accept", "text/html,application/xhtml+xml,*/*;q=0.8")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusFound, rec.Code)
+		assert.Equal(t, "/login?next=/runs", rec.Header().Get("Location"))
+
+		// API requests are challenged
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/runs", nil))
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assert.Equal(t, "First", rec.Header().Get("WWW-Authenticate"))
+	})
+}
@@ -0,0 +1,120 @@
+package auth
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Basic authenticates requests with HTTP basic auth against the bcrypt
+// hashes of an htpasswd file.
+type Basic struct {
+	users  map[string][]byte // bcrypt hash by user name
+	dummy  []byte            // hash compared for unknown users, so they take as long as known ones
+	logger *slog.Logger
+
+	mu       sync.Mutex
+	verified map[string][sha256.Size]byte // digest of the last verified password by user name
+}
+
+// NewBasic returns a Basic authenticator for the users of the htpasswd file
+// at path. Only bcrypt hashes are supported, as created by `htpasswd -B`.
+func NewBasic(path string, logger *slog.Logger) (*Basic, error) {
+	users, err := LoadHtpasswd(path)
+	if err != nil {
+		return nil, err
+	}
+	dummy, err := bcrypt.GenerateFromPassword([]byte("go-snapraid-web"), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+	return &Basic{
+		users:    users,
+		dummy:    dummy,
+		logger:   logger,
+		verified: make(map[string][sha256.Size]byte),
+	}, nil
+}
+
+// LoadHtpasswd reads the users of an htpasswd file with bcrypt hashes.
+// Empty lines and lines starting with '#' are ignored.
+func LoadHtpasswd(path string) (map[string][]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close() // nolint:errcheck
+
+	users := make(map[string][]byte)
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		user, hash, ok := strings.Cut(line, ":")
+		if !ok || user == "" {
+			return nil, fmt.Errorf("%s:%d: expected USER:HASH", path, n)
+		}
+		if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+			return nil, fmt.Errorf("%s:%d: user %q: unsupported hash, only bcrypt is supported (htpasswd -B)", path, n, user)
+		}
+		if _, ok := users[user]; ok {
+			return nil, fmt.Errorf("%s:%d: duplicate user %q", path, n, user)
+		}
+		users[user] = []byte(hash)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(users) == 0 {
+		return nil, fmt.Errorf("%s: no users found", path)
+	}
+	return users, nil
+}
+
+// Authenticate checks the basic auth credentials of r. Since bcrypt is slow
+// by design, a verified password is remembered until it changes, so the many
+// requests of the dashboard are not delayed.
+func (b *Basic) Authenticate(r *http.Request) (Identity, bool) {
+	user, password, ok := r.BasicAuth()
+	if !ok {
+		return Identity{}, false
+	}
+	id := Identity{Name: user, Method: MethodBasic}
+	digest := sha256.Sum256([]byte(password))
+
+	hash, known := b.users[user]
+	b.mu.Lock()
+	verified, cached := b.verified[user]
+	b.mu.Unlock()
+	if known && cached && subtle.ConstantTimeCompare(verified[:], digest[:]) == 1 {
+		return id, true
+	}
+
+	if !known {
+		hash = b.dummy
+	}
+	if err := bcrypt.CompareHashAndPassword(hash, []byte(password)); err != nil || !known {
+		b.logger.Warn("invalid basic auth credentials", "user", user, "remoteAddr", r.RemoteAddr)
+		return Identity{}, false
+	}
+
+	b.mu.Lock()
+	b.verified[user] = digest
+	b.mu.Unlock()
+	return id, true
+}
+
+// Challenge asks browsers for a user name and password.
+func (b *Basic) Challenge() string {
+	return fmt.Sprintf("Basic realm=%q, charset=\"UTF-8\"", Realm)
+}
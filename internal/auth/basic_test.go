@@ -0,0 +1,115 @@
+package auth
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// writeHtpasswd writes an htpasswd file with the given content.
+func writeHtpasswd(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "htpasswd")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+// hash returns the bcrypt hash of password with the minimum cost.
+func hash(t *testing.T, password string) string {
+	t.Helper()
+	h, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	require.NoError(t, err)
+	return string(h)
+}
+
+func TestLoadHtpasswd(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Users", func(t *testing.T) {
+		t.Parallel()
+
+		alice, bob := hash(t, "alice"), hash(t, "bob")
+		path := writeHtpasswd(t, "# admins\nalice:"+alice+"\n\n  bob:"+bob+"  \n")
+
+		users, err := LoadHtpasswd(path)
+		require.NoError(t, err)
+		assert.Equal(t, map[string][]byte{"alice": []byte(alice), "bob": []byte(bob)}, users)
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		t.Parallel()
+
+		h := hash(t, "alice")
+		tests := []struct {
+			name    string
+			content string
+			err     string
+		}{
+			{name: "no separator", content: "alice\n", err: ":1: expected USER:HASH"},
+			{name: "no user", content: ":" + h + "\n", err: ":1: expected USER:HASH"},
+			{name: "not bcrypt", content: "# md5\nalice:$apr1$salt$hash\n", err: `:2: user "alice": unsupported hash, only bcrypt is supported (htpasswd -B)`},
+			{name: "duplicate user", content: "alice:" + h + "\nalice:" + h + "\n", err: `:2: duplicate user "alice"`},
+			{name: "empty", content: "# nobody\n", err: ": no users found"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				t.Parallel()
+
+				path := writeHtpasswd(t, tt.content)
+				_, err := LoadHtpasswd(path)
+				assert.EqualError(t, err, path+tt.err)
+			})
+		}
+	})
+
+	t.Run("Missing file", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := LoadHtpasswd(filepath.Join(t.TempDir(), "missing"))
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
+
+func TestBasic(t *testing.T) {
+	t.Parallel()
+
+	var logs strings.Builder
+	path := writeHtpasswd(t, "alice:"+hash(t, "secret")+"\n")
+	basic, err := NewBasic(path, slog.New(slog.NewTextHandler(&logs, nil)))
+	require.NoError(t, err)
+
+	request := func(user, password string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.SetBasicAuth(user, password)
+		return req
+	}
+
+	id, ok := basic.Authenticate(request("alice", "secret"))
+	assert.True(t, ok)
+	assert.Equal(t, Identity{Name: "alice", Method: MethodBasic}, id)
+
+	// served from the cache of verified passwords
+	_, ok = basic.Authenticate(request("alice", "secret"))
+	assert.True(t, ok)
+
+	_, ok = basic.Authenticate(request("alice", "wrong"))
+	assert.False(t, ok)
+
+	_, ok = basic.Authenticate(request("mallory", "secret"))
+	assert.False(t, ok)
+
+	_, ok = basic.Authenticate(httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.False(t, ok)
+
+	assert.Equal(t, 2, strings.Count(logs.String(), "invalid basic auth credentials"))
+	assert.Contains(t, logs.String(), "user=mallory")
+	assert.Equal(t, `Basic realm="go-snapraid-web", charset="UTF-8"`, basic.Challenge())
+}
@@ -0,0 +1,372 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+// Paths of the OIDC login flow, served without authentication.
+const (
+	LoginPath    = "/auth/login"
+	CallbackPath = "/auth/callback"
+	LogoutPath   = "/auth/logout"
+)
+
+const (
+	sessionCookie = "go_snapraid_session" // holds the session ID
+	loginCookie   = "go_snapraid_login"   // binds a login attempt to the browser that started it
+	loginTimeout  = 10 * time.Minute      // time to complete the login at the provider
+	maxLogins     = 1000                  // login attempts kept at once, bounds the memory of unauthenticated requests
+)
+
+// DefaultGroupsClaim is the ID token claim listing the groups of a user.
+const DefaultGroupsClaim = "groups"
+
+// OIDCConfig configures the login with an OpenID Connect provider.
+type OIDCConfig struct {
+	IssuerURL     string        // URL of the provider, e.g. https://auth.example.com
+	ClientID      string        // client registered at the provider
+	ClientSecret  string        // secret of the client, empty for public clients
+	ExternalURL   string        // URL the dashboard is reached at, the callback is below it
+	Scopes        []string      // scopes requested in addition to openid, profile and email
+	UsernameClaim string        // ID token claim naming users, the stable sub claim if empty
+	GroupsClaim   string        // ID token claim listing the groups of a user, DefaultGroupsClaim if empty
+	AllowedGroups []string      // groups allowed to log in, empty allows every user of the provider
+	SessionTTL    time.Duration // time until users have to log in again
+}
+
+// session is a logged in user.
+type session struct {
+	identity Identity
+	idToken  string // raw ID token, passed as hint when logging out at the provider
+	expires  time.Time
+}
+
+// login is a login attempt waiting for the callback of the provider.
+type login struct {
+	nonce    string // expected in the ID token
+	verifier string // PKCE code verifier
+	next     string // path to return to after logging in
+	expires  time.Time
+}
+
+// OIDC authenticates users with the authorization code flow of an OpenID
+// Connect provider. Logged in users are kept in memory and identified by a
+// session cookie, so they have to log in again after a restart.
+type OIDC struct {
+	cfg        OIDCConfig
+	oauth2     oauth2.Config
+	verifier   *oidc.IDTokenVerifier
+	endSession string // end_session_endpoint of the provider, empty if not supported
+	secure     bool   // whether cookies are restricted to HTTPS
+	mux        *http.ServeMux
+	logger     *slog.Logger
+
+	mu       sync.Mutex
+	sessions map[string]session // by session ID
+	logins   map[string]login   // by state
+	Now      func() time.Time   // returns the current time, replaceable in tests
+}
+
+// NewOIDC discovers the provider at cfg.IssuerURL and returns an OIDC
+// authenticator for it.
+func NewOIDC(ctx context.Context, cfg OIDCConfig, logger *slog.Logger) (*OIDC, error) {
+	external, err := url.Parse(cfg.ExternalURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid external URL: %w", err)
+	}
+	provider, err := oidc.NewProvider(ctx, cfg.IssuerURL)
+	if err != nil {
+		return nil, err
+	}
+	var discovery struct {
+		EndSession string `json:"end_session_endpoint"`
+	}
+	if err := provider.Claims(&discovery); err != nil {
+		return nil, err
+	}
+	if cfg.GroupsClaim == "" {
+		cfg.GroupsClaim = DefaultGroupsClaim
+	}
+
+	o := &OIDC{
+		cfg: cfg,
+		oauth2: oauth2.Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			Endpoint:     provider.Endpoint(),
+			RedirectURL:  external.JoinPath(CallbackPath).String(),
+			Scopes:       append([]string{oidc.ScopeOpenID, "profile", "email"}, cfg.Scopes...),
+		},
+		verifier:   provider.Verifier(&oidc.Config{ClientID: cfg.ClientID}),
+		endSession: discovery.EndSession,
+		secure:     external.Scheme == "https",
+		mux:        http.NewServeMux(),
+		logger:     logger,
+		sessions:   make(map[string]session),
+		logins:     make(map[string]login),
+		Now:        time.Now,
+	}
+	o.mux.HandleFunc("GET "+LoginPath, o.login)
+	o.mux.HandleFunc("GET "+CallbackPath, o.callback)
+	o.mux.HandleFunc("GET "+LogoutPath, o.logout)
+	return o, nil
+}
+
+// Authenticate returns the user of the session cookie of r.
+func (o *OIDC) Authenticate(r *http.Request) (Identity, bool) {
+	cookie, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return Identity{}, false
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	s, ok := o.sessions[cookie.Value]
+	if !ok || !o.Now().Before(s.expires) {
+		return Identity{}, false
+	}
+	return s.identity, true
+}
+
+// Challenge returns "", browsers are redirected to LoginURL instead.
+func (o *OIDC) Challenge() string {
+	return ""
+}
+
+// LoginURL returns the URL starting the login, returning to r afterwards.
+func (o *OIDC) LoginURL(r *http.Request) string {
+	return LoginPath + "?" + url.Values{"next": {r.URL.RequestURI()}}.Encode()
+}
+
+// ServeHTTP serves the login flow below /auth/.
+func (o *OIDC) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	o.mux.ServeHTTP(w, r)
+}
+
+// login redirects to the provider.
+func (o *OIDC) login(w http.ResponseWriter, r *http.Request) {
+	state := rand.Text()
+	l := login{
+		nonce:    rand.Text(),
+		verifier: oauth2.GenerateVerifier(),
+		next:     localPath(r.URL.Query().Get("next")),
+		expires:  o.Now().Add(loginTimeout),
+	}
+
+	o.mu.Lock()
+	if len(o.logins) >= maxLogins {
+		o.expireLogins()
+	}
+	full := len(o.logins) >= maxLogins
+	if !full {
+		o.logins[state] = l
+	}
+	o.mu.Unlock()
+	if full {
+		http.Error(w, "too many pending logins, please try again later", http.StatusServiceUnavailable)
+		return
+	}
+
+	o.setCookie(w, loginCookie, state, "/auth/", loginTimeout)
+	authURL := o.oauth2.AuthCodeURL(state, oidc.Nonce(l.nonce), oauth2.S256ChallengeOption(l.verifier))
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// callback exchanges the authorization code for an ID token, checks the
+// groups of the user and starts a session.
+func (o *OIDC) callback(w http.ResponseWriter, r *http.Request) {
+	state := r.URL.Query().Get("state")
+	cookie, err := r.Cookie(loginCookie)
+	if err != nil || state == "" || cookie.Value != state {
+		http.Error(w, "invalid login state, please try again", http.StatusBadRequest)
+		return
+	}
+	o.setCookie(w, loginCookie, "", "/auth/", -1)
+
+	o.mu.Lock()
+	l, ok := o.logins[state]
+	delete(o.logins, state)
+	o.mu.Unlock()
+	if !ok || !o.Now().Before(l.expires) {
+		http.Error(w, "login expired, please try again", http.StatusBadRequest)
+		return
+	}
+
+	if msg := r.URL.Query().Get("error"); msg != "" {
+		o.logger.Warn("OIDC login failed", "error", msg, "description", r.URL.Query().Get("error_description"))
+		http.Error(w, "login failed: "+msg, http.StatusUnauthorized)
+		return
+	}
+
+	identity, rawIDToken, err := o.exchange(r.Context(), r.URL.Query().Get("code"), l)
+	if err != nil {
+		o.logger.Warn("OIDC login failed", "error", err, "remoteAddr", r.RemoteAddr)
+		http.Error(w, "login failed", http.StatusUnauthorized)
+		return
+	}
+	if !o.allowed(identity) {
+		o.logger.Warn("OIDC user is not in an allowed group", "user", identity.Name, "groups", identity.Groups)
+		http.Error(w, fmt.Sprintf("user %q is not in an allowed group", identity.Name), http.StatusForbidden)
+		return
+	}
+
+	id := rand.Text()
+	o.mu.Lock()
+	o.sessions[id] = session{identity: identity, idToken: rawIDToken, expires: o.Now().Add(o.cfg.SessionTTL)}
+	o.expire()
+	o.mu.Unlock()
+
+	o.logger.Info("OIDC user logged in", "user", identity.Name)
+	o.setCookie(w, sessionCookie, id, "/", o.cfg.SessionTTL)
+	http.Redirect(w, r, l.next, http.StatusFound)
+}
+
+// exchange redeems the authorization code and returns the user of the
+// verified ID token.
+func (o *OIDC) exchange(ctx context.Context, code string, l login) (Identity, string, error) {
+	token, err := o.oauth2.Exchange(ctx, code, oauth2.VerifierOption(l.verifier))
+	if err != nil {
+		return Identity{}, "", err
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return Identity{}, "", errors.New("no id_token in token response")
+	}
+	idToken, err := o.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return Identity{}, "", err
+	}
+	if idToken.Nonce != l.nonce {
+		return Identity{}, "", errors.New("nonce of ID token does not match")
+	}
+
+	var claims map[string]any
+	if err := idToken.Claims(&claims); err != nil {
+		return Identity{}, "", err
+	}
+	identity := Identity{Name: idToken.Subject, Method: MethodOIDC, Groups: stringsClaim(claims[o.cfg.GroupsClaim])}
+	if o.cfg.UsernameClaim != "" {
+		// no fallback to sub, it would share the names of the claim
+		name, ok := claims[o.cfg.UsernameClaim].(string)
+		if !ok || name == "" {
+			return Identity{}, "", fmt.Errorf("no %q claim in ID token", o.cfg.UsernameClaim)
+		}
+		identity.Name = name
+	}
+	return identity, rawIDToken, nil
+}
+
+// allowed reports whether identity is in one of the allowed groups.
+func (o *OIDC) allowed(identity Identity) bool {
+	if len(o.cfg.AllowedGroups) == 0 {
+		return true
+	}
+	return slices.ContainsFunc(identity.Groups, func(group string) bool {
+		return slices.Contains(o.cfg.AllowedGroups, group)
+	})
+}
+
+// logout ends the session and, if supported, the session at the provider.
+func (o *OIDC) logout(w http.ResponseWriter, r *http.Request) {
+	var s session
+	if cookie, err := r.Cookie(sessionCookie); err == nil {
+		o.mu.Lock()
+		s = o.sessions[cookie.Value]
+		delete(o.sessions, cookie.Value)
+		o.mu.Unlock()
+	}
+	o.setCookie(w, sessionCookie, "", "/", -1)
+
+	if o.endSession == "" || s.idToken == "" {
+		http.Redirect(w, r, "/", http.StatusFound)
+		return
+	}
+	u, err := url.Parse(o.endSession)
+	if err != nil {
+		http.Redirect(w, r, "/", http.StatusFound)
+		return
+	}
+	q := u.Query()
+	q.Set("id_token_hint", s.idToken)
+	q.Set("client_id", o.cfg.ClientID)
+	q.Set("post_logout_redirect_uri", strings.TrimSuffix(o.cfg.ExternalURL, "/")+"/")
+	u.RawQuery = q.Encode()
+	http.Redirect(w, r, u.String(), http.StatusFound)
+}
+
+// expire removes expired sessions and login attempts. o.mu must be held.
+func (o *OIDC) expire() {
+	now := o.Now()
+	for id, s := range o.sessions {
+		if !now.Before(s.expires) {
+			delete(o.sessions, id)
+		}
+	}
+	o.expireLogins()
+}
+
+// expireLogins removes expired login attempts. o.mu must be held.
+func (o *OIDC) expireLogins() {
+	now := o.Now()
+	for state, l := range o.logins {
+		if !now.Before(l.expires) {
+			delete(o.logins, state)
+		}
+	}
+}
+
+// setCookie sets an HTTP-only cookie, deleting it if maxAge is negative.
+func (o *OIDC) setCookie(w http.ResponseWriter, name, value, path string, maxAge time.Duration) {
+	cookie := &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     path,
+		MaxAge:   int(maxAge.Seconds()),
+		HttpOnly: true,
+		Secure:   o.secure,
+		SameSite: http.SameSiteLaxMode,
+	}
+	if maxAge < 0 {
+		cookie.MaxAge = -1
+	}
+	http.SetCookie(w, cookie)
+}
+
+// localPath returns next if it is a path on this server, "/" otherwise, so
+// the login cannot be abused to redirect to other sites.
+func localPath(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return "/"
+	}
+	return next
+}
+
+// stringsClaim returns a claim holding a list of strings or a single string.
+func stringsClaim(claim any) []string {
+	switch v := claim.(type) {
+	case string:
+		return []string{v}
+	case []any:
+		out := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
@@ -0,0 +1,379 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/coreos/go-oidc/v3/oidc/oidctest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+// mockProvider is an OpenID Connect provider logging in a fixed user without
+// asking for credentials.
+type mockProvider struct {
+	*httptest.Server
+	key    *rsa.PrivateKey
+	claims map[string]any // claims of the user, iss, aud, exp and nonce are added
+
+	mu        sync.Mutex
+	requests  map[string]url.Values // authorization requests by code
+	loggedOut url.Values            // query of the last logout
+}
+
+// newMockProvider starts a provider logging in the user with claims.
+func newMockProvider(t *testing.T, claims map[string]any) *mockProvider {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	p := &mockProvider{key: key, claims: claims, requests: make(map[string]url.Values)}
+	keys := &oidctest.Server{PublicKeys: []oidctest.PublicKey{{PublicKey: key.Public(), KeyID: "test", Algorithm: oidc.RS256}}}
+	mux := http.NewServeMux()
+	mux.Handle("GET /keys", keys)
+	mux.HandleFunc("GET /.well-known/openid-configuration", p.discovery)
+	mux.HandleFunc("GET /authorize", p.authorize)
+	mux.HandleFunc("POST /token", p.token)
+	mux.HandleFunc("GET /logout", p.logout)
+	p.Server = httptest.NewServer(mux)
+	keys.SetIssuer(p.URL)
+	t.Cleanup(p.Close)
+	return p
+}
+
+func (p *mockProvider) discovery(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"issuer":                                p.URL,
+		"authorization_endpoint":                p.URL + "/authorize",
+		"token_endpoint":                        p.URL + "/token",
+		"jwks_uri":                              p.URL + "/keys",
+		"end_session_endpoint":                  p.URL + "/logout",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{oidc.RS256},
+	})
+}
+
+// authorize redirects back with a code right away.
+func (p *mockProvider) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("response_type") != "code" || q.Get("code_challenge_method") != "S256" {
+		http.Error(w, "invalid authorization request", http.StatusBadRequest)
+		return
+	}
+	code := rand.Text()
+	p.mu.Lock()
+	p.requests[code] = q
+	p.mu.Unlock()
+
+	redirect, _ := url.Parse(q.Get("redirect_uri"))
+	redirect.RawQuery = url.Values{"code": {code}, "state": {q.Get("state")}}.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+// token redeems a code for a signed ID token.
+func (p *mockProvider) token(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	q, ok := p.requests[r.FormValue("code")]
+	delete(p.requests, r.FormValue("code"))
+	p.mu.Unlock()
+	challenge := sha256.Sum256([]byte(r.FormValue("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(challenge[:]) != q.Get("code_challenge") {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = io.WriteString(w, `{"error":"invalid_grant"}`)
+		return
+	}
+
+	claims := maps.Clone(p.claims)
+	claims["iss"] = p.URL
+	claims["aud"] = q.Get("client_id")
+	claims["exp"] = time.Now().Add(time.Hour).Unix()
+	claims["nonce"] = q.Get("nonce")
+	payload, _ := json.Marshal(claims)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"access_token": "access",
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     oidctest.SignIDToken(p.key, "test", oidc.RS256, string(payload)),
+	})
+}
+
+func (p *mockProvider) logout(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	p.loggedOut = r.URL.Query()
+	p.mu.Unlock()
+	_, _ = io.WriteString(w, "logged out")
+}
+
+// newTestApp serves an OIDC-protected handler echoing the user and returns
+// a client keeping cookies like a browser.
+func newTestApp(t *testing.T, provider *mockProvider, cfg OIDCConfig) (*httptest.Server, *http.Client) {
+	t.Helper()
+	var handler http.Handler
+	app := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(app.Close)
+
+	cfg.IssuerURL = provider.URL
+	cfg.ClientID = "snapraid"
+	cfg.ExternalURL = app.URL
+	cfg.SessionTTL = time.Hour
+	o, err := NewOIDC(context.Background(), cfg, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	require.NoError(t, err)
+
+	mux := http.NewServeMux()
+	mux.Handle("/auth/", o)
+	mux.Handle("/", Require([]Authenticator{o}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, _ := FromContext(r.Context())
+		_, _ = fmt.Fprintf(w, "%s %s %v", r.URL.RequestURI(), id.Name, id.Groups)
+	})))
+	handler = mux
+
+	jar, err := cookiejar.New(nil)
+	require.NoError(t, err)
+	return app, &http.Client{Jar: jar}
+}
+
+// get requests path from the app, like a browser if html is set.
+func get(t *testing.T, client *http.Client, rawURL string, html bool) (int, string) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	require.NoError(t, err)
+	if html {
+		req.Header.Set("Accept", "text/html")
+	}
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close() // nolint:errcheck
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp.StatusCode, string(body)
+}
+
+func TestOIDC(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Logs in and out", func(t *testing.T) {
+		t.Parallel()
+
+		provider := newMockProvider(t, map[string]any{
+			"sub":                "1234",
+			"preferred_username": "alice",
+			"email":              "alice@example.com",
+			"groups":             []string{"admins", "family"},
+		})
+		app, client := newTestApp(t, provider, OIDCConfig{AllowedGroups: []string{"family"}})
+
+		// API requests are not redirected
+		code, _ := get(t, client, app.URL+"/api/v1/runs", false)
+		assert.Equal(t, http.StatusUnauthorized, code)
+
+		code, body := get(t, client, app.URL+"/runs?source=media", true)
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "/runs?source=media 1234 [admins family]", body)
+
+		code, body = get(t, client, app.URL+"/api/v1/runs", false)
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "/api/v1/runs 1234 [admins family]", body)
+
+		code, body = get(t, client, app.URL+LogoutPath, true)
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "logged out", body)
+		provider.mu.Lock()
+		assert.NotEmpty(t, provider.loggedOut.Get("id_token_hint"))
+		assert.Equal(t, app.URL+"/", provider.loggedOut.Get("post_logout_redirect_uri"))
+		provider.mu.Unlock()
+
+		code, _ = get(t, client, app.URL+"/api/v1/runs", false)
+		assert.Equal(t, http.StatusUnauthorized, code)
+	})
+
+	t.Run("Rejects users outside the allowed groups", func(t *testing.T) {
+		t.Parallel()
+
+		provider := newMockProvider(t, map[string]any{"sub": "5678", "email": "bob@example.com"})
+		app, client := newTestApp(t, provider, OIDCConfig{AllowedGroups: []string{"family"}})
+
+		code, body := get(t, client, app.URL+"/", true)
+		assert.Equal(t, http.StatusForbidden, code)
+		assert.Equal(t, "user \"5678\" is not in an allowed group\n", body)
+
+		code, _ = get(t, client, app.URL+"/api/v1/runs", false)
+		assert.Equal(t, http.StatusUnauthorized, code)
+	})
+
+	t.Run("Custom groups claim", func(t *testing.T) {
+		t.Parallel()
+
+		provider := newMockProvider(t, map[string]any{"sub": "9012", "roles": "snapraid"})
+		app, client := newTestApp(t, provider, OIDCConfig{GroupsClaim: "roles", AllowedGroups: []string{"snapraid"}})
+
+		code, body := get(t, client, app.URL+"/", true)
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "/ 9012 [snapraid]", body)
+	})
+
+	t.Run("Custom username claim", func(t *testing.T) {
+		t.Parallel()
+
+		provider := newMockProvider(t, map[string]any{"sub": "1234", "preferred_username": "alice"})
+		app, client := newTestApp(t, provider, OIDCConfig{UsernameClaim: "preferred_username"})
+
+		code, body := get(t, client, app.URL+"/", true)
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "/ alice []", body)
+
+		// without the claim, users are not named by sub instead
+		provider = newMockProvider(t, map[string]any{"sub": "5678"})
+		app, client = newTestApp(t, provider, OIDCConfig{UsernameClaim: "preferred_username"})
+
+		code, body = get(t, client, app.URL+"/", true)
+		assert.Equal(t, http.StatusUnauthorized, code)
+		assert.Equal(t, "login failed\n", body)
+	})
+
+	t.Run("Rejects callbacks without login", func(t *testing.T) {
+		t.Parallel()
+
+		provider := newMockProvider(t, map[string]any{"sub": "1234"})
+		app, client := newTestApp(t, provider, OIDCConfig{})
+
+		code, body := get(t, client, app.URL+CallbackPath+"?code=forged&state=forged", true)
+		assert.Equal(t, http.StatusBadRequest, code)
+		assert.Equal(t, "invalid login state, please try again\n", body)
+	})
+
+	t.Run("Provider unreachable", func(t *testing.T) {
+		t.Parallel()
+
+		provider := newMockProvider(t, nil)
+		provider.Close()
+
+		_, err := NewOIDC(context.Background(), OIDCConfig{IssuerURL: provider.URL, ExternalURL: "http://localhost"}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+		assert.Error(t, err)
+	})
+}
+
+func TestOIDC_Authenticate(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	o := &OIDC{
+		sessions: map[string]session{
+			"valid":   {identity: Identity{Name: "alice", Method: MethodOIDC}, expires: now.Add(time.Minute)},
+			"expired": {identity: Identity{Name: "bob", Method: MethodOIDC}, expires: now},
+		},
+		Now: func() time.Time { return now },
+	}
+
+	request := func(id string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.AddCookie(&http.Cookie{Name: sessionCookie, Value: id})
+		return req
+	}
+
+	id, ok := o.Authenticate(request("valid"))
+	assert.True(t, ok)
+	assert.Equal(t, "alice", id.Name)
+
+	_, ok = o.Authenticate(request("expired"))
+	assert.False(t, ok)
+
+	_, ok = o.Authenticate(request("unknown"))
+	assert.False(t, ok)
+
+	_, ok = o.Authenticate(httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.False(t, ok)
+
+	o.expire()
+	assert.Len(t, o.sessions, 1)
+}
+
+func TestOIDC_LoginLimit(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	o := &OIDC{
+		oauth2:   oauth2.Config{Endpoint: oauth2.Endpoint{AuthURL: "https://auth.example.com/authorize"}},
+		sessions: make(map[string]session),
+		logins:   make(map[string]login),
+		Now:      func() time.Time { return now },
+	}
+	for i := range maxLogins {
+		o.logins[fmt.Sprint(i)] = login{expires: now.Add(time.Duration(i%2) * time.Minute)}
+	}
+
+	start := func() int {
+		rec := httptest.NewRecorder()
+		o.login(rec, httptest.NewRequest(http.MethodGet, LoginPath, nil))
+		return rec.Code
+	}
+
+	// expired logins make room for new ones
+	assert.Equal(t, http.StatusFound, start())
+	assert.Len(t, o.logins, maxLogins/2+1)
+
+	for len(o.logins) < maxLogins {
+		require.Equal(t, http.StatusFound, start())
+	}
+	rec := httptest.NewRecorder()
+	o.login(rec, httptest.NewRequest(http.MethodGet, LoginPath, nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, "too many pending logins, please try again later\n", rec.Body.String())
+	assert.Len(t, o.logins, maxLogins)
+}
+
+func TestOIDC_LoginURL(t *testing.T) {
+	t.Parallel()
+
+	req := httptest.NewRequest(http.MethodGet, "/runs?source=media", nil)
+	assert.Equal(t, "/auth/login?next=%2Fruns%3Fsource%3Dmedia", new(OIDC).LoginURL(req))
+}
+
+func TestLocalPath(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]string{
+		"":                          "/",
+		"/":                         "/",
+		"/api/v1/runs?source=media": "/api/v1/runs?source=media",
+		"//evil.example.com":        "/",
+		"/\\evil.example.com":       "/",
+		"https://evil.example.com":  "/",
+		"relative":                  "/",
+	}
+	for next, expected := range tests {
+		assert.Equal(t, expected, localPath(next), next)
+	}
+}
+
+func TestStringsClaim(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, []string{"admins"}, stringsClaim("admins"))
+	assert.Equal(t, []string{"admins", "family"}, stringsClaim([]any{"admins", 42, "family"}))
+	assert.Nil(t, stringsClaim(nil))
+	assert.Nil(t, stringsClaim(42.0))
+}
@@ -0,0 +1,46 @@
+package auth
+
+import (
+	"net/http"
+	"net/netip"
+	"slices"
+)
+
+// Proxy trusts the user name a reverse proxy puts into a request header
+// after authenticating the user itself, e.g. X-Forwarded-User. The header is
+// only trusted on requests from the networks of the proxy, since any other
+// client could set it as well.
+type Proxy struct {
+	header  string
+	trusted []netip.Prefix
+}
+
+// NewProxy returns a Proxy authenticator reading the user name from header
+// on requests from the trusted networks.
+func NewProxy(header string, trusted []netip.Prefix) *Proxy {
+	return &Proxy{header: header, trusted: trusted}
+}
+
+// Authenticate returns the user named by the header if r comes from a
+// trusted network.
+func (p *Proxy) Authenticate(r *http.Request) (Identity, bool) {
+	addr, err := netip.ParseAddrPort(r.RemoteAddr)
+	if err != nil {
+		return Identity{}, false
+	}
+	ip := addr.Addr().Unmap()
+	if !slices.ContainsFunc(p.trusted, func(prefix netip.Prefix) bool { return prefix.Contains(ip) }) {
+		return Identity{}, false
+	}
+
+	user := r.Header.Get(p.header)
+	if user == "" {
+		return Identity{}, false
+	}
+	return Identity{Name: user, Method: MethodProxy}, true
+}
+
+// Challenge returns "", the proxy asks for credentials on its own.
+func (p *Proxy) Challenge() string {
+	return ""
+}
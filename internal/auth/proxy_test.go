@@ -0,0 +1,51 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProxy(t *testing.T) {
+	t.Parallel()
+
+	proxy := NewProxy("X-Forwarded-User", []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("fd00::/8"),
+	})
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		user       string
+		ok         bool
+	}{
+		{name: "trusted IPv4", remoteAddr: "10.1.2.3:40000", user: "alice", ok: true},
+		{name: "trusted IPv6", remoteAddr: "[fd00::1]:40000", user: "alice", ok: true},
+		{name: "IPv4-mapped IPv6", remoteAddr: "[::ffff:10.1.2.3]:40000", user: "alice", ok: true},
+		{name: "untrusted", remoteAddr: "192.168.1.2:40000", user: "alice", ok: false},
+		{name: "no header", remoteAddr: "10.1.2.3:40000", user: "", ok: false},
+		{name: "invalid address", remoteAddr: "pipe", user: "alice", ok: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.user != "" {
+				req.Header.Set("X-Forwarded-User", tt.user)
+			}
+			id, ok := proxy.Authenticate(req)
+			assert.Equal(t, tt.ok, ok)
+			if tt.ok {
+				assert.Equal(t, Identity{Name: tt.user, Method: MethodProxy}, id)
+			}
+		})
+	}
+
+	assert.Empty(t, proxy.Challenge())
+}
@@ -0,0 +1,146 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Role grants permissions to users. Each role includes the permissions of
+// the roles below it.
+type Role string
+
+// Roles, from least to most privileged.
+const (
+	RoleViewer   Role = "viewer"   // reads runs and jobs
+	RoleOperator Role = "operator" // additionally pauses and resumes scheduled jobs and starts actions
+	RoleAdmin    Role = "admin"    // additionally starts actions configured with the admin role
+)
+
+// roleRanks orders the roles by privilege.
+var roleRanks = map[Role]int{RoleViewer: 1, RoleOperator: 2, RoleAdmin: 3}
+
+// ParseRole parses the name of a role.
+func ParseRole(s string) (Role, error) {
+	r := Role(s)
+	if _, ok := roleRanks[r]; !ok {
+		return "", fmt.Errorf("unknown role %q, expected viewer, operator or admin", s)
+	}
+	return r, nil
+}
+
+// Includes reports whether r grants the permissions of other.
+func (r Role) Includes(other Role) bool {
+	return roleRanks[r] >= roleRanks[other]
+}
+
+// Policy assigns roles to authenticated users.
+type Policy struct {
+	Default   Role            // role of users without an assigned role
+	Users     map[string]Role // roles by METHOD:NAME of users, by "token" for the bearer token
+	Groups    map[string]Role // roles by OIDC group
+	HidePaths bool            // whether viewers only see counts instead of file paths
+}
+
+// ParseAssignment parses a role assignment given as METHOD:NAME=ROLE for a
+// user authenticated by basic auth, the reverse proxy or OIDC, as
+// token=ROLE for the bearer token or as @GROUP=ROLE for the members of an
+// OIDC group. Users are qualified by method since the same name may belong
+// to different people, e.g. in the htpasswd file and at the OIDC provider.
+func ParseAssignment(s string) (name string, group bool, role Role, err error) {
+	name, value, ok := strings.Cut(s, "=")
+	if !ok || name == "" || name == "@" {
+		return "", false, "", fmt.Errorf("invalid role assignment %q, expected METHOD:NAME=ROLE, token=ROLE or @GROUP=ROLE", s)
+	}
+	if role, err = ParseRole(value); err != nil {
+		return "", false, "", err
+	}
+	if group = strings.HasPrefix(name, "@"); group {
+		return name[1:], true, role, nil
+	}
+	if name == MethodToken {
+		return name, false, role, nil
+	}
+	method, user, ok := strings.Cut(name, ":")
+	if !ok || user == "" {
+		return "", false, "", fmt.Errorf("invalid role assignment %q, expected METHOD:NAME=ROLE, token=ROLE or @GROUP=ROLE", s)
+	}
+	if method != MethodBasic && method != MethodProxy && method != MethodOIDC {
+		return "", false, "", fmt.Errorf("unknown method %q in role assignment %q, expected basic, proxy or oidc", method, s)
+	}
+	return name, false, role, nil
+}
+
+// Role returns the role of id: the most privileged role assigned to the user
+// or one of its groups, the default role if none is.
+func (p Policy) Role(id Identity) Role {
+	role, ok := p.Users[userKey(id)]
+	for _, group := range id.Groups {
+		if r, found := p.Groups[group]; found && (!ok || !role.Includes(r)) {
+			role, ok = r, true
+		}
+	}
+	if !ok {
+		return p.Default
+	}
+	return role
+}
+
+// userKey returns the key of the user of id in Policy.Users.
+func userKey(id Identity) string {
+	if id.Method == MethodToken {
+		return MethodToken // the token has no user name
+	}
+	return id.Method + ":" + id.Name
+}
+
+// Apply returns a handler passing requests to next with the role of the
+// authenticated user set in its identity.
+func (p Policy) Apply(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if id, ok := FromContext(r.Context()); ok {
+			id.Role = p.Role(id)
+			id.HidePaths = p.HidePaths && !id.Role.Includes(RoleOperator)
+			r = r.WithContext(WithIdentity(r.Context(), id))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Allowed reports whether the user of ctx has role. Without an identity,
+// authentication is disabled and everything is allowed.
+func Allowed(ctx context.Context, role Role) bool {
+	id, ok := FromContext(ctx)
+	return !ok || id.Role.Includes(role)
+}
+
+// PathsHidden reports whether file paths are hidden from the user of ctx.
+func PathsHidden(ctx context.Context) bool {
+	id, ok := FromContext(ctx)
+	return ok && id.HidePaths
+}
+
+// RequireRole returns a handler answering requests of users without role
+// with 403 Forbidden.
+func RequireRole(role Role, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !Allowed(r.Context(), role) {
+			http.Error(w, fmt.Sprintf("forbidden, requires role %s", role), http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequirePaths returns a handler answering requests of users that file paths
+// are hidden from with 403 Forbidden.
+func RequirePaths(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if PathsHidden(r.Context()) {
+			http.Error(w, "forbidden, file paths are hidden from viewers", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,181 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRole(t *testing.T) {
+	t.Parallel()
+
+	for _, s := range []string{"viewer", "operator", "admin"} {
+		role, err := ParseRole(s)
+		require.NoError(t, err)
+		assert.Equal(t, Role(s), role)
+	}
+
+	_, err := ParseRole("root")
+	assert.EqualError(t, err, `unknown role "root", expected viewer, operator or admin`)
+}
+
+func TestRole_Includes(t *testing.T) {
+	t.Parallel()
+
+	assert.True(t, RoleAdmin.Includes(RoleOperator))
+	assert.True(t, RoleOperator.Includes(RoleOperator))
+	assert.True(t, RoleOperator.Includes(RoleViewer))
+	assert.False(t, RoleViewer.Includes(RoleOperator))
+	assert.False(t, Role("").Includes(RoleViewer))
+}
+
+func TestParseAssignment(t *testing.T) {
+	t.Parallel()
+
+	name, group, role, err := ParseAssignment("basic:alice=operator")
+	require.NoError(t, err)
+	assert.Equal(t, "basic:alice", name)
+	assert.False(t, group)
+	assert.Equal(t, RoleOperator, role)
+
+	name, group, role, err = ParseAssignment("oidc:alice@example.com=admin")
+	require.NoError(t, err)
+	assert.Equal(t, "oidc:alice@example.com", name)
+	assert.False(t, group)
+	assert.Equal(t, RoleAdmin, role)
+
+	name, group, role, err = ParseAssignment("token=viewer")
+	require.NoError(t, err)
+	assert.Equal(t, "token", name)
+	assert.False(t, group)
+	assert.Equal(t, RoleViewer, role)
+
+	name, group, role, err = ParseAssignment("@admins=admin")
+	require.NoError(t, err)
+	assert.Equal(t, "admins", name)
+	assert.True(t, group)
+	assert.Equal(t, RoleAdmin, role)
+
+	for _, s := range []string{"alice", "=viewer", "@=viewer", "alice=viewer", "basic:=viewer"} {
+		_, _, _, err := ParseAssignment(s)
+		assert.EqualError(t, err, `invalid role assignment "`+s+`", expected METHOD:NAME=ROLE, token=ROLE or @GROUP=ROLE`)
+	}
+
+	_, _, _, err = ParseAssignment("ldap:alice=viewer")
+	assert.EqualError(t, err, `unknown method "ldap" in role assignment "ldap:alice=viewer", expected basic, proxy or oidc`)
+
+	_, _, _, err = ParseAssignment("basic:alice=root")
+	assert.EqualError(t, err, `unknown role "root", expected viewer, operator or admin`)
+}
+
+func TestPolicy_Role(t *testing.T) {
+	t.Parallel()
+
+	policy := Policy{
+		Default: RoleViewer,
+		Users:   map[string]Role{"basic:alice": RoleAdmin, "oidc:bob": RoleViewer, "token": RoleOperator},
+		Groups:  map[string]Role{"ops": RoleOperator, "family": RoleViewer},
+	}
+
+	tests := []struct {
+		name     string
+		identity Identity
+		expected Role
+	}{
+		{name: "default", identity: Identity{Name: "carol", Method: MethodBasic}, expected: RoleViewer},
+		{name: "user", identity: Identity{Name: "alice", Method: MethodBasic}, expected: RoleAdmin},
+		{name: "user of other method", identity: Identity{Name: "alice", Method: MethodOIDC}, expected: RoleViewer},
+		{name: "token", identity: Identity{Name: "token", Method: MethodToken}, expected: RoleOperator},
+		{name: "group", identity: Identity{Name: "carol", Method: MethodOIDC, Groups: []string{"family", "ops"}}, expected: RoleOperator},
+		{name: "higher group than user", identity: Identity{Name: "bob", Method: MethodOIDC, Groups: []string{"ops"}}, expected: RoleOperator},
+		{name: "lower group than user", identity: Identity{Name: "alice", Method: MethodBasic, Groups: []string{"ops"}}, expected: RoleAdmin},
+		{name: "lower than default", identity: Identity{Name: "dave", Method: MethodOIDC, Groups: []string{"family"}}, expected: RoleViewer},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tt.expected, policy.Role(tt.identity))
+		})
+	}
+}
+
+func TestPolicy_Apply(t *testing.T) {
+	t.Parallel()
+
+	policy := Policy{Default: RoleViewer, Users: map[string]Role{"basic:alice": RoleOperator}, HidePaths: true}
+	var got Identity
+	handler := policy.Apply(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = FromContext(r.Context())
+	}))
+
+	serve := func(id Identity) Identity {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		handler.ServeHTTP(httptest.NewRecorder(), req.WithContext(WithIdentity(req.Context(), id)))
+		return got
+	}
+
+	assert.Equal(t, Identity{Name: "alice", Method: MethodBasic, Role: RoleOperator}, serve(Identity{Name: "alice", Method: MethodBasic}))
+	assert.Equal(t, Identity{Name: "bob", Method: MethodBasic, Role: RoleViewer, HidePaths: true}, serve(Identity{Name: "bob", Method: MethodBasic}))
+
+	// without authentication
+	got = Identity{}
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, Identity{}, got)
+}
+
+func TestRequireRole(t *testing.T) {
+	t.Parallel()
+
+	handler := RequireRole(RoleOperator, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+
+	tests := []struct {
+		name     string
+		identity *Identity
+		code     int
+	}{
+		{name: "without authentication", code: http.StatusOK},
+		{name: "viewer", identity: &Identity{Name: "bob", Role: RoleViewer}, code: http.StatusForbidden},
+		{name: "operator", identity: &Identity{Name: "alice", Role: RoleOperator}, code: http.StatusOK},
+		{name: "admin", identity: &Identity{Name: "carol", Role: RoleAdmin}, code: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest(http.MethodPost, "/", nil)
+			if tt.identity != nil {
+				req = req.WithContext(WithIdentity(req.Context(), *tt.identity))
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.code, rec.Code)
+			if tt.code == http.StatusForbidden {
+				assert.Equal(t, "forbidden, requires role operator\n", rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestRequirePaths(t *testing.T) {
+	t.Parallel()
+
+	handler := RequirePaths(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req.WithContext(WithIdentity(req.Context(), Identity{Name: "bob", Role: RoleViewer, HidePaths: true})))
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Equal(t, "forbidden, file paths are hidden from viewers\n", rec.Body.String())
+}
@@ -0,0 +1,57 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Token authenticates requests carrying a static bearer token, e.g. from
+// Prometheus or scripts using the API.
+type Token struct {
+	digest [sha256.Size]byte // only the digest is kept and compared, so comparisons take constant time
+}
+
+// NewToken returns a Token authenticator accepting token.
+func NewToken(token string) (*Token, error) {
+	if token == "" {
+		return nil, errors.New("token is empty")
+	}
+	return &Token{digest: sha256.Sum256([]byte(token))}, nil
+}
+
+// LoadToken returns a Token authenticator accepting the token stored in the
+// file at path. Surrounding whitespace is ignored.
+func LoadToken(path string) (*Token, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	t, err := NewToken(strings.TrimSpace(string(b)))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return t, nil
+}
+
+// Authenticate checks the "Authorization: Bearer TOKEN" header of r.
+func (t *Token) Authenticate(r *http.Request) (Identity, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return Identity{}, false
+	}
+	digest := sha256.Sum256([]byte(strings.TrimSpace(token)))
+	if subtle.ConstantTimeCompare(digest[:], t.digest[:]) != 1 {
+		return Identity{}, false
+	}
+	return Identity{Name: "token", Method: MethodToken}, true
+}
+
+// Challenge asks API clients for a bearer token.
+func (t *Token) Challenge() string {
+	return fmt.Sprintf("Bearer realm=%q", Realm)
+}
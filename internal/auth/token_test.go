@@ -0,0 +1,84 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadToken(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Trims whitespace", func(t *testing.T) {
+		t.Parallel()
+
+		path := filepath.Join(t.TempDir(), "token")
+		require.NoError(t, os.WriteFile(path, []byte("  secret\n"), 0o600))
+
+		token, err := LoadToken(path)
+		require.NoError(t, err)
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		_, ok := token.Authenticate(req)
+		assert.True(t, ok)
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		t.Parallel()
+
+		path := filepath.Join(t.TempDir(), "token")
+		require.NoError(t, os.WriteFile(path, []byte("\n"), 0o600))
+
+		_, err := LoadToken(path)
+		assert.EqualError(t, err, path+": token is empty")
+	})
+
+	t.Run("Missing file", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := LoadToken(filepath.Join(t.TempDir(), "missing"))
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
+
+func TestToken(t *testing.T) {
+	t.Parallel()
+
+	token, err := NewToken("secret")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		header string
+		ok     bool
+	}{
+		{name: "valid", header: "Bearer secret", ok: true},
+		{name: "case insensitive scheme", header: "bearer secret", ok: true},
+		{name: "wrong token", header: "Bearer guess", ok: false},
+		{name: "wrong scheme", header: "Basic secret", ok: false},
+		{name: "no token", header: "Bearer", ok: false},
+		{name: "no header", header: "", ok: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			id, ok := token.Authenticate(req)
+			assert.Equal(t, tt.ok, ok)
+			if tt.ok {
+				assert.Equal(t, Identity{Name: "token", Method: MethodToken}, id)
+			}
+		})
+	}
+
+	assert.Equal(t, `Bearer realm="go-snapraid-web"`, token.Challenge())
+}
@@ -1,9 +1,19 @@
 package flag
 
 import (
+	"fmt"
 	"net"
+	"net/netip"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
 
+	"github.com/gi8lino/go-snapraid-web/internal/auth"
 	"github.com/gi8lino/go-snapraid-web/internal/logging"
+	"github.com/gi8lino/go-snapraid-web/internal/notify"
+	"github.com/gi8lino/go-snapraid-web/internal/prune"
 
 	"github.com/containeroo/tinyflags"
 )
@@ -18,11 +28,116 @@ func (e *HelpRequested) Error() string {
 	return e.Message
 }
 
+// Source is a named output directory.
+type Source struct {
+	Name string // shown in the source switcher and used in the "source" query parameter
+	Dir  string // directory containing the run files
+}
+
+// DefaultSource is the name of the source served without --source.
+const DefaultSource = "default"
+
+// sourceName restricts source names to characters safe in URLs and metric labels.
+var sourceName = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// ParseSource parses a source given as NAME=DIR.
+func ParseSource(s string) (Source, error) {
+	name, dir, err := parseNamed(s, "source", "NAME=DIR")
+	if err != nil {
+		return Source{}, err
+	}
+	return Source{Name: name, Dir: dir}, nil
+}
+
+// Remote is a named go-snapraid-web instance whose runs are mirrored.
+type Remote struct {
+	Name      string // source name of the mirrored runs
+	URL       string // base URL of the instance
+	TokenFile string // file with a bearer token sent to the instance, empty sends none
+}
+
+// ParseRemote parses a remote given as NAME=URL.
+func ParseRemote(s string) (Remote, error) {
+	name, rawURL, err := parseNamed(s, "remote", "NAME=URL")
+	if err != nil {
+		return Remote{}, err
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return Remote{}, fmt.Errorf("invalid remote URL %q, expected http(s)://HOST[/PATH]", rawURL)
+	}
+	return Remote{Name: name, URL: rawURL}, nil
+}
+
+// parseNamed splits a NAME=VALUE flag value and validates the name.
+func parseNamed(s, kind, format string) (name, value string, err error) {
+	name, value, ok := strings.Cut(s, "=")
+	if !ok || value == "" {
+		return "", "", fmt.Errorf("invalid %s %q, expected %s", kind, s, format)
+	}
+	if !sourceName.MatchString(name) {
+		return "", "", fmt.Errorf("invalid %s name %q, only letters, digits, '_', '.' and '-' are allowed", kind, name)
+	}
+	return name, value, nil
+}
+
 // Options holds the parsed configuration flags.
 type Options struct {
-	LogFormat  logging.LogFormat // log format: json or text
-	ListenAddr string            // address to listen on (e.g., ":8080")
-	OutputDir  string            // directory to read SnapRAID output JSON files
+	LogFormat     logging.LogFormat // log format: json or text
+	ListenAddr    string            // address to listen on (e.g., ":8080")
+	OutputDir     string            // directory to read SnapRAID output JSON files
+	Sources       []Source          // served output directories, the first is the default
+	WatchInterval time.Duration     // interval for polling the output directory for changes
+
+	Remotes        []Remote      // instances whose runs are mirrored and served as sources
+	RemoteCacheDir string        // directory the runs of remotes are cached in, one subdirectory per remote
+	RemoteInterval time.Duration // interval for fetching the runs of remotes
+
+	WarnRemovedCount   int     // flag runs removing at least this many files, 0 disables
+	WarnRemovedPercent float64 // flag runs removing at least this percentage of equal files, 0 disables
+	WarnUpdatedCount   int     // flag runs updating at least this many files, 0 disables
+	WarnUpdatedPercent float64 // flag runs updating at least this percentage of equal files, 0 disables
+
+	Webhooks        []notify.Webhook // webhooks notified about new runs
+	WebhookTemplate string           // file with a custom body template for generic webhooks
+
+	PruneInterval time.Duration // interval of the background prune job, 0 disables
+	Prune         prune.Policy  // retention policy of the background prune job
+	PruneAction   prune.Action  // delete or compress pruned runs
+
+	ScheduleConfig string // file with the jobs of the built-in scheduler, empty disables
+	ActionsConfig  string // file with the commands that can be run from the dashboard, empty disables
+
+	AuthHtpasswd    string         // htpasswd file with bcrypt hashes for basic auth, empty disables
+	AuthTokenFile   string         // file with a static bearer token, empty disables
+	AuthProxyHeader string         // header with the user name set by a trusted reverse proxy, empty disables
+	AuthProxyCIDRs  []netip.Prefix // networks the reverse proxy connects from
+	AuthPolicy      auth.Policy    // roles of authenticated users
+
+	OIDCIssuer           string        // URL of the OpenID Connect provider, empty disables
+	OIDCClientID         string        // client registered at the provider
+	OIDCClientSecretFile string        // file with the client secret, empty for public clients
+	OIDCScopes           []string      // scopes requested in addition to openid, profile and email
+	OIDCUsernameClaim    string        // ID token claim naming users, empty for sub
+	OIDCGroupsClaim      string        // ID token claim listing the groups of a user
+	OIDCAllowedGroups    []string      // groups allowed to log in, empty allows all users
+	OIDCSessionTTL       time.Duration // time until users have to log in again
+	ExternalURL          string        // URL the dashboard is reached at
+
+	TLSCertFile       string        // certificate chain for serving HTTPS, empty serves plain HTTP
+	TLSKeyFile        string        // private key of the certificate
+	TLSClientCAFile   string        // CA bundle client certificates must be signed by, empty disables mTLS
+	TLSRedirectAddr   string        // address redirecting plain HTTP to HTTPS, empty disables
+	TLSReloadInterval time.Duration // interval for checking the TLS files for changes
+}
+
+// PruneOptions holds the parsed flags of the prune subcommand.
+type PruneOptions struct {
+	LogFormat logging.LogFormat // log format: json or text
+	OutputDir string            // directory containing the run files
+	Policy    prune.Policy      // runs to keep
+	Action    prune.Action      // delete or compress pruned runs
+	DryRun    bool              // only report what would be pruned
 }
 
 // ParseFlags parses command-line arguments into Options.
@@ -40,6 +155,160 @@ func ParseFlags(args []string, version string) (Options, error) {
 	tf.StringVar(&opts.OutputDir, "output-dir", "/output", "Output directory for generated files").
 		Short("o").
 		Value()
+	sources := tf.StringSlice("source", nil, "Named output directory, replaces --output-dir (repeatable)").
+		Delimiter(" ").
+		Validate(func(s string) error {
+			_, err := ParseSource(s)
+			return err
+		}).
+		Placeholder("NAME=DIR").
+		Value()
+	tf.DurationVar(&opts.WatchInterval, "watch-interval", 10*time.Second, "Interval for polling the output directory for new runs").
+		Short("w").
+		Placeholder("DURATION").
+		Value()
+	remotes := tf.StringSlice("remote", nil, "Other go-snapraid-web instance whose runs are served as source NAME (repeatable)").
+		Delimiter(" ").
+		Validate(func(s string) error {
+			_, err := ParseRemote(s)
+			return err
+		}).
+		Placeholder("NAME=URL").
+		Value()
+	remoteTokens := tf.StringSlice("remote-token-file", nil, "File with a bearer token for the remote NAME (repeatable)").
+		Delimiter(" ").
+		Validate(func(s string) error {
+			_, _, err := parseNamed(s, "remote token file", "NAME=FILE")
+			return err
+		}).
+		Placeholder("NAME=FILE").
+		Value()
+	tf.StringVar(&opts.RemoteCacheDir, "remote-cache-dir", "/cache", "Directory for caching the runs of remotes").
+		Placeholder("DIR").
+		Value()
+	tf.DurationVar(&opts.RemoteInterval, "remote-interval", time.Minute, "Interval for fetching the runs of remotes").
+		Placeholder("DURATION").
+		Value()
+	tf.IntVar(&opts.WarnRemovedCount, "warn-removed-count", 0, "Flag runs removing at least this many files (0 disables)").
+		Placeholder("COUNT").
+		Value()
+	tf.Float64Var(&opts.WarnRemovedPercent, "warn-removed-percent", 5, "Flag runs removing at least this percentage of equal files (0 disables)").
+		Placeholder("PERCENT").
+		Value()
+	tf.IntVar(&opts.WarnUpdatedCount, "warn-updated-count", 0, "Flag runs updating at least this many files (0 disables)").
+		Placeholder("COUNT").
+		Value()
+	tf.Float64Var(&opts.WarnUpdatedPercent, "warn-updated-percent", 0, "Flag runs updating at least this percentage of equal files (0 disables)").
+		Placeholder("PERCENT").
+		Value()
+	webhooks := tf.StringSlice("webhook", nil, "Webhook notified about new runs, KIND is generic, gotify, ntfy or slack (repeatable)").
+		Delimiter(" ").
+		Validate(func(s string) error {
+			_, err := notify.ParseWebhook(s)
+			return err
+		}).
+		Placeholder("[KIND=]URL").
+		Value()
+	tf.StringVar(&opts.WebhookTemplate, "webhook-template", "", "File with a Go template for the body of generic webhooks").
+		Placeholder("FILE").
+		Value()
+	tf.DurationVar(&opts.PruneInterval, "prune-interval", 0, "Interval for pruning the output directory (0 disables)").
+		Placeholder("DURATION").
+		Value()
+	tf.IntVar(&opts.Prune.KeepLast, "prune-keep-last", 0, "Prune job keeps this many most recent runs").
+		Placeholder("COUNT").
+		Value()
+	tf.IntVar(&opts.Prune.KeepDaily, "prune-keep-daily", 0, "Prune job keeps the newest run per day for this many days").
+		Placeholder("DAYS").
+		Value()
+	tf.IntVar(&opts.Prune.KeepWeekly, "prune-keep-weekly", 0, "Prune job keeps the newest run per week for this many weeks").
+		Placeholder("WEEKS").
+		Value()
+	pruneCompress := tf.String("prune-compress", "", "Prune job compresses runs instead of deleting them").
+		Choices(string(prune.ActionGzip), string(prune.ActionZstd)).
+		Placeholder("FORMAT").
+		Value()
+	tf.StringVar(&opts.ScheduleConfig, "schedule-config", "", "File with go-snapraid jobs to run on cron schedules").
+		Placeholder("FILE").
+		Value()
+	tf.StringVar(&opts.ActionsConfig, "actions-config", "", "File with go-snapraid commands that can be run from the dashboard").
+		Placeholder("FILE").
+		Value()
+	tf.StringVar(&opts.AuthHtpasswd, "auth-htpasswd", "", "File in htpasswd format with bcrypt hashes for basic auth").
+		Placeholder("FILE").
+		Value()
+	tf.StringVar(&opts.AuthTokenFile, "auth-token-file", "", "File with a bearer token accepted for API and metrics requests").
+		Placeholder("FILE").
+		Value()
+	tf.StringVar(&opts.AuthProxyHeader, "auth-proxy-header", "", "Header with the user name set by a trusted reverse proxy, e.g. X-Forwarded-User").
+		Placeholder("HEADER").
+		Value()
+	proxyCIDRs := tf.StringSlice("auth-proxy-cidr", nil, "Network the reverse proxy connects from (repeatable)").
+		Delimiter(" ").
+		Validate(func(s string) error {
+			_, err := netip.ParsePrefix(s)
+			return err
+		}).
+		Placeholder("CIDR").
+		Value()
+	defaultRole := tf.String("auth-default-role", string(auth.RoleViewer), "Role of users without an assigned role").
+		Choices(string(auth.RoleViewer), string(auth.RoleOperator), string(auth.RoleAdmin)).
+		Placeholder("ROLE").
+		Value()
+	roles := tf.StringSlice("auth-role", nil, "Role of a user as METHOD:NAME, of the bearer token as token or of an OIDC group as @GROUP (repeatable)").
+		Delimiter(" ").
+		Validate(func(s string) error {
+			_, _, _, err := auth.ParseAssignment(s)
+			return err
+		}).
+		Placeholder("NAME=ROLE").
+		Value()
+	tf.BoolVar(&opts.AuthPolicy.HidePaths, "auth-hide-paths", false, "Show viewers only counts instead of file paths").
+		Value()
+	tf.StringVar(&opts.OIDCIssuer, "oidc-issuer", "", "URL of an OpenID Connect provider users log in with").
+		Placeholder("URL").
+		Value()
+	tf.StringVar(&opts.OIDCClientID, "oidc-client-id", "", "Client ID registered at the OpenID Connect provider").
+		Placeholder("ID").
+		Value()
+	tf.StringVar(&opts.OIDCClientSecretFile, "oidc-client-secret-file", "", "File with the client secret").
+		Placeholder("FILE").
+		Value()
+	oidcScopes := tf.StringSlice("oidc-scope", nil, "Scope requested in addition to openid, profile and email (repeatable)").
+		Delimiter(" ").
+		Placeholder("SCOPE").
+		Value()
+	tf.StringVar(&opts.OIDCUsernameClaim, "oidc-username-claim", "", "ID token claim naming users instead of sub, e.g. preferred_username").
+		Placeholder("CLAIM").
+		Value()
+	tf.StringVar(&opts.OIDCGroupsClaim, "oidc-groups-claim", auth.DefaultGroupsClaim, "ID token claim listing the groups of a user").
+		Placeholder("CLAIM").
+		Value()
+	oidcGroups := tf.StringSlice("oidc-allowed-group", nil, "Group allowed to log in, all users of the provider if unset (repeatable)").
+		Delimiter(" ").
+		Placeholder("GROUP").
+		Value()
+	tf.DurationVar(&opts.OIDCSessionTTL, "oidc-session-ttl", 24*time.Hour, "Time until users have to log in again").
+		Placeholder("DURATION").
+		Value()
+	tf.StringVar(&opts.ExternalURL, "external-url", "", "URL the dashboard is reached at, required for OpenID Connect").
+		Placeholder("URL").
+		Value()
+	tf.StringVar(&opts.TLSCertFile, "tls-cert", "", "Certificate file for serving HTTPS, reloaded on change").
+		Placeholder("FILE").
+		Value()
+	tf.StringVar(&opts.TLSKeyFile, "tls-key", "", "Private key file of the certificate").
+		Placeholder("FILE").
+		Value()
+	tf.StringVar(&opts.TLSClientCAFile, "tls-client-ca", "", "CA bundle client certificates must be signed by (mTLS)").
+		Placeholder("FILE").
+		Value()
+	tf.StringVar(&opts.TLSRedirectAddr, "tls-redirect-address", "", "Address redirecting plain HTTP to HTTPS (e.g., :80)").
+		Placeholder("ADDR").
+		Value()
+	tf.DurationVar(&opts.TLSReloadInterval, "tls-reload-interval", 10*time.Second, "Interval for checking the TLS files for changes").
+		Placeholder("DURATION").
+		Value()
 	logFormat := tf.String("log-format", "json", "Log format").
 		Choices(string(logging.LogFormatText), string(logging.LogFormatJSON)).
 		Short("l").
@@ -51,6 +320,197 @@ func ParseFlags(args []string, version string) (Options, error) {
 
 	opts.LogFormat = logging.LogFormat(*logFormat)
 	opts.ListenAddr = (*listenAddr).String()
+	if opts.WatchInterval <= 0 {
+		return Options{}, fmt.Errorf("--watch-interval: must be positive")
+	}
+	srcs, err := parseSources(*sources, opts.OutputDir)
+	if err != nil {
+		return Options{}, fmt.Errorf("--source: %w", err)
+	}
+	opts.Sources = srcs
+	names := make(map[string]bool, len(srcs))
+	for _, src := range srcs {
+		names[src.Name] = true
+	}
+	for _, s := range *remotes {
+		r, _ := ParseRemote(s) // validated above
+		if names[r.Name] {
+			return Options{}, fmt.Errorf("--remote: duplicate source name %q", r.Name)
+		}
+		names[r.Name] = true
+		opts.Remotes = append(opts.Remotes, r)
+	}
+	for _, s := range *remoteTokens {
+		name, file, _ := parseNamed(s, "remote token file", "NAME=FILE") // validated above
+		i := slices.IndexFunc(opts.Remotes, func(r Remote) bool { return r.Name == name })
+		if i < 0 {
+			return Options{}, fmt.Errorf("--remote-token-file: unknown remote %q", name)
+		}
+		opts.Remotes[i].TokenFile = file
+	}
+	if len(opts.Remotes) > 0 && opts.RemoteInterval <= 0 {
+		return Options{}, fmt.Errorf("--remote-interval: must be positive")
+	}
+	for _, s := range *webhooks {
+		w, _ := notify.ParseWebhook(s) // validated above
+		opts.Webhooks = append(opts.Webhooks, w)
+	}
+	opts.PruneAction, _ = prune.ParseAction(*pruneCompress) // validated by Choices
+	if opts.PruneInterval > 0 {
+		if err := opts.Prune.Validate(); err != nil {
+			return Options{}, fmt.Errorf("--prune-interval: %w", err)
+		}
+	}
+	for _, s := range *proxyCIDRs {
+		prefix, _ := netip.ParsePrefix(s) // validated above
+		opts.AuthProxyCIDRs = append(opts.AuthProxyCIDRs, prefix.Masked())
+	}
+	if opts.AuthProxyHeader != "" && len(opts.AuthProxyCIDRs) == 0 {
+		return Options{}, fmt.Errorf("--auth-proxy-header: requires --auth-proxy-cidr")
+	}
+	if opts.AuthProxyHeader == "" && len(opts.AuthProxyCIDRs) > 0 {
+		return Options{}, fmt.Errorf("--auth-proxy-cidr: requires --auth-proxy-header")
+	}
+	opts.AuthPolicy.Default, _ = auth.ParseRole(*defaultRole) // validated by Choices
+	opts.AuthPolicy.Users = make(map[string]auth.Role)
+	opts.AuthPolicy.Groups = make(map[string]auth.Role)
+	for _, s := range *roles {
+		name, group, role, _ := auth.ParseAssignment(s) // validated above
+		if group {
+			opts.AuthPolicy.Groups[name] = role
+		} else {
+			opts.AuthPolicy.Users[name] = role
+		}
+	}
+	opts.OIDCScopes = *oidcScopes
+	opts.OIDCAllowedGroups = *oidcGroups
+	if err := validateOIDC(opts); err != nil {
+		return Options{}, err
+	}
+	if err := validateTLS(opts); err != nil {
+		return Options{}, err
+	}
+	// without authentication, anyone reaching the port could run the commands
+	if opts.ActionsConfig != "" && opts.AuthHtpasswd == "" && opts.AuthTokenFile == "" && opts.AuthProxyHeader == "" && opts.OIDCIssuer == "" {
+		return Options{}, fmt.Errorf("--actions-config: requires authentication, refusing to let anyone run commands")
+	}
+
+	return opts, nil
+}
+
+// validateOIDC checks that the flags needed to log in with OpenID Connect
+// are set when it is enabled.
+func validateOIDC(opts Options) error {
+	if opts.OIDCIssuer == "" {
+		if opts.OIDCClientID != "" {
+			return fmt.Errorf("--oidc-client-id: requires --oidc-issuer")
+		}
+		return nil
+	}
+	if u, err := url.Parse(opts.OIDCIssuer); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("--oidc-issuer: invalid URL %q", opts.OIDCIssuer)
+	}
+	if opts.OIDCClientID == "" {
+		return fmt.Errorf("--oidc-issuer: requires --oidc-client-id")
+	}
+	if opts.ExternalURL == "" {
+		return fmt.Errorf("--oidc-issuer: requires --external-url")
+	}
+	if u, err := url.Parse(opts.ExternalURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("--external-url: invalid URL %q", opts.ExternalURL)
+	}
+	if opts.OIDCSessionTTL <= 0 {
+		return fmt.Errorf("--oidc-session-ttl: must be positive")
+	}
+	return nil
+}
+
+// validateTLS checks that the flags for serving HTTPS are complete.
+func validateTLS(opts Options) error {
+	if opts.TLSCertFile == "" {
+		switch {
+		case opts.TLSKeyFile != "":
+			return fmt.Errorf("--tls-key: requires --tls-cert")
+		case opts.TLSClientCAFile != "":
+			return fmt.Errorf("--tls-client-ca: requires --tls-cert")
+		case opts.TLSRedirectAddr != "":
+			return fmt.Errorf("--tls-redirect-address: requires --tls-cert")
+		}
+		return nil
+	}
+	if opts.TLSKeyFile == "" {
+		return fmt.Errorf("--tls-cert: requires --tls-key")
+	}
+	if opts.TLSRedirectAddr != "" {
+		if _, _, err := net.SplitHostPort(opts.TLSRedirectAddr); err != nil {
+			return fmt.Errorf("--tls-redirect-address: invalid address %q", opts.TLSRedirectAddr)
+		}
+	}
+	if opts.TLSReloadInterval <= 0 {
+		return fmt.Errorf("--tls-reload-interval: must be positive")
+	}
+	return nil
+}
+
+// parseSources parses the --source values. Without any, outputDir is served
+// as the default source.
+func parseSources(values []string, outputDir string) ([]Source, error) {
+	if len(values) == 0 {
+		return []Source{{Name: DefaultSource, Dir: outputDir}}, nil
+	}
+
+	sources := make([]Source, 0, len(values))
+	seen := make(map[string]bool, len(values))
+	for _, v := range values {
+		src, _ := ParseSource(v) // validated above
+		if seen[src.Name] {
+			return nil, fmt.Errorf("duplicate source name %q", src.Name)
+		}
+		seen[src.Name] = true
+		sources = append(sources, src)
+	}
+	return sources, nil
+}
+
+// ParsePruneFlags parses the arguments of the prune subcommand into PruneOptions.
+func ParsePruneFlags(args []string, version string) (PruneOptions, error) {
+	opts := PruneOptions{}
+	tf := tinyflags.NewFlagSet("go-snapraid prune", tinyflags.ContinueOnError)
+	tf.Version(version)
+
+	tf.StringVar(&opts.OutputDir, "output-dir", "/output", "Output directory containing the run files").
+		Short("o").
+		Value()
+	tf.IntVar(&opts.Policy.KeepLast, "keep-last", 0, "Keep this many most recent runs").
+		Placeholder("COUNT").
+		Value()
+	tf.IntVar(&opts.Policy.KeepDaily, "keep-daily", 0, "Keep the newest run per day for this many days").
+		Placeholder("DAYS").
+		Value()
+	tf.IntVar(&opts.Policy.KeepWeekly, "keep-weekly", 0, "Keep the newest run per week for this many weeks").
+		Placeholder("WEEKS").
+		Value()
+	compress := tf.String("compress", "", "Compress runs instead of deleting them").
+		Choices(string(prune.ActionGzip), string(prune.ActionZstd)).
+		Placeholder("FORMAT").
+		Value()
+	tf.BoolVar(&opts.DryRun, "dry-run", false, "Only report what would be pruned").
+		Short("n").
+		Value()
+	logFormat := tf.String("log-format", "json", "Log format").
+		Choices(string(logging.LogFormatText), string(logging.LogFormatJSON)).
+		Short("l").
+		Value()
+
+	if err := tf.Parse(args); err != nil {
+		return PruneOptions{}, err
+	}
+
+	opts.LogFormat = logging.LogFormat(*logFormat)
+	opts.Action, _ = prune.ParseAction(*compress) // validated by Choices
+	if err := opts.Policy.Validate(); err != nil {
+		return PruneOptions{}, err
+	}
 
 	return opts, nil
 }
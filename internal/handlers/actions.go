@@ -0,0 +1,249 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gi8lino/go-snapraid-web/internal/action"
+	"github.com/gi8lino/go-snapraid-web/internal/api"
+	"github.com/gi8lino/go-snapraid-web/internal/auth"
+	"github.com/gi8lino/go-snapraid-web/internal/utils"
+)
+
+// ActionSummary is the API representation of an action.
+type ActionSummary struct {
+	Name    string            `json:"name"`
+	Command []string          `json:"command"`
+	Source  string            `json:"source"` // source receiving the run files
+	Role    string            `json:"role"`   // role required to start the action
+	Running bool              `json:"running"`
+	Last    *ExecutionSummary `json:"last,omitempty"` // latest execution, omitted if the action never ran
+}
+
+// ExecutionSummary is the API representation of an execution of an action.
+type ExecutionSummary struct {
+	Start  time.Time `json:"start"`
+	End    time.Time `json:"end,omitzero"`  // omitted while running
+	Status string    `json:"status"`        // running, success or failed
+	Run    string    `json:"run,omitempty"` // ID of the run file produced, omitted if none appeared
+	Error  string    `json:"error,omitempty"`
+}
+
+// OutputLine is the payload of an "output" event of an action.
+type OutputLine struct {
+	Stream string `json:"stream"` // stdout or stderr
+	Text   string `json:"text"`
+}
+
+// newActionSummary converts the state of an action into its API representation.
+func newActionSummary(a action.ActionState) ActionSummary {
+	s := ActionSummary{
+		Name:    a.Name,
+		Command: api.NonNil(a.Command),
+		Source:  a.Source,
+		Role:    string(a.Role),
+		Running: a.Running,
+	}
+	if a.Last != nil {
+		last := newExecutionSummary(*a.Last)
+		s.Last = &last
+	}
+	return s
+}
+
+// newExecutionSummary converts an execution into its API representation.
+func newExecutionSummary(e action.Execution) ExecutionSummary {
+	return ExecutionSummary{
+		Start:  e.Start,
+		End:    e.End,
+		Status: string(e.Status),
+		Run:    e.RunID,
+		Error:  e.Error,
+	}
+}
+
+// redactAction removes the command arguments of a for users that file paths
+// are hidden from, since arguments may point at directories.
+func redactAction(r *http.Request, a action.ActionState) action.ActionState {
+	if auth.PathsHidden(r.Context()) {
+		a.Command = a.Command[:min(len(a.Command), 1)]
+	}
+	return a
+}
+
+// ActionsAPI returns an HTTP handler listing all actions as JSON, in
+// configured order. Command arguments are left out for users that file
+// paths are hidden from.
+func ActionsAPI(runner *action.Runner) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		actions := runner.Actions()
+		out := make([]ActionSummary, 0, len(actions))
+		for _, a := range actions {
+			out = append(out, newActionSummary(redactAction(r, a)))
+		}
+
+		writeJSON(w, http.StatusOK, struct {
+			Actions []ActionSummary `json:"actions"`
+		}{
+			Actions: out,
+		})
+	}
+}
+
+// ActionAPI returns an HTTP handler rendering the action selected by the
+// {action} path value as JSON.
+func ActionAPI(runner *action.Runner) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		a, ok := runner.Action(r.PathValue("action"))
+		if !ok {
+			writeJSONError(w, http.StatusNotFound, fmt.Sprintf("action %q not found", r.PathValue("action")))
+			return
+		}
+		writeJSON(w, http.StatusOK, newActionSummary(redactAction(r, a)))
+	}
+}
+
+// StartActionAPI returns an HTTP handler starting the command of the action
+// selected by the {action} path value. It responds with 202 Accepted and
+// the new state of the action, with 403 Forbidden if the user lacks the
+// role of the action or with 409 Conflict if another command writes into
+// the source of the action.
+func StartActionAPI(runner *action.Runner) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("action")
+		if a, ok := runner.Action(name); ok && !auth.Allowed(r.Context(), a.Role) {
+			http.Error(w, fmt.Sprintf("forbidden, requires role %s", a.Role), http.StatusForbidden)
+			return
+		}
+		a, err := runner.Start(name)
+		switch {
+		case errors.Is(err, action.ErrNotFound):
+			writeJSONError(w, http.StatusNotFound, fmt.Sprintf("action %q not found", name))
+		case errors.Is(err, action.ErrBusy):
+			writeJSONError(w, http.StatusConflict, fmt.Sprintf("action %q: another command is running in its source", name))
+		case err != nil:
+			writeJSONError(w, http.StatusServiceUnavailable, err.Error())
+		default:
+			writeJSON(w, http.StatusAccepted, newActionSummary(redactAction(r, a)))
+		}
+	}
+}
+
+// ActionOutput returns an HTTP handler streaming the output of the latest
+// execution of the action selected by the {action} path value as
+// Server-Sent Events. The lines written so far are sent first, each as an
+// "output" event with a JSON payload like {"stream":"stdout","text":"..."}.
+// Once the command exited, a "done" event with the execution is sent and
+// the stream ends.
+func ActionOutput(runner *action.Runner, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("action")
+		output, ok := runner.Output(name)
+		if !ok {
+			writeJSONError(w, http.StatusNotFound, fmt.Sprintf("action %q has no output", name))
+			return
+		}
+
+		rc := http.NewResponseController(w)
+		// the stream is long-lived, so lift the server-wide write timeout
+		if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+			logger.Debug("disable write deadline for action output", "error", err)
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Accel-Buffering", "no") // disable buffering in nginx
+		w.WriteHeader(http.StatusOK)
+
+		ticker := time.NewTicker(eventsKeepAlive)
+		defer ticker.Stop()
+
+		next := 0
+		for {
+			lines, n, changed, done := output.Read(next)
+			next = n
+			for _, l := range lines {
+				data, err := json.Marshal(OutputLine{Stream: string(l.Stream), Text: l.Text})
+				if err != nil {
+					logger.Error("encode action output", "error", err)
+					continue
+				}
+				if _, err := fmt.Fprintf(w, "event: output\ndata: %s\n\n", data); err != nil {
+					return
+				}
+			}
+
+			if done {
+				a, _ := runner.Action(name)
+				if a.Last == nil {
+					return
+				}
+				data, err := json.Marshal(newExecutionSummary(*a.Last))
+				if err != nil {
+					logger.Error("encode action execution", "error", err)
+					return
+				}
+				_, _ = fmt.Fprintf(w, "event: done\ndata: %s\n\n", data)
+				_ = rc.Flush()
+				return
+			}
+
+			if err := rc.Flush(); err != nil {
+				logger.Error("action output stream not supported", "error", err)
+				return
+			}
+
+			select {
+			case <-r.Context().Done():
+				return
+
+			case <-ticker.C:
+				if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+					return
+				}
+
+			case <-changed:
+			}
+		}
+	}
+}
+
+// ActionsPartial returns an HTTP handler rendering the actions with their
+// latest execution. Only users with the role of an action get a button to
+// start it, and the output is only offered to users that file paths are not
+// hidden from.
+func ActionsPartial(webFS fs.FS, runner *action.Runner, logger *slog.Logger) http.HandlerFunc {
+	tmpl := template.Must(
+		template.New("actions").
+			Funcs(utils.FuncMap()).
+			ParseFS(webFS, "web/templates/actions.html", "web/templates/schedule.html"),
+	)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		actions := runner.Actions()
+		startable := make(map[string]bool, len(actions))
+		for i, a := range actions {
+			actions[i] = redactAction(r, a)
+			startable[a.Name] = auth.Allowed(r.Context(), a.Role)
+		}
+		data := struct {
+			Actions   []action.ActionState
+			Startable map[string]bool // whether the user may start the action, by name
+			Output    bool            // whether the user may see the output
+		}{
+			Actions:   actions,
+			Startable: startable,
+			Output:    !auth.PathsHidden(r.Context()),
+		}
+		if err := tmpl.ExecuteTemplate(w, "actions", data); err != nil {
+			logger.Error("render actions partial", "error", err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/gi8lino/go-snapraid-web/internal/action"
+	"github.com/gi8lino/go-snapraid-web/internal/auth"
+	"github.com/gi8lino/go-snapraid-web/internal/runindex"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedactAction(t *testing.T) {
+	t.Parallel()
+
+	a := action.ActionState{
+		ActionConfig: action.ActionConfig{Name: "sync", Command: []string{"go-snapraid", "--config", "/etc/go-snapraid.yml"}},
+	}
+
+	t.Run("Viewer with hidden paths", func(t *testing.T) {
+		t.Parallel()
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req = req.WithContext(auth.WithIdentity(req.Context(), auth.Identity{Name: "bob", Role: auth.RoleViewer, HidePaths: true}))
+		assert.Equal(t, []string{"go-snapraid"}, redactAction(req, a).Command)
+	})
+
+	t.Run("Operator", func(t *testing.T) {
+		t.Parallel()
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req = req.WithContext(auth.WithIdentity(req.Context(), auth.Identity{Name: "alice", Role: auth.RoleOperator}))
+		assert.Equal(t, a, redactAction(req, a))
+	})
+}
+
+func TestActions(t *testing.T) {
+	t.Parallel()
+
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+
+	// newRunner returns a running Runner with a sync action that prints a
+	// line, waits until the file wait exists and writes a run file.
+	newRunner := func(t *testing.T, wait string) *action.Runner {
+		t.Helper()
+		dir := t.TempDir()
+		sources := runindex.Sources{{Name: "media", Index: loadIndex(t, dir, logger)}}
+		script := `echo "Syncing..."; while [ ! -e "$1" ]; do sleep 0.01; done; printf '{}' > "$0/2025-06-01T03:00:00Z.json"; echo "Nothing to do" >&2`
+		runner, err := action.New(action.Config{Actions: []action.ActionConfig{
+			{Name: "sync", Command: []string{"sh", "-c", script, dir, wait}},
+		}}, sources, logger)
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			runner.Run(ctx)
+		}()
+		t.Cleanup(func() {
+			cancel()
+			<-done
+		})
+		return runner
+	}
+
+	// newMux serves the action routes of runner.
+	newMux := func(runner *action.Runner) *http.ServeMux {
+		mux := http.NewServeMux()
+		mux.Handle("GET /api/v1/actions", ActionsAPI(runner))
+		mux.Handle("GET /api/v1/actions/{action}", ActionAPI(runner))
+		mux.Handle("GET /api/v1/actions/{action}/output", ActionOutput(runner, logger))
+		mux.Handle("POST /api/v1/actions/{action}/run", StartActionAPI(runner))
+		return mux
+	}
+
+	// start starts the sync action once the runner accepts actions.
+	start := func(t *testing.T, mux *http.ServeMux) *httptest.ResponseRecorder {
+		t.Helper()
+		var rec *httptest.ResponseRecorder
+		require.Eventually(t, func() bool {
+			rec = httptest.NewRecorder()
+			mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/actions/sync/run", nil))
+			return rec.Code != http.StatusServiceUnavailable
+		}, time.Second, time.Millisecond)
+		return rec
+	}
+
+	t.Run("API", func(t *testing.T) {
+		t.Parallel()
+
+		rec := httptest.NewRecorder()
+		newMux(newRunner(t, "")).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/actions", nil))
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"name":"sync","command":["sh","-c",`)
+		assert.Contains(t, rec.Body.String(), `"source":"media","role":"operator","running":false}]}`)
+
+		rec = httptest.NewRecorder()
+		newMux(newRunner(t, "")).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/actions/scrub", nil))
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.JSONEq(t, `{"error":"action \"scrub\" not found"}`, rec.Body.String())
+	})
+
+	t.Run("API hides command from viewers", func(t *testing.T) {
+		t.Parallel()
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/actions/sync", nil)
+		req = req.WithContext(auth.WithIdentity(req.Context(), auth.Identity{Name: "bob", Role: auth.RoleViewer, HidePaths: true}))
+		rec := httptest.NewRecorder()
+		newMux(newRunner(t, "")).ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"command":["sh"]`)
+	})
+
+	t.Run("Run and stream the output", func(t *testing.T) {
+		t.Parallel()
+
+		wait := filepath.Join(t.TempDir(), "continue")
+		mux := newMux(newRunner(t, wait))
+
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/actions/sync/output", nil))
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.JSONEq(t, `{"error":"action \"sync\" has no output"}`, rec.Body.String())
+
+		rec = start(t, mux)
+		assert.Equal(t, http.StatusAccepted, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"running":true`)
+		assert.Contains(t, rec.Body.String(), `"status":"running"`)
+
+		rec = httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/actions/sync/run", nil))
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		assert.JSONEq(t, `{"error":"action \"sync\": another command is running in its source"}`, rec.Body.String())
+
+		srv := httptest.NewServer(mux)
+		defer srv.Close()
+		resp, err := http.Get(srv.URL + "/api/v1/actions/sync/output")
+		require.NoError(t, err)
+		defer resp.Body.Close() // nolint:errcheck
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+		reader := bufio.NewReader(resp.Body)
+		readEvent := func() string {
+			t.Helper()
+			var b strings.Builder
+			for {
+				line, err := reader.ReadString('\n')
+				require.NoError(t, err)
+				if line == "\n" {
+					return b.String()
+				}
+				b.WriteString(line)
+			}
+		}
+		assert.Equal(t, "event: output\ndata: {\"stream\":\"stdout\",\"text\":\"Syncing...\"}\n", readEvent())
+
+		require.NoError(t, os.WriteFile(wait, nil, 0o600))
+		assert.Equal(t, "event: output\ndata: {\"stream\":\"stderr\",\"text\":\"Nothing to do\"}\n", readEvent())
+		done := readEvent()
+		assert.True(t, strings.HasPrefix(done, "event: done\ndata: {"), done)
+		assert.Contains(t, done, `"status":"success","run":"2025-06-01T03:00:00Z"`)
+		_, err = reader.ReadString('\n')
+		assert.ErrorIs(t, err, io.EOF, "stream ends")
+	})
+
+	t.Run("Start requires the role of the action", func(t *testing.T) {
+		t.Parallel()
+
+		sources := runindex.Sources{{Name: "media", Index: loadIndex(t, t.TempDir(), logger)}}
+		runner, err := action.New(action.Config{Actions: []action.ActionConfig{
+			{Name: "fix", Command: []string{"true"}, Role: auth.RoleAdmin},
+		}}, sources, logger)
+		require.NoError(t, err)
+
+		start := func(role auth.Role) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/actions/fix/run", nil)
+			req = req.WithContext(auth.WithIdentity(req.Context(), auth.Identity{Name: "alice", Role: role}))
+			rec := httptest.NewRecorder()
+			newMux(runner).ServeHTTP(rec, req)
+			return rec
+		}
+
+		rec := start(auth.RoleOperator)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		assert.Equal(t, "forbidden, requires role admin\n", rec.Body.String())
+
+		// the runner is not running, so admins get as far as starting it
+		assert.Equal(t, http.StatusServiceUnavailable, start(auth.RoleAdmin).Code)
+	})
+
+	t.Run("Partial", func(t *testing.T) {
+		t.Parallel()
+
+		webFS := fstest.MapFS{
+			"web/templates/actions.html":  &fstest.MapFile{Data: []byte(`{{define "actions"}}{{range .Actions}}{{.Name}}:{{.Source}}:{{.Running}} start={{index $.Startable .Name}}{{end}} output={{.Output}}{{end}}`)},
+			"web/templates/schedule.html": &fstest.MapFile{Data: []byte(`{{define "schedule"}}{{end}}`)},
+		}
+		handler := ActionsPartial(webFS, newRunner(t, ""), logger)
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/partials/actions", nil))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "sync:media:false start=true output=true", rec.Body.String())
+
+		// viewers cannot start actions nor see their output
+		req := httptest.NewRequest(http.MethodGet, "/partials/actions", nil)
+		req = req.WithContext(auth.WithIdentity(req.Context(), auth.Identity{Name: "bob", Role: auth.RoleViewer, HidePaths: true}))
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		assert.Equal(t, "sync:media:false start=false output=false", rec.Body.String())
+	})
+}
@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/gi8lino/go-snapraid-web/internal/api"
+	"github.com/gi8lino/go-snapraid-web/internal/auth"
+	"github.com/gi8lino/go-snapraid-web/internal/runindex"
+)
+
+// RunsAPI returns an HTTP handler listing all runs as JSON, newest first.
+// The optional "status" query parameter filters by a comma-separated list of statuses.
+func RunsAPI(index *runindex.Index) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filter, err := parseStatusFilter(r.URL.Query().Get("status"))
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		summaries := filter.apply(index.List())
+
+		runs := make([]api.RunSummary, 0, len(summaries))
+		for _, s := range summaries {
+			runs = append(runs, api.NewRunSummary(s))
+		}
+
+		writeJSON(w, http.StatusOK, struct {
+			Runs []api.RunSummary `json:"runs"`
+		}{
+			Runs: runs,
+		})
+	}
+}
+
+// RunAPI returns an HTTP handler rendering a single run as JSON.
+// The run is selected by the {id} path value; "latest" selects the most recent run.
+func RunAPI(index *runindex.Index, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		runID := r.PathValue("id")
+		if runID == "latest" {
+			latest, ok := index.Latest()
+			if !ok {
+				writeJSONError(w, http.StatusNotFound, "no runs found")
+				return
+			}
+			runID = latest.ID
+		}
+
+		summary, ok := index.Get(runID)
+		if !ok {
+			writeJSONError(w, http.StatusNotFound, fmt.Sprintf("run %q not found", runID))
+			return
+		}
+		if auth.PathsHidden(r.Context()) {
+			writeJSON(w, http.StatusOK, api.RunDetail{RunSummary: api.NewRunSummary(summary)})
+			return
+		}
+		run, err := index.Load(runID)
+		if err != nil {
+			if errors.Is(err, runindex.ErrNotFound) {
+				writeJSONError(w, http.StatusNotFound, fmt.Sprintf("run %q not found", runID))
+				return
+			}
+			logger.Error("load run", "id", runID, "error", err)
+			writeJSONError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+
+		writeJSON(w, http.StatusOK, api.RunDetail{
+			RunSummary: api.NewRunSummary(summary),
+			Files:      api.NewRunFiles(run.Result),
+			RawFiles:   api.NewRunFiles(run.Raw),
+		})
+	}
+}
+
+// writeJSON encodes v as the JSON response body with the given status code.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// writeJSONError writes a JSON error object with the given status code.
+func writeJSONError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, struct {
+		Error string `json:"error"`
+	}{
+		Error: msg,
+	})
+}
@@ -0,0 +1,243 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gi8lino/go-snapraid-web/internal/api"
+	"github.com/gi8lino/go-snapraid-web/internal/auth"
+	"github.com/gi8lino/go-snapraid-web/internal/runindex"
+	"github.com/gi8lino/go-snapraid/pkg/snapraid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunsAPI(t *testing.T) {
+	t.Parallel()
+
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+
+	t.Run("Lists runs newest first", func(t *testing.T) {
+		t.Parallel()
+
+		tmp := t.TempDir()
+		writeRunFile(t, tmp, "2025-06-01T03:00:00Z", snapraid.RunResult{
+			Timestamp: "2025-06-01T03:00:00Z",
+			Result:    snapraid.DiffResult{Equal: 10, Added: []string{"a"}},
+			Timings:   snapraid.RunTimings{Diff: 2 * time.Second, Total: 3 * time.Second},
+		})
+		writeRunFile(t, tmp, "2025-06-02T03:00:00Z", snapraid.RunResult{
+			Timestamp: "2025-06-02T03:00:00Z",
+			Result:    snapraid.DiffResult{Removed: []string{"b", "c"}},
+		})
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/runs", nil)
+		rec := httptest.NewRecorder()
+		RunsAPI(loadIndex(t, tmp, logger)).ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+		var body struct {
+			Runs []api.RunSummary `json:"runs"`
+		}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		require.Len(t, body.Runs, 2)
+		assert.Equal(t, "2025-06-02T03:00:00Z", body.Runs[0].ID)
+		assert.Equal(t, 2, body.Runs[0].Counts.Removed)
+		assert.Equal(t, 2, body.Runs[0].Counts.Total)
+		assert.Equal(t, "2025-06-01T03:00:00Z", body.Runs[1].ID)
+		assert.Equal(t, 10, body.Runs[1].Counts.Equal)
+		assert.Equal(t, 1, body.Runs[1].Counts.Added)
+		assert.Equal(t, 2.0, body.Runs[1].Timings.Diff)
+		assert.Equal(t, 3.0, body.Runs[1].Timings.Total)
+	})
+
+	t.Run("Filters by status", func(t *testing.T) {
+		t.Parallel()
+
+		tmp := t.TempDir()
+		writeRunFile(t, tmp, "2025-06-01T03:00:00Z", snapraid.RunResult{})
+		require.NoError(t, os.WriteFile(
+			filepath.Join(tmp, "2025-06-02T03:00:00Z.json"),
+			[]byte(`{"timestamp":"2025-06-02T03:00:00Z","error":"diff failed"}`),
+			0o600,
+		))
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/runs?status=failed,partial", nil)
+		rec := httptest.NewRecorder()
+		RunsAPI(loadIndex(t, tmp, logger)).ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var body struct {
+			Runs []api.RunSummary `json:"runs"`
+		}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		require.Len(t, body.Runs, 1)
+		assert.Equal(t, "2025-06-02T03:00:00Z", body.Runs[0].ID)
+		assert.Equal(t, runindex.StatusFailed, body.Runs[0].Status)
+		assert.Equal(t, "diff failed", body.Runs[0].Error)
+	})
+
+	t.Run("Invalid status filter", func(t *testing.T) {
+		t.Parallel()
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/runs?status=broken", nil)
+		rec := httptest.NewRecorder()
+		RunsAPI(loadIndex(t, t.TempDir(), logger)).ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.JSONEq(t, `{"error":"invalid status \"broken\""}`, rec.Body.String())
+	})
+
+	t.Run("Empty directory", func(t *testing.T) {
+		t.Parallel()
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/runs", nil)
+		rec := httptest.NewRecorder()
+		RunsAPI(loadIndex(t, t.TempDir(), logger)).ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"runs":[]}`, rec.Body.String())
+	})
+}
+
+func TestRunAPI(t *testing.T) {
+	t.Parallel()
+
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+
+	tmp := t.TempDir()
+	writeRunFile(t, tmp, "2025-06-01T03:00:00Z", snapraid.RunResult{
+		Timestamp: "2025-06-01T03:00:00Z",
+		Result:    snapraid.DiffResult{Added: []string{"a"}},
+	})
+	writeRunFile(t, tmp, "2025-06-02T03:00:00Z", snapraid.RunResult{
+		Timestamp: "2025-06-02T03:00:00Z",
+		Result:    snapraid.DiffResult{Removed: []string{`b\ \(1\)`}},
+	})
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /api/v1/runs/{id}", RunAPI(loadIndex(t, tmp, logger), logger))
+
+	t.Run("Returns run with files", func(t *testing.T) {
+		t.Parallel()
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/runs/2025-06-01T03:00:00Z", nil)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var run api.RunDetail
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &run))
+		assert.Equal(t, "2025-06-01T03:00:00Z", run.ID)
+		assert.Equal(t, []string{"a"}, run.Files.Added)
+		assert.Equal(t, []string{}, run.Files.Removed)
+		assert.Equal(t, []runindex.Anomaly{}, run.Anomalies)
+	})
+
+	t.Run("Latest run", func(t *testing.T) {
+		t.Parallel()
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/runs/latest", nil)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var run api.RunDetail
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &run))
+		assert.Equal(t, "2025-06-02T03:00:00Z", run.ID)
+		assert.Equal(t, []string{"b (1)"}, run.Files.Removed)
+		assert.Equal(t, []string{`b\ \(1\)`}, run.RawFiles.Removed)
+	})
+
+	t.Run("Hides files from viewers", func(t *testing.T) {
+		t.Parallel()
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/runs/2025-06-01T03:00:00Z", nil)
+		req = req.WithContext(auth.WithIdentity(req.Context(), auth.Identity{Name: "bob", Role: auth.RoleViewer, HidePaths: true}))
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.NotContains(t, rec.Body.String(), `"files"`)
+		assert.NotContains(t, rec.Body.String(), `"raw_files"`)
+
+		var run api.RunDetail
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &run))
+		assert.Equal(t, "2025-06-01T03:00:00Z", run.ID)
+		assert.Equal(t, 1, run.Counts.Added)
+	})
+
+	t.Run("Run not found", func(t *testing.T) {
+		t.Parallel()
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/runs/2020-01-01T00:00:00Z", nil)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.JSONEq(t, `{"error":"run \"2020-01-01T00:00:00Z\" not found"}`, rec.Body.String())
+	})
+
+	t.Run("Includes anomalies", func(t *testing.T) {
+		t.Parallel()
+
+		dir := t.TempDir()
+		writeRunFile(t, dir, "2025-06-01T03:00:00Z", snapraid.RunResult{
+			Result: snapraid.DiffResult{Equal: 10, Removed: []string{"a", "b", "c"}},
+		})
+		index := runindex.New(dir, runindex.Thresholds{runindex.CategoryRemoved: {Percent: 20}}, logger)
+		require.NoError(t, index.Refresh())
+
+		mux := http.NewServeMux()
+		mux.Handle("GET /api/v1/runs/{id}", RunAPI(index, logger))
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/runs/latest", nil)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var run api.RunDetail
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &run))
+		assert.Equal(t, []runindex.Anomaly{{
+			Category: runindex.CategoryRemoved,
+			Count:    3,
+			Percent:  30,
+			Reason:   "3 removed files (30.0% of equal) reach the threshold of 20.0%",
+		}}, run.Anomalies)
+	})
+
+	t.Run("Invalid run ID", func(t *testing.T) {
+		t.Parallel()
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/runs/..%2F..%2Fetc%2Fpasswd", nil)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
+
+func loadIndex(t *testing.T, dir string, logger *slog.Logger) *runindex.Index {
+	t.Helper()
+	index := runindex.New(dir, nil, logger)
+	require.NoError(t, index.Refresh())
+	return index
+}
+
+func writeRunFile(t *testing.T, dir, id string, run snapraid.RunResult) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, id+".json"), encodeJSON(t, run), 0o600))
+}
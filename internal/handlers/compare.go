@@ -0,0 +1,258 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/gi8lino/go-snapraid-web/internal/api"
+	"github.com/gi8lino/go-snapraid-web/internal/runindex"
+)
+
+// CategoryComparison lists the files of one category that only one of two
+// compared runs contains.
+type CategoryComparison struct {
+	Category runindex.Category `json:"category"`
+	OnlyFrom []string          `json:"only_from"` // files in the category of the older run only
+	OnlyTo   []string          `json:"only_to"`   // files in the category of the newer run only
+}
+
+// ChurnFile is a file that appears in both compared runs under different
+// categories, e.g. added by one run and removed again by the next.
+type ChurnFile struct {
+	Path string              `json:"path"`
+	From []runindex.Category `json:"from"` // categories of the file in the older run
+	To   []runindex.Category `json:"to"`   // categories of the file in the newer run
+}
+
+// RunComparison is the difference between two runs.
+type RunComparison struct {
+	From         api.RunSummary       `json:"from"`
+	To           api.RunSummary       `json:"to"`
+	CountsDelta  api.RunCounts        `json:"counts_delta"`  // counts of To minus counts of From
+	TimingsDelta api.RunTimings       `json:"timings_delta"` // timings of To minus timings of From
+	Files        []CategoryComparison `json:"files"`
+	Churn        []ChurnFile          `json:"churn"`
+}
+
+// CompareAPI returns an HTTP handler comparing two runs as JSON. The runs are
+// selected by the "from" and "to" query parameters; see resolveCompareIDs.
+func CompareAPI(index *runindex.Index, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		fromID, toID, err := resolveCompareIDs(index, r.URL.Query().Get("from"), r.URL.Query().Get("to"))
+		if err == nil {
+			var cmp RunComparison
+			if cmp, err = compareRuns(index, fromID, toID); err == nil {
+				writeJSON(w, http.StatusOK, cmp)
+				return
+			}
+		}
+
+		if errors.As(err, new(*notFoundError)) {
+			writeJSONError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		logger.Error("compare runs", "from", fromID, "to", toID, "error", err)
+		writeJSONError(w, http.StatusInternalServerError, "internal error")
+	}
+}
+
+// resolveCompareIDs resolves the runs to compare. An empty or "latest" toID
+// selects the most recent run; an empty fromID selects the run before toID.
+func resolveCompareIDs(index *runindex.Index, fromID, toID string) (string, string, error) {
+	if toID == "" || toID == "latest" {
+		latest, ok := index.Latest()
+		if !ok {
+			return "", "", &notFoundError{"no runs found"}
+		}
+		toID = latest.ID
+	}
+	if fromID == "" {
+		ids := index.IDs()
+		i := slices.Index(ids, toID)
+		if i < 1 {
+			return "", "", &notFoundError{fmt.Sprintf("no run before %q", toID)}
+		}
+		fromID = ids[i-1]
+	}
+	return fromID, toID, nil
+}
+
+// compareRuns loads two runs and computes their difference.
+func compareRuns(index *runindex.Index, fromID, toID string) (RunComparison, error) {
+	fromSummary, from, err := loadRun(index, fromID)
+	if err != nil {
+		return RunComparison{}, err
+	}
+	toSummary, to, err := loadRun(index, toID)
+	if err != nil {
+		return RunComparison{}, err
+	}
+
+	cmp := RunComparison{
+		From:  api.NewRunSummary(fromSummary),
+		To:    api.NewRunSummary(toSummary),
+		Files: make([]CategoryComparison, 0, len(runindex.Categories)),
+		Churn: churn(from, to),
+	}
+	cmp.CountsDelta = api.RunCounts{
+		Equal:    cmp.To.Counts.Equal - cmp.From.Counts.Equal,
+		Added:    cmp.To.Counts.Added - cmp.From.Counts.Added,
+		Removed:  cmp.To.Counts.Removed - cmp.From.Counts.Removed,
+		Updated:  cmp.To.Counts.Updated - cmp.From.Counts.Updated,
+		Moved:    cmp.To.Counts.Moved - cmp.From.Counts.Moved,
+		Copied:   cmp.To.Counts.Copied - cmp.From.Counts.Copied,
+		Restored: cmp.To.Counts.Restored - cmp.From.Counts.Restored,
+		Total:    cmp.To.Counts.Total - cmp.From.Counts.Total,
+	}
+	cmp.TimingsDelta = api.RunTimings{
+		Touch: cmp.To.Timings.Touch - cmp.From.Timings.Touch,
+		Diff:  cmp.To.Timings.Diff - cmp.From.Timings.Diff,
+		Sync:  cmp.To.Timings.Sync - cmp.From.Timings.Sync,
+		Scrub: cmp.To.Timings.Scrub - cmp.From.Timings.Scrub,
+		Smart: cmp.To.Timings.Smart - cmp.From.Timings.Smart,
+		Total: cmp.To.Timings.Total - cmp.From.Timings.Total,
+	}
+	for _, c := range runindex.Categories {
+		cmp.Files = append(cmp.Files, CategoryComparison{
+			Category: c,
+			OnlyFrom: difference(from.Files(c), to.Files(c)),
+			OnlyTo:   difference(to.Files(c), from.Files(c)),
+		})
+	}
+
+	return cmp, nil
+}
+
+// loadRun returns the summary and the full run with the given ID.
+func loadRun(index *runindex.Index, runID string) (runindex.Summary, runindex.Run, error) {
+	summary, ok := index.Get(runID)
+	if !ok {
+		return runindex.Summary{}, runindex.Run{}, &notFoundError{fmt.Sprintf("run %q not found", runID)}
+	}
+	run, err := index.Load(runID)
+	if err != nil {
+		if errors.Is(err, runindex.ErrNotFound) {
+			return runindex.Summary{}, runindex.Run{}, &notFoundError{fmt.Sprintf("run %q not found", runID)}
+		}
+		return runindex.Summary{}, runindex.Run{}, fmt.Errorf("load run %q failed: %w", runID, err)
+	}
+	return summary, run, nil
+}
+
+// difference returns the elements of a not contained in b, in order.
+func difference(a, b []string) []string {
+	exclude := make(map[string]struct{}, len(b))
+	for _, s := range b {
+		exclude[s] = struct{}{}
+	}
+
+	out := []string{}
+	for _, s := range a {
+		if _, ok := exclude[s]; !ok {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// churn returns the files that appear in both runs under different
+// categories, sorted by path.
+func churn(from, to runindex.Run) []ChurnFile {
+	fromCats, toCats := fileCategories(from), fileCategories(to)
+
+	out := []ChurnFile{}
+	for p, f := range fromCats {
+		t, ok := toCats[p]
+		if ok && !slices.Equal(f, t) {
+			out = append(out, ChurnFile{Path: p, From: f, To: t})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Path < out[j].Path })
+	return out
+}
+
+// fileCategories maps every changed file of a run to its categories.
+func fileCategories(run runindex.Run) map[string][]runindex.Category {
+	cats := make(map[string][]runindex.Category)
+	for _, c := range runindex.Categories {
+		for _, p := range run.Files(c) {
+			if !slices.Contains(cats[p], c) {
+				cats[p] = append(cats[p], c)
+			}
+		}
+	}
+	return cats
+}
+
+// CompareRow is one line of the count or timing comparison table.
+type CompareRow[T int | time.Duration] struct {
+	Label string // category or step name
+	From  T      // value of the older run
+	To    T      // value of the newer run
+	Delta T      // To minus From
+}
+
+// renderCompare renders the comparison of two runs.
+func renderCompare(
+	w io.Writer,
+	tmpl *template.Template,
+	index *runindex.Index,
+	fromID, toID string,
+) error {
+	fromID, toID, err := resolveCompareIDs(index, fromID, toID)
+	if err != nil {
+		return err
+	}
+	cmp, err := compareRuns(index, fromID, toID)
+	if err != nil {
+		return err
+	}
+
+	fc, tc := cmp.From.Counts, cmp.To.Counts
+	counts := []CompareRow[int]{
+		{"equal", fc.Equal, tc.Equal, tc.Equal - fc.Equal},
+		{"added", fc.Added, tc.Added, tc.Added - fc.Added},
+		{"removed", fc.Removed, tc.Removed, tc.Removed - fc.Removed},
+		{"updated", fc.Updated, tc.Updated, tc.Updated - fc.Updated},
+		{"moved", fc.Moved, tc.Moved, tc.Moved - fc.Moved},
+		{"copied", fc.Copied, tc.Copied, tc.Copied - fc.Copied},
+		{"restored", fc.Restored, tc.Restored, tc.Restored - fc.Restored},
+		{"total", fc.Total, tc.Total, tc.Total - fc.Total},
+	}
+
+	ft, tt := cmp.From.Timings, cmp.To.Timings
+	timings := []CompareRow[time.Duration]{
+		timingRow("touch", ft.Touch, tt.Touch),
+		timingRow("diff", ft.Diff, tt.Diff),
+		timingRow("sync", ft.Sync, tt.Sync),
+		timingRow("scrub", ft.Scrub, tt.Scrub),
+		timingRow("smart", ft.Smart, tt.Smart),
+		timingRow("total", ft.Total, tt.Total),
+	}
+
+	return tmpl.ExecuteTemplate(w, "compare", struct {
+		Comparison    RunComparison
+		Counts        []CompareRow[int]
+		Timings       []CompareRow[time.Duration]
+		AllTimestamps []string
+	}{
+		Comparison:    cmp,
+		Counts:        counts,
+		Timings:       timings,
+		AllTimestamps: index.IDs(),
+	})
+}
+
+// timingRow builds a timing comparison row from durations in seconds.
+func timingRow(label string, from, to float64) CompareRow[time.Duration] {
+	f := time.Duration(from * float64(time.Second)).Round(time.Millisecond)
+	t := time.Duration(to * float64(time.Second)).Round(time.Millisecond)
+	return CompareRow[time.Duration]{Label: label, From: f, To: t, Delta: t - f}
+}
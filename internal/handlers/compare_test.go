@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gi8lino/go-snapraid-web/internal/api"
+	"github.com/gi8lino/go-snapraid-web/internal/runindex"
+	"github.com/gi8lino/go-snapraid/pkg/snapraid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompareAPI(t *testing.T) {
+	t.Parallel()
+
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+
+	tmp := t.TempDir()
+	writeRunFile(t, tmp, "2025-06-01T03:00:00Z", snapraid.RunResult{
+		Result: snapraid.DiffResult{
+			Equal: 100,
+			Added: []string{"filme/a.mkv", "filme/b.mkv"},
+		},
+		Timings: snapraid.RunTimings{Sync: 10 * time.Second, Total: 12 * time.Second},
+	})
+	writeRunFile(t, tmp, "2025-06-02T03:00:00Z", snapraid.RunResult{
+		Result: snapraid.DiffResult{
+			Equal:   102,
+			Added:   []string{"filme/b.mkv", "filme/c.mkv"},
+			Removed: []string{"filme/a.mkv"},
+		},
+		Timings: snapraid.RunTimings{Sync: 4 * time.Second, Total: 5 * time.Second},
+	})
+	writeRunFile(t, tmp, "2025-06-03T03:00:00Z", snapraid.RunResult{})
+	index := loadIndex(t, tmp, logger)
+
+	compare := func(t *testing.T, query string) (*httptest.ResponseRecorder, RunComparison) {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/compare?"+query, nil)
+		rec := httptest.NewRecorder()
+		CompareAPI(index, logger).ServeHTTP(rec, req)
+
+		var cmp RunComparison
+		if rec.Code == http.StatusOK {
+			require.NoError(t, json.NewDecoder(rec.Body).Decode(&cmp))
+		}
+		return rec, cmp
+	}
+
+	t.Run("Compares two runs", func(t *testing.T) {
+		t.Parallel()
+
+		rec, cmp := compare(t, "from=2025-06-01T03:00:00Z&to=2025-06-02T03:00:00Z")
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		assert.Equal(t, "2025-06-01T03:00:00Z", cmp.From.ID)
+		assert.Equal(t, "2025-06-02T03:00:00Z", cmp.To.ID)
+		assert.Equal(t, api.RunCounts{Equal: 2, Removed: 1, Total: 1}, cmp.CountsDelta)
+		assert.Equal(t, api.RunTimings{Sync: -6, Total: -7}, cmp.TimingsDelta)
+
+		require.Len(t, cmp.Files, len(runindex.Categories))
+		assert.Equal(t, CategoryComparison{
+			Category: runindex.CategoryAdded,
+			OnlyFrom: []string{"filme/a.mkv"},
+			OnlyTo:   []string{"filme/c.mkv"},
+		}, cmp.Files[0])
+		assert.Equal(t, CategoryComparison{
+			Category: runindex.CategoryRemoved,
+			OnlyFrom: []string{},
+			OnlyTo:   []string{"filme/a.mkv"},
+		}, cmp.Files[1])
+
+		assert.Equal(t, []ChurnFile{{
+			Path: "filme/a.mkv",
+			From: []runindex.Category{runindex.CategoryAdded},
+			To:   []runindex.Category{runindex.CategoryRemoved},
+		}}, cmp.Churn)
+	})
+
+	t.Run("Defaults to latest and its predecessor", func(t *testing.T) {
+		t.Parallel()
+
+		rec, cmp := compare(t, "")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "2025-06-02T03:00:00Z", cmp.From.ID)
+		assert.Equal(t, "2025-06-03T03:00:00Z", cmp.To.ID)
+		assert.Empty(t, cmp.Churn)
+	})
+
+	t.Run("Defaults from to predecessor", func(t *testing.T) {
+		t.Parallel()
+
+		rec, cmp := compare(t, "to=2025-06-02T03:00:00Z")
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "2025-06-01T03:00:00Z", cmp.From.ID)
+	})
+
+	t.Run("No predecessor", func(t *testing.T) {
+		t.Parallel()
+
+		rec, _ := compare(t, "to=2025-06-01T03:00:00Z")
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.JSONEq(t, `{"error":"no run before \"2025-06-01T03:00:00Z\""}`, rec.Body.String())
+	})
+
+	t.Run("Unknown run", func(t *testing.T) {
+		t.Parallel()
+
+		rec, _ := compare(t, "from=nope&to=latest")
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.JSONEq(t, `{"error":"run \"nope\" not found"}`, rec.Body.String())
+	})
+}
+
+func TestDifference(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, []string{"a", "c"}, difference([]string{"a", "b", "c"}, []string{"b", "d"}))
+	assert.Equal(t, []string{}, difference(nil, []string{"a"}))
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gi8lino/go-snapraid-web/internal/runindex"
+)
+
+// eventsKeepAlive is the interval of comment lines sent to keep idle
+// connections open through proxies.
+const eventsKeepAlive = 30 * time.Second
+
+// Events returns an HTTP handler streaming run index changes as Server-Sent Events.
+// Each change is sent as a "run" event with a JSON payload like {"type":"added","id":"..."}.
+func Events(index *runindex.Index, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rc := http.NewResponseController(w)
+		// the stream is long-lived, so lift the server-wide write timeout
+		if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+			logger.Debug("disable write deadline for events", "error", err)
+		}
+
+		events, cancel := index.Subscribe()
+		defer cancel()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Accel-Buffering", "no") // disable buffering in nginx
+		w.WriteHeader(http.StatusOK)
+		if err := rc.Flush(); err != nil {
+			logger.Error("events stream not supported", "error", err)
+			return
+		}
+
+		ticker := time.NewTicker(eventsKeepAlive)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+
+			case <-ticker.C:
+				if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+					return
+				}
+
+			case ev, ok := <-events:
+				if !ok {
+					return
+				}
+				data, err := json.Marshal(ev)
+				if err != nil {
+					logger.Error("encode run event", "error", err)
+					continue
+				}
+				if _, err := fmt.Fprintf(w, "event: run\ndata: %s\n\n", data); err != nil {
+					return
+				}
+			}
+
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,292 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gi8lino/go-snapraid-web/internal/api"
+	"github.com/gi8lino/go-snapraid-web/internal/runindex"
+)
+
+// exportFormat is the encoding of a download.
+type exportFormat string
+
+const (
+	exportCSV    exportFormat = "csv"    // comma-separated values with a header row
+	exportNDJSON exportFormat = "ndjson" // one JSON object per line
+)
+
+// exportFormats lists the supported export formats in display order.
+var exportFormats = []exportFormat{exportCSV, exportNDJSON}
+
+// parseExportFormat parses the format query parameter; empty means CSV.
+func parseExportFormat(s string) (exportFormat, error) {
+	switch exportFormat(s) {
+	case "", exportCSV:
+		return exportCSV, nil
+	case exportNDJSON:
+		return exportNDJSON, nil
+	default:
+		return "", fmt.Errorf("invalid format %q", s)
+	}
+}
+
+// contentType returns the media type of the format.
+func (f exportFormat) contentType() string {
+	if f == exportNDJSON {
+		return "application/x-ndjson"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// exportRecord is a row of an export.
+type exportRecord interface {
+	csvRecord() []string
+}
+
+// RunRecord is an overview row as exported.
+type RunRecord struct {
+	ID       string          `json:"id"`   // run ID / RFC3339 timestamp of the run file
+	Date     string          `json:"date"` // timestamp recorded inside the run file
+	Status   runindex.Status `json:"status"`
+	Error    string          `json:"error,omitempty"`
+	Equal    int             `json:"equal"`
+	Added    int             `json:"added"`
+	Removed  int             `json:"removed"`
+	Updated  int             `json:"updated"`
+	Moved    int             `json:"moved"`
+	Copied   int             `json:"copied"`
+	Restored int             `json:"restored"`
+	Total    int             `json:"total"` // sum of all changed files, excluding equal
+	Touch    float64         `json:"touch_seconds"`
+	Diff     float64         `json:"diff_seconds"`
+	Sync     float64         `json:"sync_seconds"`
+	Scrub    float64         `json:"scrub_seconds"`
+	Smart    float64         `json:"smart_seconds"`
+	Time     float64         `json:"total_seconds"`
+}
+
+// runRecordHeader is the CSV header matching RunRecord.csvRecord.
+var runRecordHeader = []string{
+	"id", "date", "status", "error",
+	"equal", "added", "removed", "updated", "moved", "copied", "restored", "total",
+	"touch_seconds", "diff_seconds", "sync_seconds", "scrub_seconds", "smart_seconds", "total_seconds",
+}
+
+// newRunRecord converts an indexed run summary into its export row.
+func newRunRecord(s runindex.Summary) RunRecord {
+	summary := api.NewRunSummary(s)
+	return RunRecord{
+		ID:       summary.ID,
+		Date:     summary.Date,
+		Status:   summary.Status,
+		Error:    summary.Error,
+		Equal:    summary.Counts.Equal,
+		Added:    summary.Counts.Added,
+		Removed:  summary.Counts.Removed,
+		Updated:  summary.Counts.Updated,
+		Moved:    summary.Counts.Moved,
+		Copied:   summary.Counts.Copied,
+		Restored: summary.Counts.Restored,
+		Total:    summary.Counts.Total,
+		Touch:    summary.Timings.Touch,
+		Diff:     summary.Timings.Diff,
+		Sync:     summary.Timings.Sync,
+		Scrub:    summary.Timings.Scrub,
+		Smart:    summary.Timings.Smart,
+		Time:     summary.Timings.Total,
+	}
+}
+
+func (r RunRecord) csvRecord() []string {
+	return []string{
+		r.ID, r.Date, string(r.Status), r.Error,
+		strconv.Itoa(r.Equal), strconv.Itoa(r.Added), strconv.Itoa(r.Removed), strconv.Itoa(r.Updated),
+		strconv.Itoa(r.Moved), strconv.Itoa(r.Copied), strconv.Itoa(r.Restored), strconv.Itoa(r.Total),
+		formatSeconds(r.Touch), formatSeconds(r.Diff), formatSeconds(r.Sync),
+		formatSeconds(r.Scrub), formatSeconds(r.Smart), formatSeconds(r.Time),
+	}
+}
+
+// FileRecord is a changed file of a run as exported.
+type FileRecord struct {
+	Category runindex.Category `json:"category"`
+	Path     string            `json:"path"` // path with SnapRAID escapes decoded
+	Raw      string            `json:"raw"`  // shell-escaped path as written by go-snapraid
+}
+
+// fileRecordHeader is the CSV header matching FileRecord.csvRecord.
+var fileRecordHeader = []string{"category", "path", "raw"}
+
+func (r FileRecord) csvRecord() []string {
+	return []string{string(r.Category), r.Path, r.Raw}
+}
+
+// formatSeconds formats a duration in seconds without a trailing exponent.
+func formatSeconds(s float64) string {
+	return strconv.FormatFloat(s, 'f', -1, 64)
+}
+
+// ExportRunsAPI returns an HTTP handler downloading the overview rows as CSV
+// or NDJSON, newest first. The optional "status", "from" and "to" query
+// parameters filter the runs like in the overview.
+func ExportRunsAPI(index *runindex.Index, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		values := r.URL.Query()
+		format, err := parseExportFormat(values.Get("format"))
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		status, err := parseStatusFilter(values.Get("status"))
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		dates, err := parseDateRange(values)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		summaries := dates.apply(status.apply(index.List()))
+		records := make([]RunRecord, 0, len(summaries))
+		for _, s := range summaries {
+			records = append(records, newRunRecord(s))
+		}
+
+		if err := writeExport(w, format, "runs", runRecordHeader, records); err != nil {
+			logger.Error("export runs", "error", err)
+		}
+	}
+}
+
+// ExportRunFilesAPI returns an HTTP handler downloading the changed files of
+// a run as CSV or NDJSON. The run is selected by the {id} path value;
+// "latest" selects the most recent run.
+func ExportRunFilesAPI(index *runindex.Index, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		format, err := parseExportFormat(r.URL.Query().Get("format"))
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		runID := r.PathValue("id")
+		if runID == "latest" {
+			latest, ok := index.Latest()
+			if !ok {
+				writeJSONError(w, http.StatusNotFound, "no runs found")
+				return
+			}
+			runID = latest.ID
+		}
+
+		_, run, err := loadRun(index, runID)
+		if err != nil {
+			if errors.As(err, new(*notFoundError)) {
+				writeJSONError(w, http.StatusNotFound, err.Error())
+				return
+			}
+			logger.Error("load run", "id", runID, "error", err)
+			writeJSONError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+
+		var records []FileRecord
+		for _, c := range runindex.Categories {
+			for _, f := range runFiles(run, c) {
+				records = append(records, FileRecord{Category: c, Path: f.Path, Raw: f.Raw})
+			}
+		}
+
+		name := "run-" + strings.ReplaceAll(runID, ":", "-") + "-files"
+		if err := writeExport(w, format, name, fileRecordHeader, records); err != nil {
+			logger.Error("export run files", "id", runID, "error", err)
+		}
+	}
+}
+
+// writeExport writes records as a download named after name. The header is
+// only used for CSV.
+func writeExport[T exportRecord](w http.ResponseWriter, format exportFormat, name string, header []string, records []T) error {
+	w.Header().Set("Content-Type", format.contentType())
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+"."+string(format)))
+
+	if format == exportNDJSON {
+		return writeNDJSON(w, records)
+	}
+	return writeCSV(w, header, records)
+}
+
+// writeCSV writes a header row followed by one row per record.
+func writeCSV[T exportRecord](w io.Writer, header []string, records []T) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for _, r := range records {
+		if err := cw.Write(csvSafe(r.csvRecord())); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// csvSafe prefixes cells starting like a formula with a single quote, so
+// spreadsheets opening the export show file names such as "=cmd|..." as text
+// instead of evaluating them.
+func csvSafe(record []string) []string {
+	for i, cell := range record {
+		if cell != "" && strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+			record[i] = "'" + cell
+		}
+	}
+	return record
+}
+
+// writeNDJSON writes one JSON object per record and line. Paths are written
+// verbatim, e.g. "a -> b" instead of "a -\u003e b".
+func writeNDJSON[T any](w io.Writer, records []T) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	for _, r := range records {
+		if err := enc.Encode(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ExportLink is a download link offered in the dashboard.
+type ExportLink struct {
+	Label string // format name, e.g. "CSV"
+	URL   string
+}
+
+// exportLinks returns a download link per export format for path, keeping
+// the given query parameters.
+func exportLinks(path string, values url.Values) []ExportLink {
+	links := make([]ExportLink, 0, len(exportFormats))
+	for _, f := range exportFormats {
+		query := url.Values{}
+		for k, v := range values {
+			query[k] = v
+		}
+		query.Set("format", string(f))
+		links = append(links, ExportLink{
+			Label: strings.ToUpper(string(f)),
+			URL:   path + "?" + query.Encode(),
+		})
+	}
+	return links
+}
@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gi8lino/go-snapraid/pkg/snapraid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseExportFormat(t *testing.T) {
+	t.Parallel()
+
+	format, err := parseExportFormat("")
+	require.NoError(t, err)
+	assert.Equal(t, exportCSV, format)
+
+	format, err = parseExportFormat("ndjson")
+	require.NoError(t, err)
+	assert.Equal(t, exportNDJSON, format)
+
+	_, err = parseExportFormat("xlsx")
+	require.Error(t, err)
+	assert.EqualError(t, err, `invalid format "xlsx"`)
+}
+
+func TestExportRunsAPI(t *testing.T) {
+	t.Parallel()
+
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+
+	tmp := t.TempDir()
+	writeRunFile(t, tmp, "2025-06-01T03:00:00Z", snapraid.RunResult{
+		Timestamp: "2025-06-01T03:00:00Z",
+		Result:    snapraid.DiffResult{Equal: 10, Added: []string{"a", "b"}},
+		Timings:   snapraid.RunTimings{Diff: 1500 * time.Millisecond, Total: 2 * time.Second},
+	})
+	writeRunFile(t, tmp, "2025-06-02T03:00:00Z", snapraid.RunResult{
+		Timestamp: "2025-06-02T03:00:00Z",
+		Result:    snapraid.DiffResult{Removed: []string{"c"}},
+	})
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /api/v1/export/runs", ExportRunsAPI(loadIndex(t, tmp, logger), logger))
+
+	t.Run("CSV", func(t *testing.T) {
+		t.Parallel()
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/export/runs", nil)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "text/csv; charset=utf-8", rec.Header().Get("Content-Type"))
+		assert.Equal(t, `attachment; filename="runs.csv"`, rec.Header().Get("Content-Disposition"))
+		assert.Equal(t, ""+
+			"id,date,status,error,equal,added,removed,updated,moved,copied,restored,total,touch_seconds,diff_seconds,sync_seconds,scrub_seconds,smart_seconds,total_seconds\n"+
+			"2025-06-02T03:00:00Z,2025-06-02T03:00:00Z,success,,0,0,1,0,0,0,0,1,0,0,0,0,0,0\n"+
+			"2025-06-01T03:00:00Z,2025-06-01T03:00:00Z,success,,10,2,0,0,0,0,0,2,0,1.5,0,0,0,2\n",
+			rec.Body.String())
+	})
+
+	t.Run("NDJSON with date range", func(t *testing.T) {
+		t.Parallel()
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/export/runs?format=ndjson&to=2025-06-01", nil)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "application/x-ndjson", rec.Header().Get("Content-Type"))
+		assert.Equal(t, `attachment; filename="runs.ndjson"`, rec.Header().Get("Content-Disposition"))
+		assert.JSONEq(t, `{
+			"id": "2025-06-01T03:00:00Z",
+			"date": "2025-06-01T03:00:00Z",
+			"status": "success",
+			"equal": 10, "added": 2, "removed": 0, "updated": 0, "moved": 0, "copied": 0, "restored": 0, "total": 2,
+			"touch_seconds": 0, "diff_seconds": 1.5, "sync_seconds": 0, "scrub_seconds": 0, "smart_seconds": 0, "total_seconds": 2
+		}`, rec.Body.String())
+	})
+
+	t.Run("Empty range", func(t *testing.T) {
+		t.Parallel()
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/export/runs?format=ndjson&from=2026-01-01", nil)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Empty(t, rec.Body.String())
+	})
+
+	t.Run("Invalid format", func(t *testing.T) {
+		t.Parallel()
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/export/runs?format=xml", nil)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.JSONEq(t, `{"error":"invalid format \"xml\""}`, rec.Body.String())
+	})
+
+	t.Run("Invalid date range", func(t *testing.T) {
+		t.Parallel()
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/export/runs?from=yesterday", nil)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
+
+func TestExportRunFilesAPI(t *testing.T) {
+	t.Parallel()
+
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+
+	tmp := t.TempDir()
+	writeRunFile(t, tmp, "2025-06-01T03:00:00Z", snapraid.RunResult{
+		Result: snapraid.DiffResult{
+			Added: []string{`filme/a\ \(1\).mkv`},
+			Moved: []string{"x.mkv -> y.mkv"},
+		},
+	})
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /api/v1/export/runs/{id}/files", ExportRunFilesAPI(loadIndex(t, tmp, logger), logger))
+
+	t.Run("CSV", func(t *testing.T) {
+		t.Parallel()
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/export/runs/2025-06-01T03:00:00Z/files", nil)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, `attachment; filename="run-2025-06-01T03-00-00Z-files.csv"`, rec.Header().Get("Content-Disposition"))
+		assert.Equal(t, ""+
+			"category,path,raw\n"+
+			`added,filme/a (1).mkv,filme/a\ \(1\).mkv`+"\n"+
+			"moved,x.mkv -> y.mkv,x.mkv -> y.mkv\n",
+			rec.Body.String())
+	})
+
+	t.Run("NDJSON of latest run", func(t *testing.T) {
+		t.Parallel()
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/export/runs/latest/files?format=ndjson", nil)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, ""+
+			`{"category":"added","path":"filme/a (1).mkv","raw":"filme/a\\ \\(1\\).mkv"}`+"\n"+
+			`{"category":"moved","path":"x.mkv -> y.mkv","raw":"x.mkv -> y.mkv"}`+"\n",
+			rec.Body.String())
+	})
+
+	t.Run("Run not found", func(t *testing.T) {
+		t.Parallel()
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/export/runs/2020-01-01T00:00:00Z/files", nil)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
+
+func TestCSVSafe(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t,
+		[]string{"added", "'=HYPERLINK(\"x\")", "'+1.mkv", "'-rf.mkv", "'@SUM(A1).mkv", "'\tx", "a=b", "", "0"},
+		csvSafe([]string{"added", `=HYPERLINK("x")`, "+1.mkv", "-rf.mkv", "@SUM(A1).mkv", "\tx", "a=b", "", "0"}),
+	)
+}
+
+func TestExportLinks(t *testing.T) {
+	t.Parallel()
+
+	q, err := parseOverviewQuery(url.Values{"source": {"media"}, "status": {"failed"}, "from": {"2025-06-01"}, "sort": {"total"}, "page": {"2"}})
+	require.NoError(t, err)
+
+	links := exportLinks("/api/v1/export/runs", q.exportValues())
+	assert.Equal(t, []ExportLink{
+		{Label: "CSV", URL: "/api/v1/export/runs?format=csv&from=2025-06-01&source=media&status=failed"},
+		{Label: "NDJSON", URL: "/api/v1/export/runs?format=ndjson&from=2025-06-01&source=media&status=failed"},
+	}, links)
+}
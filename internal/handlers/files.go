@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gi8lino/go-snapraid-web/internal/runindex"
+)
+
+// defaultFilesPageSize is the number of files per page of a file list.
+const defaultFilesPageSize = 100
+
+// RunFilesPage is one page of the changed files of a category of a run.
+type RunFilesPage struct {
+	ID       string            `json:"id"`
+	Category runindex.Category `json:"category"`
+	Query    string            `json:"query,omitempty"` // filter applied to the paths
+	Total    int               `json:"total"`           // number of files matching the filter
+	Page     int               `json:"page"`
+	PageSize int               `json:"page_size"`
+	Files    []RunFile         `json:"files"`
+}
+
+// runFilesQuery selects a page of the changed files of a category of a run.
+type runFilesQuery struct {
+	pageQuery
+	category runindex.Category
+	filter   string // case-insensitive substring of the decoded path
+}
+
+// parseRunFilesQuery parses the "category", "q", "page" and "size" query parameters.
+func parseRunFilesQuery(values url.Values) (runFilesQuery, error) {
+	q := runFilesQuery{filter: strings.TrimSpace(values.Get("q"))}
+
+	var ok bool
+	if q.category, ok = runindex.ParseCategory(values.Get("category")); !ok {
+		return runFilesQuery{}, fmt.Errorf("invalid category %q", values.Get("category"))
+	}
+
+	var err error
+	if q.pageQuery, err = parsePageQuery(values, defaultFilesPageSize); err != nil {
+		return runFilesQuery{}, err
+	}
+	return q, nil
+}
+
+// loadRunFiles loads the requested page of files of the run.
+func loadRunFiles(index *runindex.Index, runID string, q runFilesQuery) (RunFilesPage, Pagination, error) {
+	_, run, err := loadRun(index, runID)
+	if err != nil {
+		return RunFilesPage{}, Pagination{}, err
+	}
+
+	files := runFiles(run, q.category)
+	if q.filter != "" {
+		needle := strings.ToLower(q.filter)
+		matching := make([]RunFile, 0, len(files))
+		for _, f := range files {
+			if strings.Contains(strings.ToLower(f.Path), needle) {
+				matching = append(matching, f)
+			}
+		}
+		files = matching
+	}
+
+	files, page := paginate(files, q.pageQuery)
+	return RunFilesPage{
+		ID:       runID,
+		Category: q.category,
+		Query:    q.filter,
+		Total:    page.TotalRows,
+		Page:     page.Page,
+		PageSize: page.PageSize,
+		Files:    files,
+	}, page, nil
+}
+
+// RunFilesAPI returns an HTTP handler listing a page of the changed files of
+// one category of a run as JSON. The run is selected by the {id} path value;
+// "latest" selects the most recent run.
+func RunFilesAPI(index *runindex.Index, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q, err := parseRunFilesQuery(r.URL.Query())
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		runID := r.PathValue("id")
+		if runID == "latest" {
+			latest, ok := index.Latest()
+			if !ok {
+				writeJSONError(w, http.StatusNotFound, "no runs found")
+				return
+			}
+			runID = latest.ID
+		}
+
+		page, _, err := loadRunFiles(index, runID, q)
+		if err != nil {
+			if errors.As(err, new(*notFoundError)) {
+				writeJSONError(w, http.StatusNotFound, err.Error())
+				return
+			}
+			logger.Error("load run files", "id", runID, "error", err)
+			writeJSONError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		writeJSON(w, http.StatusOK, page)
+	}
+}
+
+// renderRunFiles renders a page of the changed files of one category of a run.
+func renderRunFiles(
+	w io.Writer,
+	tmpl *template.Template,
+	index *runindex.Index,
+	runID string,
+	q runFilesQuery,
+) error {
+	page, pagination, err := loadRunFiles(index, runID, q)
+	if err != nil {
+		return err
+	}
+
+	return tmpl.ExecuteTemplate(w, "runFiles", struct {
+		Page       RunFilesPage
+		Pagination Pagination
+	}{
+		Page:       page,
+		Pagination: pagination,
+	})
+}
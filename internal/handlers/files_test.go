@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gi8lino/go-snapraid-web/internal/runindex"
+	"github.com/gi8lino/go-snapraid/pkg/snapraid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRunFilesQuery(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Defaults", func(t *testing.T) {
+		t.Parallel()
+
+		q, err := parseRunFilesQuery(url.Values{"category": {"moved"}})
+		require.NoError(t, err)
+		assert.Equal(t, runindex.CategoryMoved, q.category)
+		assert.Equal(t, "", q.filter)
+		assert.Equal(t, 1, q.page)
+		assert.Equal(t, defaultFilesPageSize, q.pageSize)
+	})
+
+	t.Run("Filter is trimmed", func(t *testing.T) {
+		t.Parallel()
+
+		q, err := parseRunFilesQuery(url.Values{"category": {"added"}, "q": {" mkv "}})
+		require.NoError(t, err)
+		assert.Equal(t, "mkv", q.filter)
+	})
+
+	t.Run("Missing category", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := parseRunFilesQuery(url.Values{})
+		require.Error(t, err)
+		assert.EqualError(t, err, `invalid category ""`)
+	})
+
+	t.Run("Invalid page", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := parseRunFilesQuery(url.Values{"category": {"added"}, "page": {"0"}})
+		require.Error(t, err)
+		assert.EqualError(t, err, `invalid page "0"`)
+	})
+}
+
+func TestRunFilesAPI(t *testing.T) {
+	t.Parallel()
+
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+
+	tmp := t.TempDir()
+	writeRunFile(t, tmp, "2025-06-01T03:00:00Z", snapraid.RunResult{
+		Result: snapraid.DiffResult{Added: []string{"a", "b", "c"}},
+	})
+	writeRunFile(t, tmp, "2025-06-02T03:00:00Z", snapraid.RunResult{
+		Result: snapraid.DiffResult{Removed: []string{`filme/b\ \(1\).mkv`, "serien/c.mkv"}},
+	})
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /api/v1/runs/{id}/files", RunFilesAPI(loadIndex(t, tmp, logger), logger))
+
+	t.Run("Returns page", func(t *testing.T) {
+		t.Parallel()
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/runs/2025-06-01T03:00:00Z/files?category=added&size=2&page=2", nil)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var page RunFilesPage
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &page))
+		assert.Equal(t, RunFilesPage{
+			ID:       "2025-06-01T03:00:00Z",
+			Category: runindex.CategoryAdded,
+			Total:    3,
+			Page:     2,
+			PageSize: 2,
+			Files:    []RunFile{{Path: "c", Raw: "c"}},
+		}, page)
+	})
+
+	t.Run("Filters decoded paths of latest run", func(t *testing.T) {
+		t.Parallel()
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/runs/latest/files?category=removed&q=B%20(1)", nil)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var page RunFilesPage
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &page))
+		assert.Equal(t, "2025-06-02T03:00:00Z", page.ID)
+		assert.Equal(t, "B (1)", page.Query)
+		assert.Equal(t, 1, page.Total)
+		assert.Equal(t, []RunFile{{Path: "filme/b (1).mkv", Raw: `filme/b\ \(1\).mkv`}}, page.Files)
+	})
+
+	t.Run("Empty category", func(t *testing.T) {
+		t.Parallel()
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/runs/2025-06-01T03:00:00Z/files?category=moved", nil)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"id":"2025-06-01T03:00:00Z","category":"moved","total":0,"page":1,"page_size":100,"files":[]}`, rec.Body.String())
+	})
+
+	t.Run("Invalid category", func(t *testing.T) {
+		t.Parallel()
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/runs/2025-06-01T03:00:00Z/files?category=deleted", nil)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.JSONEq(t, `{"error":"invalid category \"deleted\""}`, rec.Body.String())
+	})
+
+	t.Run("Run not found", func(t *testing.T) {
+		t.Parallel()
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/runs/2020-01-01T00:00:00Z/files?category=added", nil)
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
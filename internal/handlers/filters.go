@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gi8lino/go-snapraid-web/internal/runindex"
+)
+
+// statusFilter selects runs by their status. An empty filter matches all runs.
+type statusFilter map[runindex.Status]bool
+
+// parseStatusFilter parses a comma-separated list of run statuses,
+// e.g. "failed,partial".
+func parseStatusFilter(s string) (statusFilter, error) {
+	filter := statusFilter{}
+	for part := range strings.SplitSeq(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		st, ok := runindex.ParseStatus(part)
+		if !ok {
+			return nil, fmt.Errorf("invalid status %q", part)
+		}
+		filter[st] = true
+	}
+	return filter, nil
+}
+
+// String returns the filter in the format accepted by parseStatusFilter.
+func (f statusFilter) String() string {
+	var parts []string
+	for _, st := range []runindex.Status{runindex.StatusSuccess, runindex.StatusFailed, runindex.StatusPartial} {
+		if f[st] {
+			parts = append(parts, string(st))
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// apply returns the summaries matching the filter, keeping their order.
+func (f statusFilter) apply(summaries []runindex.Summary) []runindex.Summary {
+	if len(f) == 0 {
+		return summaries
+	}
+	filtered := make([]runindex.Summary, 0, len(summaries))
+	for _, s := range summaries {
+		if f[s.Status] {
+			filtered = append(filtered, s)
+		}
+	}
+	return filtered
+}
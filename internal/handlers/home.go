@@ -5,10 +5,16 @@ import (
 	"io/fs"
 	"net/http"
 	"path"
+
+	"github.com/gi8lino/go-snapraid-web/internal/auth"
 )
 
-// HomeHandler renders the base template with navbar and footer.
-func HomeHandler(webFS fs.FS, version string) http.HandlerFunc {
+// HomeHandler renders the base template with navbar and footer. With more
+// than one source, the navbar offers a source switcher; with schedule and
+// actions set, it links the scheduled jobs and the actions. Users logged in
+// with OIDC get a logout link, users that file paths are hidden from get no
+// links to search and compare.
+func HomeHandler(webFS fs.FS, version string, sources []string, schedule, actions bool) http.HandlerFunc {
 	tmpl := template.Must(
 		template.New("base").
 			ParseFS(webFS,
@@ -18,15 +24,35 @@ func HomeHandler(webFS fs.FS, version string) http.HandlerFunc {
 			),
 	)
 	data := struct {
-		Version string
-		Commit  string
+		Version   string
+		Commit    string
+		Sources   []string // source names, empty with a single source
+		Schedule  bool     // whether jobs are scheduled
+		Actions   bool     // whether actions are configured
+		User      string   // name of the authenticated user, empty without authentication
+		Logout    string   // path to log out at, empty if the user cannot log out
+		HidePaths bool     // whether file paths are hidden from the user
 	}{
-		Version: version,
+		Version:  version,
+		Schedule: schedule,
+		Actions:  actions,
+	}
+	if len(sources) > 1 {
+		data.Sources = sources
 	}
 
 	return func(w http.ResponseWriter, r *http.Request) {
+		page := data
+		if id, ok := auth.FromContext(r.Context()); ok {
+			page.User = id.Name
+			page.HidePaths = id.HidePaths
+			if id.Method == auth.MethodOIDC {
+				page.Logout = auth.LogoutPath
+			}
+		}
+
 		// execute the "base" template
-		if err := tmpl.ExecuteTemplate(w, "base", data); err != nil {
+		if err := tmpl.ExecuteTemplate(w, "base", page); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 		}
 	}
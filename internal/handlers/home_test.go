@@ -6,6 +6,7 @@ import (
 	"testing"
 	"testing/fstest"
 
+	"github.com/gi8lino/go-snapraid-web/internal/auth"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -23,7 +24,7 @@ func TestHomeHandler(t *testing.T) {
 		}
 
 		version := "v1.2.3"
-		handler := HomeHandler(webFS, version)
+		handler := HomeHandler(webFS, version, []string{"default"}, false, false)
 
 		req := httptest.NewRequest(http.MethodGet, "/", nil)
 		rec := httptest.NewRecorder()
@@ -41,6 +42,91 @@ func TestHomeHandler(t *testing.T) {
 		assert.Contains(t, body, "<footer>"+version+"</footer>")
 	})
 
+	t.Run("lists multiple sources", func(t *testing.T) {
+		t.Parallel()
+
+		webFS := fstest.MapFS{
+			"web/templates/base.html":   &fstest.MapFile{Data: []byte(`{{define "base"}}<html>{{template "navbar" .}}</html>{{end}}`)},
+			"web/templates/navbar.html": &fstest.MapFile{Data: []byte(`{{define "navbar"}}<nav>{{range .Sources}}<option>{{.}}</option>{{end}}</nav>{{end}}`)},
+			"web/templates/footer.html": &fstest.MapFile{Data: []byte(`{{define "footer"}}<!-- footer -->{{end}}`)},
+		}
+
+		t.Run("single source", func(t *testing.T) {
+			t.Parallel()
+
+			rec := httptest.NewRecorder()
+			HomeHandler(webFS, "v1.2.3", []string{"default"}, false, false)(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, "<html><nav></nav></html>", rec.Body.String())
+		})
+
+		t.Run("multiple sources", func(t *testing.T) {
+			t.Parallel()
+
+			rec := httptest.NewRecorder()
+			HomeHandler(webFS, "v1.2.3", []string{"media", "backup"}, false, false)(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, "<html><nav><option>media</option><option>backup</option></nav></html>", rec.Body.String())
+		})
+	})
+
+	t.Run("links the schedule and actions", func(t *testing.T) {
+		t.Parallel()
+
+		webFS := fstest.MapFS{
+			"web/templates/base.html":   &fstest.MapFile{Data: []byte(`{{define "base"}}<html>{{template "navbar" .}}</html>{{end}}`)},
+			"web/templates/navbar.html": &fstest.MapFile{Data: []byte(`{{define "navbar"}}<nav>{{if .Schedule}}<a>Schedule</a>{{end}}{{if .Actions}}<a>Actions</a>{{end}}</nav>{{end}}`)},
+			"web/templates/footer.html": &fstest.MapFile{Data: []byte(`{{define "footer"}}<!-- footer -->{{end}}`)},
+		}
+
+		rec := httptest.NewRecorder()
+		HomeHandler(webFS, "v1.2.3", []string{"default"}, true, false)(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "<html><nav><a>Schedule</a></nav></html>", rec.Body.String())
+
+		rec = httptest.NewRecorder()
+		HomeHandler(webFS, "v1.2.3", []string{"default"}, false, true)(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		assert.Equal(t, "<html><nav><a>Actions</a></nav></html>", rec.Body.String())
+	})
+
+	t.Run("shows the user", func(t *testing.T) {
+		t.Parallel()
+
+		webFS := fstest.MapFS{
+			"web/templates/base.html":   &fstest.MapFile{Data: []byte(`{{define "base"}}<html>{{template "navbar" .}}</html>{{end}}`)},
+			"web/templates/navbar.html": &fstest.MapFile{Data: []byte(`{{define "navbar"}}<nav>{{.User}}{{with .Logout}} <a href="{{.}}">Log out</a>{{end}}</nav>{{end}}`)},
+			"web/templates/footer.html": &fstest.MapFile{Data: []byte(`{{define "footer"}}<!-- footer -->{{end}}`)},
+		}
+		handler := HomeHandler(webFS, "v1.2.3", []string{"default"}, false, false)
+
+		tests := []struct {
+			name     string
+			identity *auth.Identity
+			expected string
+		}{
+			{name: "anonymous", expected: "<html><nav></nav></html>"},
+			{name: "basic auth", identity: &auth.Identity{Name: "alice", Method: auth.MethodBasic}, expected: "<html><nav>alice</nav></html>"},
+			{name: "OIDC", identity: &auth.Identity{Name: "bob", Method: auth.MethodOIDC}, expected: `<html><nav>bob <a href="/auth/logout">Log out</a></nav></html>`},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				t.Parallel()
+
+				req := httptest.NewRequest(http.MethodGet, "/", nil)
+				if tt.identity != nil {
+					req = req.WithContext(auth.WithIdentity(req.Context(), *tt.identity))
+				}
+				rec := httptest.NewRecorder()
+				handler(rec, req)
+
+				assert.Equal(t, http.StatusOK, rec.Code)
+				assert.Equal(t, tt.expected, rec.Body.String())
+			})
+		}
+	})
+
 	t.Run("parse error", func(t *testing.T) {
 		t.Parallel()
 
@@ -52,7 +138,7 @@ func TestHomeHandler(t *testing.T) {
 		}
 
 		version := "v1.2.3"
-		handler := HomeHandler(webFS, version)
+		handler := HomeHandler(webFS, version, []string{"default"}, false, false)
 
 		req := httptest.NewRequest(http.MethodGet, "/", nil)
 		rec := httptest.NewRecorder()
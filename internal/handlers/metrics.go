@@ -0,0 +1,221 @@
+package handlers
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gi8lino/go-snapraid-web/internal/runindex"
+	"github.com/gi8lino/go-snapraid/pkg/snapraid"
+)
+
+// metricSample is a single value of a metric with its rendered label set.
+type metricSample struct {
+	labels string // e.g. `step="sync"`, empty for unlabeled metrics
+	value  float64
+}
+
+// metricFamily is a metric with its samples of all sources.
+type metricFamily struct {
+	name    string
+	help    string
+	samples []metricSample
+}
+
+// metricWriter collects metrics and renders them in the Prometheus text
+// exposition format. Samples of a metric are grouped under a single HELP and
+// TYPE line, even if they are added for several sources.
+type metricWriter struct {
+	families []*metricFamily // in order of first use
+	byName   map[string]*metricFamily
+	labels   string // labels prepended to every sample, e.g. `source="media"`
+}
+
+// newMetricWriter returns an empty metricWriter.
+func newMetricWriter() *metricWriter {
+	return &metricWriter{byName: make(map[string]*metricFamily)}
+}
+
+// gauge adds samples to a gauge metric.
+func (m *metricWriter) gauge(name, help string, samples ...metricSample) {
+	f, ok := m.byName[name]
+	if !ok {
+		f = &metricFamily{name: name, help: help}
+		m.byName[name] = f
+		m.families = append(m.families, f)
+	}
+	for _, s := range samples {
+		switch {
+		case m.labels == "":
+		case s.labels == "":
+			s.labels = m.labels
+		default:
+			s.labels = m.labels + "," + s.labels
+		}
+		f.samples = append(f.samples, s)
+	}
+}
+
+// writeTo writes all metrics with their HELP and TYPE lines.
+func (m *metricWriter) writeTo(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	for _, f := range m.families {
+		fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s gauge\n", f.name, f.help, f.name) // nolint:errcheck
+		for _, s := range f.samples {
+			value := strconv.FormatFloat(s.value, 'g', -1, 64)
+			if s.labels == "" {
+				fmt.Fprintf(bw, "%s %s\n", f.name, value) // nolint:errcheck
+				continue
+			}
+			fmt.Fprintf(bw, "%s{%s} %s\n", f.name, s.labels, value) // nolint:errcheck
+		}
+	}
+	return bw.Flush()
+}
+
+// label renders a single label pair.
+func label(name, value string) string {
+	return name + "=" + strconv.Quote(value)
+}
+
+// boolValue converts b into a gauge value.
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// unixSeconds converts t into a gauge value.
+func unixSeconds(t time.Time) float64 {
+	return float64(t.UnixNano()) / float64(time.Second)
+}
+
+// Metrics returns an HTTP handler exposing run history metrics for
+// Prometheus. Every sample carries a "source" label.
+func Metrics(sources runindex.Sources) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+		m := newMetricWriter()
+		for _, src := range sources {
+			m.labels = label("source", src.Name)
+			sourceMetrics(m, src.Index)
+		}
+		_ = m.writeTo(w)
+	}
+}
+
+// sourceMetrics adds the metrics of a single source to m.
+func sourceMetrics(m *metricWriter, index *runindex.Index) {
+	summaries := index.List() // newest first
+
+	// totals over the indexed history
+	runs := map[runindex.Status]int{}
+	var files runindex.Summary
+	for _, s := range summaries {
+		runs[s.Status]++
+		files.Added += s.Added
+		files.Removed += s.Removed
+		files.Updated += s.Updated
+		files.Moved += s.Moved
+		files.Copied += s.Copied
+		files.Restored += s.Restored
+	}
+	m.gauge("snapraid_runs", "Number of indexed runs by status.",
+		metricSample{label("status", string(runindex.StatusSuccess)), float64(runs[runindex.StatusSuccess])},
+		metricSample{label("status", string(runindex.StatusFailed)), float64(runs[runindex.StatusFailed])},
+		metricSample{label("status", string(runindex.StatusPartial)), float64(runs[runindex.StatusPartial])},
+	)
+	m.gauge("snapraid_history_files", "Number of changed files over all indexed runs by category.",
+		fileSamples(files, false)...,
+	)
+
+	if len(summaries) == 0 {
+		return
+	}
+
+	// most recent run
+	latest := summaries[0]
+	m.gauge("snapraid_last_run_timestamp_seconds", "Unix time of the most recent run.",
+		metricSample{value: unixSeconds(latest.Time)},
+	)
+	m.gauge("snapraid_last_run_success", "Whether the most recent run succeeded.",
+		metricSample{value: boolValue(latest.Status == runindex.StatusSuccess)},
+	)
+	durations := make([]metricSample, 0, len(runSteps)+1)
+	for _, step := range runSteps {
+		durations = append(durations, metricSample{label("step", step.name), step.duration(latest.Timings).Seconds()})
+	}
+	durations = append(durations, metricSample{label("step", "total"), latest.Timings.Total.Seconds()})
+	m.gauge("snapraid_last_run_step_duration_seconds", "Duration of each step of the most recent run.",
+		durations...,
+	)
+	m.gauge("snapraid_last_run_files", "Number of files per category in the most recent run.",
+		fileSamples(latest, true)...,
+	)
+	anomalies := make([]metricSample, 0, len(runindex.Categories))
+	for _, c := range runindex.Categories {
+		var flagged bool
+		for _, a := range latest.Anomalies {
+			flagged = flagged || a.Category == c
+		}
+		anomalies = append(anomalies, metricSample{label("category", string(c)), boolValue(flagged)})
+	}
+	m.gauge("snapraid_last_run_anomaly", "Whether a category of the most recent run exceeded its warning threshold.",
+		anomalies...,
+	)
+
+	// most recent successful run and most recent run per executed step
+	for _, s := range summaries {
+		if s.Status == runindex.StatusSuccess {
+			m.gauge("snapraid_last_success_timestamp_seconds", "Unix time of the most recent successful run.",
+				metricSample{value: unixSeconds(s.Time)},
+			)
+			break
+		}
+	}
+	var stepTimes []metricSample
+	for _, step := range runSteps {
+		for _, s := range summaries {
+			if step.duration(s.Timings) > 0 {
+				stepTimes = append(stepTimes, metricSample{label("step", step.name), unixSeconds(s.Time)})
+				break
+			}
+		}
+	}
+	m.gauge("snapraid_last_step_timestamp_seconds", "Unix time of the most recent run that executed the step.",
+		stepTimes...,
+	)
+}
+
+// runSteps lists the go-snapraid steps in execution order.
+var runSteps = []struct {
+	name     string
+	duration func(snapraid.RunTimings) time.Duration
+}{
+	{"touch", func(t snapraid.RunTimings) time.Duration { return t.Touch }},
+	{"diff", func(t snapraid.RunTimings) time.Duration { return t.Diff }},
+	{"sync", func(t snapraid.RunTimings) time.Duration { return t.Sync }},
+	{"scrub", func(t snapraid.RunTimings) time.Duration { return t.Scrub }},
+	{"smart", func(t snapraid.RunTimings) time.Duration { return t.Smart }},
+}
+
+// fileSamples returns one sample per file category of s.
+func fileSamples(s runindex.Summary, withEqual bool) []metricSample {
+	samples := []metricSample{
+		{label("category", "added"), float64(s.Added)},
+		{label("category", "removed"), float64(s.Removed)},
+		{label("category", "updated"), float64(s.Updated)},
+		{label("category", "moved"), float64(s.Moved)},
+		{label("category", "copied"), float64(s.Copied)},
+		{label("category", "restored"), float64(s.Restored)},
+	}
+	if withEqual {
+		samples = append(samples, metricSample{label("category", "equal"), float64(s.Equal)})
+	}
+	return samples
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gi8lino/go-snapraid-web/internal/runindex"
+	"github.com/gi8lino/go-snapraid/pkg/snapraid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics(t *testing.T) {
+	t.Parallel()
+
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+
+	t.Run("Exports latest run and history", func(t *testing.T) {
+		t.Parallel()
+
+		tmp := t.TempDir()
+		writeRunFile(t, tmp, "2025-06-01T03:00:00Z", snapraid.RunResult{
+			Result:  snapraid.DiffResult{Added: []string{"a", "b"}},
+			Timings: snapraid.RunTimings{Diff: time.Second, Sync: 90 * time.Second, Total: 91 * time.Second},
+		})
+		require.NoError(t, os.WriteFile(
+			filepath.Join(tmp, "2025-06-02T03:00:00Z.json"),
+			[]byte(`{"result":{"equal":7,"removed_files":["c"]},"timings":{"diff":2000000000,"total":2000000000},"error":"sync failed"}`),
+			0o600,
+		))
+
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		rec := httptest.NewRecorder()
+		Metrics(runindex.Sources{{Name: "default", Index: loadIndex(t, tmp, logger)}}).ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "text/plain; version=0.0.4; charset=utf-8", rec.Header().Get("Content-Type"))
+
+		body := rec.Body.String()
+		assert.Contains(t, body, "# TYPE snapraid_runs gauge\n")
+		assert.Contains(t, body, `snapraid_runs{source="default",status="success"} 1`+"\n")
+		assert.Contains(t, body, `snapraid_runs{source="default",status="partial"} 1`+"\n")
+		assert.Contains(t, body, `snapraid_history_files{source="default",category="added"} 2`+"\n")
+		assert.Contains(t, body, `snapraid_history_files{source="default",category="removed"} 1`+"\n")
+		assert.Contains(t, body, `snapraid_last_run_timestamp_seconds{source="default"} 1.7488332e+09`+"\n")
+		assert.Contains(t, body, `snapraid_last_run_success{source="default"} 0`+"\n")
+		assert.Contains(t, body, `snapraid_last_run_step_duration_seconds{source="default",step="diff"} 2`+"\n")
+		assert.Contains(t, body, `snapraid_last_run_step_duration_seconds{source="default",step="total"} 2`+"\n")
+		assert.Contains(t, body, `snapraid_last_run_files{source="default",category="removed"} 1`+"\n")
+		assert.Contains(t, body, `snapraid_last_run_files{source="default",category="equal"} 7`+"\n")
+		assert.Contains(t, body, `snapraid_last_run_anomaly{source="default",category="removed"} 0`+"\n")
+		assert.Contains(t, body, `snapraid_last_success_timestamp_seconds{source="default"} 1.7487468e+09`+"\n")
+		assert.Contains(t, body, `snapraid_last_step_timestamp_seconds{source="default",step="diff"} 1.7488332e+09`+"\n")
+		assert.Contains(t, body, `snapraid_last_step_timestamp_seconds{source="default",step="sync"} 1.7487468e+09`+"\n")
+		assert.NotContains(t, body, `snapraid_last_step_timestamp_seconds{source="default",step="scrub"}`)
+	})
+
+	t.Run("No runs", func(t *testing.T) {
+		t.Parallel()
+
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		rec := httptest.NewRecorder()
+		Metrics(runindex.Sources{{Name: "default", Index: loadIndex(t, t.TempDir(), logger)}}).ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		body := rec.Body.String()
+		assert.Contains(t, body, `snapraid_runs{source="default",status="success"} 0`+"\n")
+		assert.NotContains(t, body, "snapraid_last_run_timestamp_seconds")
+	})
+
+	t.Run("Multiple sources", func(t *testing.T) {
+		t.Parallel()
+
+		media, backup := t.TempDir(), t.TempDir()
+		writeRunFile(t, media, "2025-06-01T03:00:00Z", snapraid.RunResult{})
+
+		sources := runindex.Sources{
+			{Name: "media", Index: loadIndex(t, media, logger)},
+			{Name: "backup", Index: loadIndex(t, backup, logger)},
+		}
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		rec := httptest.NewRecorder()
+		Metrics(sources).ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		body := rec.Body.String()
+		assert.Equal(t, 1, strings.Count(body, "# TYPE snapraid_runs gauge\n"))
+		assert.Contains(t, body, `snapraid_runs{source="media",status="success"} 1`+"\n")
+		assert.Contains(t, body, `snapraid_runs{source="backup",status="success"} 0`+"\n")
+		assert.Contains(t, body, `snapraid_last_run_timestamp_seconds{source="media"} 1.7487468e+09`+"\n")
+		assert.NotContains(t, body, `snapraid_last_run_timestamp_seconds{source="backup"}`)
+	})
+}
@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"cmp"
+	"fmt"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/gi8lino/go-snapraid-web/internal/runindex"
+)
+
+// overviewColumns maps the sortable overview columns to their comparison.
+var overviewColumns = map[string]func(a, b runindex.Summary) int{
+	"date":   func(a, b runindex.Summary) int { return a.Time.Compare(b.Time) },
+	"status": func(a, b runindex.Summary) int { return cmp.Compare(a.Status, b.Status) },
+	"total":  func(a, b runindex.Summary) int { return cmp.Compare(a.Total(), b.Total()) },
+	"touch":  func(a, b runindex.Summary) int { return cmp.Compare(a.Timings.Touch, b.Timings.Touch) },
+	"diff":   func(a, b runindex.Summary) int { return cmp.Compare(a.Timings.Diff, b.Timings.Diff) },
+	"sync":   func(a, b runindex.Summary) int { return cmp.Compare(a.Timings.Sync, b.Timings.Sync) },
+	"scrub":  func(a, b runindex.Summary) int { return cmp.Compare(a.Timings.Scrub, b.Timings.Scrub) },
+	"smart":  func(a, b runindex.Summary) int { return cmp.Compare(a.Timings.Smart, b.Timings.Smart) },
+	"time":   func(a, b runindex.Summary) int { return cmp.Compare(a.Timings.Total, b.Timings.Total) },
+}
+
+// dateRange selects runs by time. Zero bounds are open.
+type dateRange struct {
+	from time.Time // inclusive
+	to   time.Time // exclusive
+}
+
+// parseDateRange parses the "from" and "to" query parameters. Both accept a
+// date (2006-01-02, UTC) or an RFC3339 timestamp; a date as "to" includes
+// the whole day.
+func parseDateRange(values url.Values) (dateRange, error) {
+	var r dateRange
+	var err error
+	if r.from, err = parseRangeBound(values.Get("from"), false); err != nil {
+		return dateRange{}, fmt.Errorf("invalid from: %w", err)
+	}
+	if r.to, err = parseRangeBound(values.Get("to"), true); err != nil {
+		return dateRange{}, fmt.Errorf("invalid to: %w", err)
+	}
+	if !r.from.IsZero() && !r.to.IsZero() && !r.from.Before(r.to) {
+		return dateRange{}, fmt.Errorf("from must be before to")
+	}
+	return r, nil
+}
+
+// parseRangeBound parses a single date range bound. If endOfDay is set, a
+// plain date is moved to the start of the following day.
+func parseRangeBound(s string, endOfDay bool) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		if endOfDay {
+			t = t.AddDate(0, 0, 1)
+		}
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is neither a date nor an RFC3339 timestamp", s)
+	}
+	return t, nil
+}
+
+// contains reports whether t lies within the range.
+func (r dateRange) contains(t time.Time) bool {
+	return (r.from.IsZero() || !t.Before(r.from)) && (r.to.IsZero() || t.Before(r.to))
+}
+
+// apply returns the summaries within the range, keeping their order.
+func (r dateRange) apply(summaries []runindex.Summary) []runindex.Summary {
+	if r.from.IsZero() && r.to.IsZero() {
+		return summaries
+	}
+	filtered := make([]runindex.Summary, 0, len(summaries))
+	for _, s := range summaries {
+		if r.contains(s.Time) {
+			filtered = append(filtered, s)
+		}
+	}
+	return filtered
+}
+
+// overviewQuery holds the filter, sort order and page of the overview.
+type overviewQuery struct {
+	pageQuery
+	status statusFilter
+	dates  dateRange
+	sort   string // key of overviewColumns
+	desc   bool
+}
+
+// parseOverviewQuery parses the overview query parameters:
+// status, from, to, sort, dir (asc or desc), page and size.
+func parseOverviewQuery(values url.Values) (overviewQuery, error) {
+	q := overviewQuery{sort: "date", desc: true}
+
+	var err error
+	if q.pageQuery, err = parsePageQuery(values, defaultPageSize); err != nil {
+		return overviewQuery{}, err
+	}
+	if q.status, err = parseStatusFilter(values.Get("status")); err != nil {
+		return overviewQuery{}, err
+	}
+	if q.dates, err = parseDateRange(values); err != nil {
+		return overviewQuery{}, err
+	}
+
+	if s := values.Get("sort"); s != "" {
+		if _, ok := overviewColumns[s]; !ok {
+			return overviewQuery{}, fmt.Errorf("invalid sort column %q", s)
+		}
+		q.sort = s
+	}
+	switch dir := values.Get("dir"); dir {
+	case "":
+	case "asc", "desc":
+		q.desc = dir == "desc"
+	default:
+		return overviewQuery{}, fmt.Errorf("invalid sort direction %q", dir)
+	}
+
+	return q, nil
+}
+
+// filter returns the summaries matching the status and date range, sorted.
+func (q overviewQuery) filter(summaries []runindex.Summary) []runindex.Summary {
+	filtered := q.dates.apply(q.status.apply(summaries))
+
+	sorted := slices.Clone(filtered)
+	compare := overviewColumns[q.sort]
+	slices.SortStableFunc(sorted, func(a, b runindex.Summary) int {
+		if c := compare(a, b); c != 0 {
+			if q.desc {
+				return -c
+			}
+			return c
+		}
+		return b.Time.Compare(a.Time) // newest first among equal values
+	})
+	return sorted
+}
+
+// exportValues returns the source and filter parameters of the overview, so
+// an export contains the same runs regardless of sort order and page.
+func (q overviewQuery) exportValues() url.Values {
+	values := url.Values{}
+	for _, k := range []string{"source", "status", "from", "to"} {
+		if v := q.values.Get(k); v != "" {
+			values.Set(k, v)
+		}
+	}
+	return values
+}
+
+// SortLink is a sortable column header of the overview.
+type SortLink struct {
+	Label string // column title
+	Query string // query string sorting by the column
+	Sort  string // "ascending", "descending" or empty if not sorted by the column
+}
+
+// sortLinks returns the column headers of the overview. Clicking the sorted
+// column toggles the direction; other columns start descending.
+func (q overviewQuery) sortLinks() []SortLink {
+	columns := []struct{ key, label string }{
+		{"date", "Date"},
+		{"status", "Status"},
+		{"total", "Total"},
+		{"touch", "Touch"},
+		{"diff", "Diff"},
+		{"sync", "Sync"},
+		{"scrub", "Scrub"},
+		{"smart", "Smart"},
+		{"time", "Total Time"},
+	}
+
+	links := make([]SortLink, 0, len(columns))
+	for _, c := range columns {
+		link := SortLink{Label: c.label}
+		dir := "desc"
+		if c.key == q.sort {
+			link.Sort = "ascending"
+			if q.desc {
+				link.Sort = "descending"
+				dir = "asc"
+			}
+		}
+		link.Query = q.with("sort", c.key, "dir", dir, "page", "")
+		links = append(links, link)
+	}
+	return links
+}
+
+// dateInput formats a range bound for an HTML date input.
+func dateInput(t time.Time, endOfDay bool) string {
+	if t.IsZero() {
+		return ""
+	}
+	if endOfDay {
+		t = t.Add(-time.Nanosecond)
+	}
+	return t.UTC().Format(time.DateOnly)
+}
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gi8lino/go-snapraid-web/internal/runindex"
+	"github.com/gi8lino/go-snapraid/pkg/snapraid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseOverviewQuery(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Defaults", func(t *testing.T) {
+		t.Parallel()
+
+		q, err := parseOverviewQuery(url.Values{})
+		require.NoError(t, err)
+		assert.Equal(t, "date", q.sort)
+		assert.True(t, q.desc)
+		assert.Equal(t, 1, q.page)
+		assert.Equal(t, defaultPageSize, q.pageSize)
+	})
+
+	t.Run("Custom values", func(t *testing.T) {
+		t.Parallel()
+
+		q, err := parseOverviewQuery(url.Values{
+			"sort": {"sync"},
+			"dir":  {"asc"},
+			"page": {"3"},
+			"size": {"10000"},
+			"from": {"2025-06-01"},
+			"to":   {"2025-06-30"},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "sync", q.sort)
+		assert.False(t, q.desc)
+		assert.Equal(t, 3, q.page)
+		assert.Equal(t, maxPageSize, q.pageSize)
+		assert.Equal(t, time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC), q.dates.from)
+		assert.Equal(t, time.Date(2025, 7, 1, 0, 0, 0, 0, time.UTC), q.dates.to)
+	})
+
+	tests := []struct {
+		name   string
+		values url.Values
+		err    string
+	}{
+		{name: "invalid status", values: url.Values{"status": {"nope"}}, err: `invalid status "nope"`},
+		{name: "invalid sort", values: url.Values{"sort": {"name"}}, err: `invalid sort column "name"`},
+		{name: "invalid direction", values: url.Values{"dir": {"up"}}, err: `invalid sort direction "up"`},
+		{name: "invalid page", values: url.Values{"page": {"0"}}, err: `invalid page "0"`},
+		{name: "invalid size", values: url.Values{"size": {"x"}}, err: `invalid page size "x"`},
+		{name: "invalid from", values: url.Values{"from": {"June"}}, err: `invalid from: "June" is neither a date nor an RFC3339 timestamp`},
+		{name: "empty range", values: url.Values{"from": {"2025-06-02"}, "to": {"2025-06-01"}}, err: "from must be before to"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := parseOverviewQuery(tt.values)
+			assert.EqualError(t, err, tt.err)
+		})
+	}
+}
+
+func TestOverviewQuery_filter(t *testing.T) {
+	t.Parallel()
+
+	day := func(d int) time.Time { return time.Date(2025, 6, d, 3, 0, 0, 0, time.UTC) }
+	summaries := []runindex.Summary{
+		{ID: "4", Time: day(4), Status: runindex.StatusSuccess, Timings: snapraid.RunTimings{Sync: 3 * time.Second}},
+		{ID: "3", Time: day(3), Status: runindex.StatusFailed, Timings: snapraid.RunTimings{Sync: 1 * time.Second}},
+		{ID: "2", Time: day(2), Status: runindex.StatusSuccess, Timings: snapraid.RunTimings{Sync: 3 * time.Second}},
+		{ID: "1", Time: day(1), Status: runindex.StatusSuccess, Timings: snapraid.RunTimings{Sync: 2 * time.Second}},
+	}
+	ids := func(s []runindex.Summary) []string {
+		var out []string
+		for _, x := range s {
+			out = append(out, x.ID)
+		}
+		return out
+	}
+
+	tests := []struct {
+		name   string
+		values url.Values
+		want   []string
+	}{
+		{name: "newest first by default", values: url.Values{}, want: []string{"4", "3", "2", "1"}},
+		{name: "oldest first", values: url.Values{"dir": {"asc"}}, want: []string{"1", "2", "3", "4"}},
+		{name: "sync descending, ties newest first", values: url.Values{"sort": {"sync"}}, want: []string{"4", "2", "1", "3"}},
+		{name: "sync ascending", values: url.Values{"sort": {"sync"}, "dir": {"asc"}}, want: []string{"3", "1", "4", "2"}},
+		{name: "date range", values: url.Values{"from": {"2025-06-02"}, "to": {"2025-06-03"}}, want: []string{"3", "2"}},
+		{name: "status and range", values: url.Values{"status": {"success"}, "to": {"2025-06-03"}}, want: []string{"2", "1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			q, err := parseOverviewQuery(tt.values)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, ids(q.filter(summaries)))
+		})
+	}
+}
+
+func TestOverviewQuery_sortLinks(t *testing.T) {
+	t.Parallel()
+
+	q, err := parseOverviewQuery(url.Values{"sort": {"sync"}, "page": {"4"}})
+	require.NoError(t, err)
+
+	links := q.sortLinks()
+	require.Len(t, links, 9)
+	assert.Equal(t, SortLink{Label: "Date", Query: "dir=desc&sort=date"}, links[0])
+	assert.Equal(t, SortLink{Label: "Sync", Query: "dir=asc&sort=sync", Sort: "descending"}, links[5])
+}
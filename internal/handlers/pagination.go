@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+)
+
+const (
+	defaultPageSize = 50  // rows per page if not requested otherwise
+	maxPageSize     = 500 // upper bound for the requested page size
+)
+
+// pageSizes are the page sizes offered in the dashboard.
+var pageSizes = []int{25, defaultPageSize, 100, 250, maxPageSize}
+
+// pageQuery holds the requested page of a paginated table.
+type pageQuery struct {
+	values   url.Values // raw parameters, used to build links
+	page     int        // 1-based
+	pageSize int
+}
+
+// parsePageQuery parses the "page" and "size" query parameters.
+// The page size is capped at maxPageSize.
+func parsePageQuery(values url.Values, defaultSize int) (pageQuery, error) {
+	q := pageQuery{values: values, page: 1, pageSize: defaultSize}
+
+	var err error
+	if s := values.Get("page"); s != "" {
+		if q.page, err = strconv.Atoi(s); err != nil || q.page < 1 {
+			return pageQuery{}, fmt.Errorf("invalid page %q", s)
+		}
+	}
+	if s := values.Get("size"); s != "" {
+		if q.pageSize, err = strconv.Atoi(s); err != nil || q.pageSize < 1 {
+			return pageQuery{}, fmt.Errorf("invalid page size %q", s)
+		}
+		q.pageSize = min(q.pageSize, maxPageSize)
+	}
+
+	return q, nil
+}
+
+// with returns the query string with the given parameters replaced.
+// Empty values remove the parameter.
+func (q pageQuery) with(kv ...string) string {
+	values := url.Values{}
+	for k, v := range q.values {
+		values[k] = slices.Clone(v)
+	}
+	for i := 0; i+1 < len(kv); i += 2 {
+		if kv[i+1] == "" {
+			values.Del(kv[i])
+			continue
+		}
+		values.Set(kv[i], kv[i+1])
+	}
+	return values.Encode()
+}
+
+// Pagination describes the current page of a paginated table.
+type Pagination struct {
+	Page       int    // current page, 1-based
+	PageSize   int    // rows per page
+	TotalRows  int    // number of rows across all pages
+	TotalPages int    // number of pages, at least 1
+	First      int    // 1-based number of the first row on the page, 0 if empty
+	Last       int    // 1-based number of the last row on the page
+	PrevQuery  string // query string of the previous page, empty on the first page
+	NextQuery  string // query string of the next page, empty on the last page
+	Pages      []PageLink
+}
+
+// PageLink is a link to a page of a paginated table.
+type PageLink struct {
+	Number  int    // page number, 0 for a gap
+	Query   string // query string of the page
+	Current bool   // true for the current page
+}
+
+// paginate returns the rows of the requested page and its pagination.
+// A page beyond the last one selects the last page.
+func paginate[T any](rows []T, q pageQuery) ([]T, Pagination) {
+	p := Pagination{
+		PageSize:   q.pageSize,
+		TotalRows:  len(rows),
+		TotalPages: max(1, (len(rows)+q.pageSize-1)/q.pageSize),
+	}
+	p.Page = min(q.page, p.TotalPages)
+
+	start := (p.Page - 1) * p.PageSize
+	end := min(start+p.PageSize, len(rows))
+	if start < end {
+		p.First, p.Last = start+1, end
+	}
+
+	pageQuery := func(n int) string { return q.with("page", strconv.Itoa(n)) }
+	if p.Page > 1 {
+		p.PrevQuery = pageQuery(p.Page - 1)
+	}
+	if p.Page < p.TotalPages {
+		p.NextQuery = pageQuery(p.Page + 1)
+	}
+
+	// first, last and two pages around the current one, gaps in between
+	last := 0
+	for n := 1; n <= p.TotalPages; n++ {
+		if n != 1 && n != p.TotalPages && (n < p.Page-2 || n > p.Page+2) {
+			continue
+		}
+		if last != 0 && n > last+1 {
+			p.Pages = append(p.Pages, PageLink{})
+		}
+		p.Pages = append(p.Pages, PageLink{Number: n, Query: pageQuery(n), Current: n == p.Page})
+		last = n
+	}
+
+	return rows[start:end], p
+}
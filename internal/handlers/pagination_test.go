@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePageQuery(t *testing.T) {
+	t.Parallel()
+
+	q, err := parsePageQuery(url.Values{}, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 1, q.page)
+	assert.Equal(t, 10, q.pageSize)
+
+	q, err = parsePageQuery(url.Values{"page": {"2"}, "size": {"100000"}}, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 2, q.page)
+	assert.Equal(t, maxPageSize, q.pageSize)
+
+	_, err = parsePageQuery(url.Values{"page": {"-1"}}, 10)
+	assert.EqualError(t, err, `invalid page "-1"`)
+}
+
+func TestPaginate(t *testing.T) {
+	t.Parallel()
+
+	rows := make([]int, 23)
+	for i := range rows {
+		rows[i] = i + 1
+	}
+
+	t.Run("Middle page", func(t *testing.T) {
+		t.Parallel()
+
+		q, err := parseOverviewQuery(url.Values{"size": {"2"}, "page": {"6"}, "status": {"success"}})
+		require.NoError(t, err)
+
+		page, p := paginate(rows, q.pageQuery)
+		assert.Equal(t, []int{11, 12}, page)
+		assert.Equal(t, 6, p.Page)
+		assert.Equal(t, 12, p.TotalPages)
+		assert.Equal(t, 11, p.First)
+		assert.Equal(t, 12, p.Last)
+		assert.Equal(t, "page=5&size=2&status=success", p.PrevQuery)
+		assert.Equal(t, "page=7&size=2&status=success", p.NextQuery)
+
+		var numbers []int
+		for _, l := range p.Pages {
+			numbers = append(numbers, l.Number)
+		}
+		assert.Equal(t, []int{1, 0, 4, 5, 6, 7, 8, 0, 12}, numbers)
+		assert.True(t, p.Pages[4].Current)
+	})
+
+	t.Run("Page beyond last", func(t *testing.T) {
+		t.Parallel()
+
+		q, err := parseOverviewQuery(url.Values{"size": {"10"}, "page": {"9"}})
+		require.NoError(t, err)
+
+		page, p := paginate(rows, q.pageQuery)
+		assert.Equal(t, []int{21, 22, 23}, page)
+		assert.Equal(t, 3, p.Page)
+		assert.Empty(t, p.NextQuery)
+	})
+
+	t.Run("No rows", func(t *testing.T) {
+		t.Parallel()
+
+		q, err := parseOverviewQuery(url.Values{})
+		require.NoError(t, err)
+
+		page, p := paginate([]int{}, q.pageQuery)
+		assert.Empty(t, page)
+		assert.Equal(t, 1, p.TotalPages)
+		assert.Equal(t, 0, p.First)
+		assert.Empty(t, p.PrevQuery)
+		assert.Empty(t, p.NextQuery)
+	})
+}
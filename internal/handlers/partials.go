@@ -1,7 +1,6 @@
 package handlers
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
 	"html/template"
@@ -9,50 +8,62 @@ import (
 	"io/fs"
 	"log/slog"
 	"net/http"
-	"os"
+	"net/url"
 	"path"
-	"path/filepath"
-	"slices"
-	"sort"
-	"strings"
 	"time"
 
+	"github.com/gi8lino/go-snapraid-web/internal/auth"
+	"github.com/gi8lino/go-snapraid-web/internal/runindex"
 	"github.com/gi8lino/go-snapraid-web/internal/utils"
-	"github.com/gi8lino/go-snapraid/pkg/snapraid"
 )
 
 // OverviewView represents a summarized SnapRAID run for display in the overview table.
 type OverviewView struct {
-	Timestamp string        // original RFC3339 timestamp used as run ID
-	Date      string        // formatted timestamp for display
-	Total     int           // total number of file changes
-	TouchTime time.Duration // duration of `touch` step
-	DiffTime  time.Duration // duration of `diff` step
-	SyncTime  time.Duration // duration of `sync` step
-	ScrubTime time.Duration // duration of `scrub` step
-	SmartTime time.Duration // duration of `smart` step
-	TotalTime time.Duration // total runtime duration
+	Timestamp string             // original RFC3339 timestamp used as run ID
+	Date      string             // formatted timestamp for display
+	Total     int                // total number of file changes
+	TouchTime time.Duration      // duration of `touch` step
+	DiffTime  time.Duration      // duration of `diff` step
+	SyncTime  time.Duration      // duration of `sync` step
+	ScrubTime time.Duration      // duration of `scrub` step
+	SmartTime time.Duration      // duration of `smart` step
+	TotalTime time.Duration      // total runtime duration
+	Status    string             // outcome of the run: success, failed or partial
+	Error     string             // error message, empty if the run succeeded
+	Anomalies []runindex.Anomaly // categories exceeding the warning thresholds
 }
 
 // RunView represents detailed file-level changes for a specific SnapRAID run.
 type RunView struct {
-	Timestamp     string   // run ID / timestamp
-	Date          string   // formatted run timestamp
-	AddedFiles    []string // list of added files
-	RemovedFiles  []string // list of removed files
-	UpdatedFiles  []string // list of updated files
-	MovedFiles    []string // list of moved files
-	CopiedFiles   []string // list of copied files
-	RestoredFiles []string // list of restored files
+	Timestamp  string             // run ID / timestamp
+	Date       string             // formatted run timestamp
+	Status     string             // outcome of the run: success, failed or partial
+	Error      string             // error message, empty if the run succeeded
+	Anomalies  []runindex.Anomaly // categories exceeding the warning thresholds
+	Categories []CategoryCount    // number of changed files per category
+	View       RunViewMode        // how the changed files are displayed
+	Tree       *TreeNode          // changed files grouped by directory, set in tree view
+	TreePath   []TreeCrumb        // breadcrumb trail to the displayed tree directory
+	Exports    []ExportLink       // downloads of the changed files
+	HidePaths  bool               // whether only the counts are shown, without files
 }
 
-type runResultCompat struct {
-	Timestamp string              `json:"timestamp"`
-	Result    snapraid.DiffResult `json:"result"`
-	Timings   snapraid.RunTimings `json:"timings"`
-	Error     json.RawMessage     `json:"error"`
+// CategoryCount is the number of changed files of a category of a run. The
+// files themselves are loaded page by page from the files partial.
+type CategoryCount struct {
+	Category runindex.Category
+	Count    int
 }
 
+// RunFile is a changed file of a run.
+type RunFile struct {
+	Path string `json:"path"` // path with SnapRAID escapes decoded, for display
+	Raw  string `json:"raw"`  // shell-escaped path as written by go-snapraid, for copying
+}
+
+// pathSections are the partial sections listing file paths.
+var pathSections = map[string]bool{"files": true, "search": true, "compare": true, "file": true}
+
 // notFoundError is returned by the handler when a requested partial section is not found.
 type notFoundError struct {
 	msg string
@@ -64,7 +75,7 @@ func (e *notFoundError) Error() string { return e.msg }
 // PartialHandler returns an HTTP handler that renders HTML templates for partial sections.
 func PartialHandler(
 	webFS fs.FS,
-	outputDir string,
+	index *runindex.Index,
 	logger *slog.Logger,
 ) http.HandlerFunc {
 	tmpl := template.Must(
@@ -74,32 +85,105 @@ func PartialHandler(
 				webFS,
 				"web/templates/overview.html",
 				"web/templates/run.html",
+				"web/templates/trends.html",
+				"web/templates/search.html",
+				"web/templates/compare.html",
+				"web/templates/file.html",
 			),
 	)
 
 	return func(w http.ResponseWriter, r *http.Request) {
 		section := path.Base(r.URL.Path)
+		hidePaths := auth.PathsHidden(r.Context())
+		if hidePaths && pathSections[section] {
+			http.Error(w, "forbidden, file paths are hidden from viewers", http.StatusForbidden)
+			return
+		}
 		var err error
 
 		switch section {
 		case "overview":
-			err = renderOverview(w, tmpl, outputDir)
+			q, perr := parseOverviewQuery(r.URL.Query())
+			if perr != nil {
+				http.Error(w, perr.Error(), http.StatusBadRequest)
+				return
+			}
+			err = renderOverview(w, tmpl, index, q)
 
 		case "run":
 			runID := r.URL.Query().Get("id")
 			if runID == "" {
-				runID, err = findLatestRunID(outputDir)
-				if err != nil {
+				latest, ok := index.Latest()
+				if !ok {
+					err = errors.New("no run files found")
 					break
 				}
+				runID = latest.ID
+			}
+			view, perr := parseRunViewMode(r.URL.Query().Get("view"))
+			if perr != nil {
+				http.Error(w, perr.Error(), http.StatusBadRequest)
+				return
+			}
+			if hidePaths {
+				view = RunViewList
+			}
+			err = renderRun(w, tmpl, index, runID, view, r.URL.Query().Get("path"), sourceValues(r.URL.Query()), hidePaths)
+			if errors.As(err, new(*notFoundError)) {
+				logger.Error("run not found", "error", err)
+				http.NotFound(w, r)
+				return
+			}
+
+		case "files":
+			q, perr := parseRunFilesQuery(r.URL.Query())
+			if perr != nil {
+				http.Error(w, perr.Error(), http.StatusBadRequest)
+				return
 			}
-			err = renderRun(w, tmpl, outputDir, runID)
+			err = renderRunFiles(w, tmpl, index, r.URL.Query().Get("id"), q)
 			if errors.As(err, new(*notFoundError)) {
-				logger.Error("no run files found or glob failed", "error", err)
+				logger.Error("run not found", "error", err)
 				http.NotFound(w, r)
 				return
 			}
 
+		case "trends":
+			bucket, perr := parseTrendBucket(r.URL.Query().Get("bucket"))
+			if perr != nil {
+				http.Error(w, perr.Error(), http.StatusBadRequest)
+				return
+			}
+			err = tmpl.ExecuteTemplate(w, "trends", struct {
+				Bucket TrendBucket
+			}{
+				Bucket: bucket,
+			})
+
+		case "search":
+			q, perr := parseSearchQuery(r)
+			if perr != nil {
+				http.Error(w, perr.Error(), http.StatusBadRequest)
+				return
+			}
+			err = renderSearch(w, tmpl, index, q)
+
+		case "compare":
+			err = renderCompare(w, tmpl, index, r.URL.Query().Get("from"), r.URL.Query().Get("to"))
+			if errors.As(err, new(*notFoundError)) {
+				logger.Error("compare runs not found", "error", err)
+				http.NotFound(w, r)
+				return
+			}
+
+		case "file":
+			path := r.URL.Query().Get("path")
+			if path == "" {
+				http.Error(w, "missing path", http.StatusBadRequest)
+				return
+			}
+			err = renderTimeline(w, tmpl, index, path)
+
 		default:
 			http.NotFound(w, r)
 		}
@@ -111,123 +195,142 @@ func PartialHandler(
 	}
 }
 
-// renderOverview renders the overview partial with a summary of all runs.
+// renderOverview renders one page of the overview table.
 func renderOverview(
 	w io.Writer,
 	tmpl *template.Template,
-	outputDir string,
+	index *runindex.Index,
+	q overviewQuery,
 ) error {
-	matches, err := filepath.Glob(filepath.Join(outputDir, "*.json"))
-	if err != nil {
-		return fmt.Errorf("glob failed: %w", err)
-	}
-
-	var rows []OverviewView
-	for _, fullPath := range matches {
-		timestampStr := strings.TrimSuffix(filepath.Base(fullPath), ".json")
-		timestamp, err := time.Parse(time.RFC3339, timestampStr)
-		if err != nil {
-			continue
-		}
+	summaries, page := paginate(q.filter(index.List()), q.pageQuery)
 
-		f, err := os.Open(fullPath)
-		if err != nil {
-			return fmt.Errorf("open file %q failed: %w", fullPath, err)
-		}
-		defer f.Close() // nolint:errcheck
-
-		var result runResultCompat
-		if err := json.NewDecoder(f).Decode(&result); err != nil {
-			return fmt.Errorf("JSON decode of %q failed: %w", fullPath, err)
-		}
-
-		stats := result.Result
-		total := len(stats.Added) + len(stats.Removed) + len(stats.Updated) +
-			len(stats.Moved) + len(stats.Copied) + len(stats.Restored)
-
-		rows = append(rows, OverviewView{
-			Timestamp: timestampStr,
-			Date:      timestamp.Format(time.RFC3339),
-			Total:     total,
-			TouchTime: result.Timings.Touch,
-			DiffTime:  result.Timings.Diff,
-			SyncTime:  result.Timings.Sync,
-			ScrubTime: result.Timings.Scrub,
-			SmartTime: result.Timings.Smart,
-			TotalTime: result.Timings.Total,
-		})
+	rows := make([]OverviewView, 0, len(summaries))
+	for _, s := range summaries {
+		rows = append(rows, newOverviewView(s))
 	}
 
-	sort.Slice(rows, func(i, j int) bool {
-		return rows[i].Timestamp > rows[j].Timestamp
-	})
-
 	return tmpl.ExecuteTemplate(w, "overview", struct {
-		Rows []OverviewView
+		Rows       []OverviewView
+		Status     string // active status filter
+		From       string // start of the date range as 2006-01-02, empty if open
+		To         string // end of the date range as 2006-01-02, empty if open
+		Columns    []SortLink
+		Pagination Pagination
+		PageSizes  []int        // choices for the page size
+		Exports    []ExportLink // downloads of the filtered runs
 	}{
-		Rows: rows,
+		Rows:       rows,
+		Status:     q.status.String(),
+		From:       dateInput(q.dates.from, false),
+		To:         dateInput(q.dates.to, true),
+		Columns:    q.sortLinks(),
+		Pagination: page,
+		PageSizes:  pageSizes,
+		Exports:    exportLinks("/api/v1/export/runs", q.exportValues()),
 	})
 }
 
-// renderRun renders the detailed view for a single SnapRAID run.
+// newOverviewView converts an indexed run summary into its overview row.
+func newOverviewView(s runindex.Summary) OverviewView {
+	return OverviewView{
+		Timestamp: s.ID,
+		Date:      s.Time.Format(time.RFC3339),
+		Total:     s.Total(),
+		TouchTime: s.Timings.Touch,
+		DiffTime:  s.Timings.Diff,
+		SyncTime:  s.Timings.Sync,
+		ScrubTime: s.Timings.Scrub,
+		SmartTime: s.Timings.Smart,
+		TotalTime: s.Timings.Total,
+		Status:    string(s.Status),
+		Error:     s.Error,
+		Anomalies: s.Anomalies,
+	}
+}
+
+// renderRun renders the detailed view for a single SnapRAID run. In tree
+// view, only the changed files below treePath are shown. The source values
+// are kept in the export links. With hidePaths, only the counts are shown.
 func renderRun(
 	w io.Writer,
 	tmpl *template.Template,
-	outputDir string,
+	index *runindex.Index,
 	runID string,
+	view RunViewMode,
+	treePath string,
+	source url.Values,
+	hidePaths bool,
 ) error {
-	fullPath := filepath.Join(outputDir, runID+".json")
-	f, err := os.Open(fullPath)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return &notFoundError{fmt.Sprintf("run %q not found", runID)}
-		}
-		return fmt.Errorf("open detail file %q failed: %w", fullPath, err)
+	summary, ok := index.Get(runID)
+	if !ok {
+		return &notFoundError{fmt.Sprintf("run %q not found", runID)}
 	}
-	defer f.Close() // nolint:errcheck
 
-	var result runResultCompat
-	if err := json.NewDecoder(f).Decode(&result); err != nil {
-		return fmt.Errorf("JSON decode %q failed: %w", fullPath, err)
+	rv := RunView{
+		Timestamp:  runID,
+		Date:       summary.Timestamp,
+		Status:     string(summary.Status),
+		Error:      summary.Error,
+		Anomalies:  summary.Anomalies,
+		Categories: make([]CategoryCount, len(runindex.Categories)),
+		View:       view,
+		Exports:    exportLinks("/api/v1/export/runs/"+url.PathEscape(runID)+"/files", source),
+		HidePaths:  hidePaths,
 	}
-
-	// build dropdown list
-	matches, err := filepath.Glob(filepath.Join(outputDir, "*.json"))
-	if err != nil {
-		return fmt.Errorf("glob for dropdown failed: %w", err)
+	for i, c := range runindex.Categories {
+		rv.Categories[i] = CategoryCount{Category: c, Count: summary.Count(c)}
 	}
-	var allTimestamps []string
-	for _, file := range matches {
-		ts := strings.TrimSuffix(filepath.Base(file), ".json")
-		allTimestamps = append(allTimestamps, ts)
+
+	// The list view only shows counts, its files are loaded lazily.
+	if view == RunViewTree {
+		_, run, err := loadRun(index, runID)
+		if err != nil {
+			return err
+		}
+		tree, ok := buildTree(run, treePath)
+		if !ok && tree.Path != "" {
+			return &notFoundError{fmt.Sprintf("directory %q not found in run %q", tree.Path, runID)}
+		}
+		rv.Tree = tree
+		rv.TreePath = treeCrumbs(tree.Path)
 	}
-	slices.Sort(allTimestamps)
 
 	return tmpl.ExecuteTemplate(w, "run", struct {
 		Run           RunView
 		AllTimestamps []string
 	}{
-		Run: RunView{
-			Timestamp:     runID,
-			Date:          result.Timestamp,
-			AddedFiles:    result.Result.Added,
-			RemovedFiles:  result.Result.Removed,
-			UpdatedFiles:  result.Result.Updated,
-			MovedFiles:    result.Result.Moved,
-			CopiedFiles:   result.Result.Copied,
-			RestoredFiles: result.Result.Restored,
-		},
-		AllTimestamps: allTimestamps,
+		Run:           rv,
+		AllTimestamps: index.IDs(),
 	})
 }
 
-// findLatestRunID returns the most recent run file's ID from the output directory.
-func findLatestRunID(outputDir string) (string, error) {
-	matches, err := filepath.Glob(filepath.Join(outputDir, "*.json"))
-	if err != nil || len(matches) == 0 {
-		return "", fmt.Errorf("no run files found or glob failed: %w", err)
+// runFiles pairs the decoded and raw paths of a category of run.
+func runFiles(run runindex.Run, c runindex.Category) []RunFile {
+	paths, raw := run.Files(c), run.RawFiles(c)
+	files := make([]RunFile, len(paths))
+	for i := range paths {
+		files[i] = RunFile{Path: paths[i], Raw: raw[i]}
+	}
+	return files
+}
+
+// renderSearch renders the search form and, if a query was given, its matches.
+func renderSearch(
+	w io.Writer,
+	tmpl *template.Template,
+	index *runindex.Index,
+	q searchQuery,
+) error {
+	result := SearchResult{Query: q.query, Mode: q.mode}
+	if q.query != "" {
+		result = searchRuns(index, q)
 	}
-	slices.Sort(matches) // RFC3339 file names sort lexicographically by time
-	latest := filepath.Base(matches[len(matches)-1])
-	return strings.TrimSuffix(latest, ".json"), nil
+
+	return tmpl.ExecuteTemplate(w, "search", struct {
+		Result SearchResult
+		Limit  int
+	}{
+		Result: result,
+		Limit:  q.limit,
+	})
 }
@@ -12,6 +12,7 @@ import (
 	"testing/fstest"
 	"time"
 
+	"github.com/gi8lino/go-snapraid-web/internal/auth"
 	"github.com/gi8lino/go-snapraid/pkg/snapraid"
 	"github.com/stretchr/testify/assert"
 )
@@ -20,8 +21,12 @@ func TestPartialHandler(t *testing.T) {
 	t.Parallel()
 
 	fs := fstest.MapFS{
-		"web/templates/overview.html": &fstest.MapFile{Data: []byte(`{{define "overview"}}OK{{end}}`)},
-		"web/templates/run.html":      &fstest.MapFile{Data: []byte(`{{define "run"}}RUN{{end}}`)},
+		"web/templates/overview.html": &fstest.MapFile{Data: []byte(`{{define "overview"}}OK{{range .Rows}} {{.Timestamp}}:{{.Status}}{{end}}{{end}}`)},
+		"web/templates/run.html":      &fstest.MapFile{Data: []byte(`{{define "run"}}RUN {{.Run.View}}{{with .Run.Tree}} {{.Path}}:{{.Counts.Total}}{{else}}{{range .Run.Categories}}{{if .Count}} {{.Category}}:{{.Count}}{{end}}{{end}}{{end}}{{end}}{{define "runFiles"}}FILES {{.Page.Total}} {{.Pagination.Page}}/{{.Pagination.TotalPages}}{{range .Page.Files}} {{.Path}}{{end}}{{end}}`)},
+		"web/templates/trends.html":   &fstest.MapFile{Data: []byte(`{{define "trends"}}TRENDS {{.Bucket}}{{end}}`)},
+		"web/templates/search.html":   &fstest.MapFile{Data: []byte(`{{define "search"}}SEARCH{{range .Result.Matches}} {{.RunID}}:{{.Category}}:{{.Path}}{{end}}{{end}}`)},
+		"web/templates/compare.html":  &fstest.MapFile{Data: []byte(`{{define "compare"}}COMPARE {{.Comparison.From.ID}} {{.Comparison.To.ID}}{{range .Counts}} {{.Label}}:{{.Delta}}{{end}}{{end}}`)},
+		"web/templates/file.html":     &fstest.MapFile{Data: []byte(`{{define "file"}}FILE {{.Timeline.Path}}{{range .Timeline.Changes}} {{.RunID}}:{{.Category}}{{end}}{{end}}`)},
 	}
 
 	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
@@ -55,7 +60,7 @@ func TestPartialHandler(t *testing.T) {
 		jsonPath := filepath.Join(tmp, now.Format(time.RFC3339)+".json")
 		assert.NoError(t, os.WriteFile(jsonPath, data, 0o600))
 
-		handler := PartialHandler(fs, tmp, logger)
+		handler := PartialHandler(fs, loadIndex(t, tmp, logger), logger)
 
 		req := httptest.NewRequest("GET", "/partials/overview", nil)
 		rr := httptest.NewRecorder()
@@ -65,10 +70,285 @@ func TestPartialHandler(t *testing.T) {
 		assert.Contains(t, rr.Body.String(), "OK")
 	})
 
+	t.Run("Filters overview by status", func(t *testing.T) {
+		t.Parallel()
+
+		tmp := t.TempDir()
+		assert.NoError(t, os.WriteFile(filepath.Join(tmp, "2025-06-01T03:00:00Z.json"), []byte(`{"error":null}`), 0o600))
+		assert.NoError(t, os.WriteFile(filepath.Join(tmp, "2025-06-02T03:00:00Z.json"), []byte(`{"error":"boom"}`), 0o600))
+
+		handler := PartialHandler(fs, loadIndex(t, tmp, logger), logger)
+
+		req := httptest.NewRequest("GET", "/partials/overview?status=failed", nil)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "OK 2025-06-02T03:00:00Z:failed", rr.Body.String())
+	})
+
+	t.Run("Paginates overview", func(t *testing.T) {
+		t.Parallel()
+
+		tmp := t.TempDir()
+		for _, id := range []string{"2025-06-01T03:00:00Z", "2025-06-02T03:00:00Z", "2025-06-03T03:00:00Z"} {
+			writeRunFile(t, tmp, id, snapraid.RunResult{})
+		}
+		handler := PartialHandler(fs, loadIndex(t, tmp, logger), logger)
+
+		req := httptest.NewRequest("GET", "/partials/overview?size=2&page=2", nil)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "OK 2025-06-01T03:00:00Z:success", rr.Body.String())
+	})
+
+	t.Run("Invalid status filter", func(t *testing.T) {
+		t.Parallel()
+
+		handler := PartialHandler(fs, loadIndex(t, t.TempDir(), logger), logger)
+
+		req := httptest.NewRequest("GET", "/partials/overview?status=unknown", nil)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("Renders trends", func(t *testing.T) {
+		t.Parallel()
+
+		handler := PartialHandler(fs, loadIndex(t, t.TempDir(), logger), logger)
+
+		req := httptest.NewRequest("GET", "/partials/trends?bucket=week", nil)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "TRENDS week", rr.Body.String())
+	})
+
+	t.Run("Renders search results", func(t *testing.T) {
+		t.Parallel()
+
+		tmp := t.TempDir()
+		assert.NoError(t, os.WriteFile(
+			filepath.Join(tmp, "2025-06-01T03:00:00Z.json"),
+			[]byte(`{"result":{"removed_files":["filme/a.mkv","filme/b.txt"]}}`),
+			0o600,
+		))
+		handler := PartialHandler(fs, loadIndex(t, tmp, logger), logger)
+
+		req := httptest.NewRequest("GET", "/partials/search?q=*.mkv&mode=glob", nil)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "SEARCH 2025-06-01T03:00:00Z:removed:filme/a.mkv", rr.Body.String())
+	})
+
+	t.Run("Renders run tree", func(t *testing.T) {
+		t.Parallel()
+
+		tmp := t.TempDir()
+		writeRunFile(t, tmp, "2025-06-01T03:00:00Z", snapraid.RunResult{
+			Result: snapraid.DiffResult{
+				Added:   []string{"serien/a/e1.mkv", "serien/a/e2.mkv", "filme/x.mkv"},
+				Removed: []string{"serien/b/e1.mkv"},
+			},
+		})
+		handler := PartialHandler(fs, loadIndex(t, tmp, logger), logger)
+
+		req := httptest.NewRequest("GET", "/partials/run?id=2025-06-01T03:00:00Z&view=tree&path=serien", nil)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "RUN tree serien:3", rr.Body.String())
+	})
+
+	t.Run("Renders run counts", func(t *testing.T) {
+		t.Parallel()
+
+		tmp := t.TempDir()
+		writeRunFile(t, tmp, "2025-06-01T03:00:00Z", snapraid.RunResult{
+			Result: snapraid.DiffResult{
+				Added:   []string{"a", "b"},
+				Removed: []string{"c"},
+			},
+		})
+		handler := PartialHandler(fs, loadIndex(t, tmp, logger), logger)
+
+		req := httptest.NewRequest("GET", "/partials/run?id=2025-06-01T03:00:00Z", nil)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "RUN list added:2 removed:1", rr.Body.String())
+	})
+
+	t.Run("Hides file paths from viewers", func(t *testing.T) {
+		t.Parallel()
+
+		tmp := t.TempDir()
+		writeRunFile(t, tmp, "2025-06-01T03:00:00Z", snapraid.RunResult{
+			Result: snapraid.DiffResult{Added: []string{"filme/a.mkv"}},
+		})
+		handler := PartialHandler(fs, loadIndex(t, tmp, logger), logger)
+		viewer := auth.Identity{Name: "bob", Role: auth.RoleViewer, HidePaths: true}
+
+		for _, path := range []string{
+			"/partials/files?id=2025-06-01T03:00:00Z&category=added",
+			"/partials/search?q=filme",
+			"/partials/compare",
+			"/partials/file?path=filme/a.mkv",
+		} {
+			req := httptest.NewRequest("GET", path, nil)
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req.WithContext(auth.WithIdentity(req.Context(), viewer)))
+
+			assert.Equal(t, http.StatusForbidden, rr.Code, path)
+		}
+
+		req := httptest.NewRequest("GET", "/partials/run?id=2025-06-01T03:00:00Z&view=tree", nil)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req.WithContext(auth.WithIdentity(req.Context(), viewer)))
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "RUN list added:1", rr.Body.String())
+	})
+
+	t.Run("Renders run files", func(t *testing.T) {
+		t.Parallel()
+
+		tmp := t.TempDir()
+		writeRunFile(t, tmp, "2025-06-01T03:00:00Z", snapraid.RunResult{
+			Result: snapraid.DiffResult{
+				Added: []string{"filme/a.mkv", "serien/b.mkv", "filme/c.mkv", "filme/d.mkv"},
+			},
+		})
+		handler := PartialHandler(fs, loadIndex(t, tmp, logger), logger)
+
+		req := httptest.NewRequest("GET", "/partials/files?id=2025-06-01T03:00:00Z&category=added&q=FILME&size=2&page=2", nil)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "FILES 3 2/2 filme/d.mkv", rr.Body.String())
+	})
+
+	t.Run("Run files invalid category", func(t *testing.T) {
+		t.Parallel()
+
+		tmp := t.TempDir()
+		writeRunFile(t, tmp, "2025-06-01T03:00:00Z", snapraid.RunResult{})
+		handler := PartialHandler(fs, loadIndex(t, tmp, logger), logger)
+
+		req := httptest.NewRequest("GET", "/partials/files?id=2025-06-01T03:00:00Z&category=deleted", nil)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("Run files not found", func(t *testing.T) {
+		t.Parallel()
+
+		tmp := t.TempDir()
+		handler := PartialHandler(fs, loadIndex(t, tmp, logger), logger)
+
+		req := httptest.NewRequest("GET", "/partials/files?id=2025-06-01T03:00:00Z&category=added", nil)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+
+	t.Run("Run tree directory not found", func(t *testing.T) {
+		t.Parallel()
+
+		tmp := t.TempDir()
+		writeRunFile(t, tmp, "2025-06-01T03:00:00Z", snapraid.RunResult{})
+		handler := PartialHandler(fs, loadIndex(t, tmp, logger), logger)
+
+		req := httptest.NewRequest("GET", "/partials/run?view=tree&path=missing", nil)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+
+	t.Run("Invalid run view", func(t *testing.T) {
+		t.Parallel()
+
+		tmp := t.TempDir()
+		writeRunFile(t, tmp, "2025-06-01T03:00:00Z", snapraid.RunResult{})
+		handler := PartialHandler(fs, loadIndex(t, tmp, logger), logger)
+
+		req := httptest.NewRequest("GET", "/partials/run?view=grid", nil)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("Renders compare", func(t *testing.T) {
+		t.Parallel()
+
+		tmp := t.TempDir()
+		writeRunFile(t, tmp, "2025-06-01T03:00:00Z", snapraid.RunResult{
+			Result: snapraid.DiffResult{Added: []string{"a"}},
+		})
+		writeRunFile(t, tmp, "2025-06-02T03:00:00Z", snapraid.RunResult{
+			Result: snapraid.DiffResult{Removed: []string{"a", "b"}},
+		})
+		handler := PartialHandler(fs, loadIndex(t, tmp, logger), logger)
+
+		req := httptest.NewRequest("GET", "/partials/compare", nil)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "COMPARE 2025-06-01T03:00:00Z 2025-06-02T03:00:00Z equal:0 added:-1 removed:2 updated:0 moved:0 copied:0 restored:0 total:1", rr.Body.String())
+	})
+
+	t.Run("Compare without runs", func(t *testing.T) {
+		t.Parallel()
+
+		handler := PartialHandler(fs, loadIndex(t, t.TempDir(), logger), logger)
+
+		req := httptest.NewRequest("GET", "/partials/compare", nil)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+
+	t.Run("Renders file timeline", func(t *testing.T) {
+		t.Parallel()
+
+		tmp := t.TempDir()
+		writeRunFile(t, tmp, "2025-06-01T03:00:00Z", snapraid.RunResult{
+			Result: snapraid.DiffResult{Added: []string{"a"}},
+		})
+		writeRunFile(t, tmp, "2025-06-02T03:00:00Z", snapraid.RunResult{
+			Result: snapraid.DiffResult{Removed: []string{"a"}},
+		})
+		handler := PartialHandler(fs, loadIndex(t, tmp, logger), logger)
+
+		req := httptest.NewRequest("GET", "/partials/file?path=a", nil)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "FILE a 2025-06-01T03:00:00Z:added 2025-06-02T03:00:00Z:removed", rr.Body.String())
+	})
+
 	t.Run("Run not found", func(t *testing.T) {
 		t.Parallel()
 
-		handler := PartialHandler(fs, t.TempDir(), logger)
+		handler := PartialHandler(fs, loadIndex(t, t.TempDir(), logger), logger)
 
 		req := httptest.NewRequest("GET", "/partials/run?id=nonexistent", nil)
 		rr := httptest.NewRecorder()
@@ -83,7 +363,7 @@ func TestPartialHandler(t *testing.T) {
 		req := httptest.NewRequest("GET", "/partials/doesnotexist", nil)
 		rr := httptest.NewRecorder()
 
-		handler := PartialHandler(fs, t.TempDir(), logger)
+		handler := PartialHandler(fs, loadIndex(t, t.TempDir(), logger), logger)
 		handler.ServeHTTP(rr, req)
 
 		assert.Equal(t, http.StatusNotFound, rr.Code)
@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"fmt"
+	"html/template"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gi8lino/go-snapraid-web/internal/api"
+	"github.com/gi8lino/go-snapraid-web/internal/auth"
+	"github.com/gi8lino/go-snapraid-web/internal/schedule"
+	"github.com/gi8lino/go-snapraid-web/internal/utils"
+)
+
+// JobSummary is the API representation of a scheduled job.
+type JobSummary struct {
+	Name     string        `json:"name"`
+	Schedule string        `json:"schedule"` // cron expression
+	Command  []string      `json:"command"`
+	Source   string        `json:"source"` // source receiving the run files
+	Paused   bool          `json:"paused"`
+	Running  bool          `json:"running"`
+	Next     time.Time     `json:"next,omitzero"`   // omitted before the scheduler runs
+	Missed   time.Time     `json:"missed,omitzero"` // latest scheduled time without a run, omitted if none
+	Fires    []FireSummary `json:"fires"`           // recent fires, newest first
+}
+
+// FireSummary is the API representation of a scheduled time of a job.
+type FireSummary struct {
+	Scheduled time.Time `json:"scheduled"`
+	Start     time.Time `json:"start,omitzero"` // omitted if skipped
+	End       time.Time `json:"end,omitzero"`   // omitted while running or if skipped
+	Status    string    `json:"status"`         // running, success, failed or skipped
+	Run       string    `json:"run,omitempty"`  // ID of the run file produced, omitted if none appeared
+	Error     string    `json:"error,omitempty"`
+	Output    string    `json:"output,omitempty"` // end of the command output of failed fires
+}
+
+// newJobSummary converts the state of a job into its API representation.
+func newJobSummary(j schedule.JobState) JobSummary {
+	fires := make([]FireSummary, 0, len(j.Fires))
+	for _, f := range j.Fires {
+		fires = append(fires, FireSummary{
+			Scheduled: f.Scheduled,
+			Start:     f.Start,
+			End:       f.End,
+			Status:    string(f.Status),
+			Run:       f.RunID,
+			Error:     f.Error,
+			Output:    f.Output,
+		})
+	}
+	return JobSummary{
+		Name:     j.Name,
+		Schedule: j.Schedule,
+		Command:  api.NonNil(j.Command),
+		Source:   j.Source,
+		Paused:   j.Paused,
+		Running:  j.Running,
+		Next:     j.Next,
+		Missed:   j.Missed,
+		Fires:    fires,
+	}
+}
+
+// redactJob removes the command arguments and output of j for users that
+// file paths are hidden from, since SnapRAID output names changed files and
+// arguments may point at directories.
+func redactJob(r *http.Request, j schedule.JobState) schedule.JobState {
+	if !auth.PathsHidden(r.Context()) {
+		return j
+	}
+	j.Command = j.Command[:min(len(j.Command), 1)]
+	fires := make([]schedule.Fire, len(j.Fires))
+	for i, f := range j.Fires {
+		f.Output = ""
+		fires[i] = f
+	}
+	j.Fires = fires
+	return j
+}
+
+// ScheduleAPI returns an HTTP handler listing all scheduled jobs as JSON,
+// in configured order. Command arguments and output are left out for users
+// that file paths are hidden from.
+func ScheduleAPI(scheduler *schedule.Scheduler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		jobs := scheduler.Jobs()
+		out := make([]JobSummary, 0, len(jobs))
+		for _, j := range jobs {
+			out = append(out, newJobSummary(redactJob(r, j)))
+		}
+
+		writeJSON(w, http.StatusOK, struct {
+			Jobs []JobSummary `json:"jobs"`
+		}{
+			Jobs: out,
+		})
+	}
+}
+
+// JobAPI returns an HTTP handler rendering the job selected by the {job}
+// path value as JSON.
+func JobAPI(scheduler *schedule.Scheduler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		j, ok := scheduler.Job(r.PathValue("job"))
+		if !ok {
+			writeJSONError(w, http.StatusNotFound, fmt.Sprintf("job %q not found", r.PathValue("job")))
+			return
+		}
+		writeJSON(w, http.StatusOK, newJobSummary(redactJob(r, j)))
+	}
+}
+
+// PauseJobAPI returns an HTTP handler pausing or resuming the job selected
+// by the {job} path value. It responds with the new state of the job.
+func PauseJobAPI(scheduler *schedule.Scheduler, paused bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		j, ok := scheduler.SetPaused(r.PathValue("job"), paused)
+		if !ok {
+			writeJSONError(w, http.StatusNotFound, fmt.Sprintf("job %q not found", r.PathValue("job")))
+			return
+		}
+		writeJSON(w, http.StatusOK, newJobSummary(redactJob(r, j)))
+	}
+}
+
+// SchedulePartial returns an HTTP handler rendering the scheduled jobs with
+// their recent fires. Only operators get buttons to pause and resume jobs.
+// Command output is left out for users that file paths are hidden from.
+func SchedulePartial(webFS fs.FS, scheduler *schedule.Scheduler, logger *slog.Logger) http.HandlerFunc {
+	tmpl := template.Must(
+		template.New("schedule").
+			Funcs(utils.FuncMap()).
+			ParseFS(webFS, "web/templates/schedule.html"),
+	)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		jobs := scheduler.Jobs()
+		for i, j := range jobs {
+			jobs[i] = redactJob(r, j)
+		}
+		data := struct {
+			Jobs     []schedule.JobState
+			Operator bool // whether the user may pause and resume jobs
+		}{
+			Jobs:     jobs,
+			Operator: auth.Allowed(r.Context(), auth.RoleOperator),
+		}
+		if err := tmpl.ExecuteTemplate(w, "schedule", data); err != nil {
+			logger.Error("render schedule partial", "error", err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+		}
+	}
+}
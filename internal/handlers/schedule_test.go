@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/gi8lino/go-snapraid-web/internal/auth"
+	"github.com/gi8lino/go-snapraid-web/internal/runindex"
+	"github.com/gi8lino/go-snapraid-web/internal/schedule"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedactJob(t *testing.T) {
+	t.Parallel()
+
+	job := schedule.JobState{
+		JobConfig: schedule.JobConfig{Name: "nightly", Command: []string{"go-snapraid", "--config", "/etc/go-snapraid.yml"}},
+		Fires: []schedule.Fire{
+			{Status: schedule.FireFailed, Error: "exit status 1", Output: "Missing file 'filme/a.mkv'."},
+		},
+	}
+
+	t.Run("Viewer with hidden paths", func(t *testing.T) {
+		t.Parallel()
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req = req.WithContext(auth.WithIdentity(req.Context(), auth.Identity{Name: "bob", Role: auth.RoleViewer, HidePaths: true}))
+		got := redactJob(req, job)
+
+		assert.Equal(t, []string{"go-snapraid"}, got.Command)
+		assert.Equal(t, []schedule.Fire{{Status: schedule.FireFailed, Error: "exit status 1"}}, got.Fires)
+		assert.Equal(t, "Missing file 'filme/a.mkv'.", job.Fires[0].Output, "original state unchanged")
+	})
+
+	t.Run("Operator", func(t *testing.T) {
+		t.Parallel()
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req = req.WithContext(auth.WithIdentity(req.Context(), auth.Identity{Name: "alice", Role: auth.RoleOperator}))
+		assert.Equal(t, job, redactJob(req, job))
+	})
+}
+
+func TestSchedule(t *testing.T) {
+	t.Parallel()
+
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+
+	// newScheduler returns a scheduler with a nightly job that missed its last run.
+	newScheduler := func(t *testing.T) *schedule.Scheduler {
+		t.Helper()
+		sources := runindex.Sources{{Name: "media", Index: loadIndex(t, t.TempDir(), logger)}}
+		scheduler, err := schedule.New(schedule.Config{Jobs: []schedule.JobConfig{
+			{Name: "nightly", Schedule: "CRON_TZ=UTC 0 3 * * *", Command: []string{"go-snapraid", "--config", "/etc/go-snapraid.yml"}},
+		}}, sources, logger)
+		require.NoError(t, err)
+		scheduler.Now = func() time.Time { return time.Date(2025, 6, 2, 5, 0, 0, 0, time.UTC) }
+		return scheduler
+	}
+
+	t.Run("API", func(t *testing.T) {
+		t.Parallel()
+
+		rec := httptest.NewRecorder()
+		ScheduleAPI(newScheduler(t)).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/schedule", nil))
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"jobs":[{
+			"name":"nightly",
+			"schedule":"CRON_TZ=UTC 0 3 * * *",
+			"command":["go-snapraid","--config","/etc/go-snapraid.yml"],
+			"source":"media",
+			"paused":false,
+			"running":false,
+			"missed":"2025-06-02T03:00:00Z",
+			"fires":[]
+		}]}`, rec.Body.String())
+	})
+
+	t.Run("API hides command and output from viewers", func(t *testing.T) {
+		t.Parallel()
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/schedule", nil)
+		req = req.WithContext(auth.WithIdentity(req.Context(), auth.Identity{Name: "bob", Role: auth.RoleViewer, HidePaths: true}))
+		rec := httptest.NewRecorder()
+		ScheduleAPI(newScheduler(t)).ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"command":["go-snapraid"]`)
+		assert.NotContains(t, rec.Body.String(), "/etc/go-snapraid.yml")
+	})
+
+	t.Run("Job", func(t *testing.T) {
+		t.Parallel()
+
+		scheduler := newScheduler(t)
+		mux := http.NewServeMux()
+		mux.Handle("GET /api/v1/schedule/{job}", JobAPI(scheduler))
+
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/schedule/nightly", nil))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"name":"nightly"`)
+
+		rec = httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/schedule/weekly", nil))
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.JSONEq(t, `{"error":"job \"weekly\" not found"}`, rec.Body.String())
+	})
+
+	t.Run("Pause and resume", func(t *testing.T) {
+		t.Parallel()
+
+		scheduler := newScheduler(t)
+		mux := http.NewServeMux()
+		mux.Handle("POST /api/v1/schedule/{job}/pause", PauseJobAPI(scheduler, true))
+		mux.Handle("POST /api/v1/schedule/{job}/resume", PauseJobAPI(scheduler, false))
+
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/schedule/nightly/pause", nil))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"paused":true`)
+		assert.NotContains(t, rec.Body.String(), `"missed"`)
+		state, _ := scheduler.Job("nightly")
+		assert.True(t, state.Paused)
+
+		rec = httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/schedule/nightly/resume", nil))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"paused":false`)
+
+		rec = httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/schedule/weekly/pause", nil))
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("Partial", func(t *testing.T) {
+		t.Parallel()
+
+		webFS := fstest.MapFS{
+			"web/templates/schedule.html": &fstest.MapFile{Data: []byte(`{{define "schedule"}}{{range .Jobs}}{{.Name}}:{{.Source}}:{{.Paused}}:{{.Missed.UTC.Format "2006-01-02T15:04"}}{{end}} operator={{.Operator}}{{end}}`)},
+		}
+
+		handler := SchedulePartial(webFS, newScheduler(t), logger)
+
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/partials/schedule", nil))
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "nightly:media:false:2025-06-02T03:00 operator=true", rec.Body.String())
+
+		// viewers cannot pause jobs
+		req := httptest.NewRequest(http.MethodGet, "/partials/schedule", nil)
+		req = req.WithContext(auth.WithIdentity(req.Context(), auth.Identity{Name: "alice", Role: auth.RoleViewer}))
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "nightly:media:false:2025-06-02T03:00 operator=false", rec.Body.String())
+	})
+}
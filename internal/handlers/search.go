@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gi8lino/go-snapraid-web/internal/runindex"
+)
+
+// SearchMode selects how a search query is matched against file paths.
+type SearchMode string
+
+const (
+	SearchSubstring SearchMode = "substring" // case-insensitive substring match
+	SearchGlob      SearchMode = "glob"      // shell pattern, see path.Match
+	SearchRegex     SearchMode = "regex"     // Go regular expression
+)
+
+const (
+	defaultSearchLimit = 500   // default maximum number of matches returned
+	maxSearchLimit     = 10000 // upper bound for the limit parameter
+)
+
+// SearchMatch is a file that matched a search query in one run.
+type SearchMatch struct {
+	RunID    string            `json:"run_id"`
+	Date     string            `json:"date"` // timestamp recorded inside the run file
+	Category runindex.Category `json:"category"`
+	Path     string            `json:"path"`
+}
+
+// SearchResult holds all matches of a search query, newest run first.
+type SearchResult struct {
+	Query     string        `json:"query"`
+	Mode      SearchMode    `json:"mode"`
+	Matches   []SearchMatch `json:"matches"`
+	Truncated bool          `json:"truncated"` // true if more matches exist than returned
+}
+
+// searchQuery is a parsed search request.
+type searchQuery struct {
+	query string
+	mode  SearchMode
+	limit int
+	match func(string) bool
+}
+
+// parseSearchQuery parses the q, mode and limit query parameters.
+func parseSearchQuery(r *http.Request) (searchQuery, error) {
+	params := r.URL.Query()
+	q := searchQuery{
+		query: params.Get("q"),
+		mode:  SearchMode(params.Get("mode")),
+		limit: defaultSearchLimit,
+	}
+	if q.mode == "" {
+		q.mode = SearchSubstring
+	}
+
+	if s := params.Get("limit"); s != "" {
+		limit, err := strconv.Atoi(s)
+		if err != nil || limit < 1 {
+			return searchQuery{}, fmt.Errorf("invalid limit %q", s)
+		}
+		q.limit = min(limit, maxSearchLimit)
+	}
+
+	match, err := newPathMatcher(q.query, q.mode)
+	if err != nil {
+		return searchQuery{}, err
+	}
+	q.match = match
+
+	return q, nil
+}
+
+// newPathMatcher returns a function reporting whether a path matches query.
+// Glob patterns without a slash are matched against the file name only.
+func newPathMatcher(query string, mode SearchMode) (func(string) bool, error) {
+	switch mode {
+	case SearchSubstring:
+		needle := strings.ToLower(query)
+		return func(p string) bool {
+			return strings.Contains(strings.ToLower(p), needle)
+		}, nil
+
+	case SearchGlob:
+		if _, err := path.Match(query, ""); err != nil {
+			return nil, fmt.Errorf("invalid glob %q: %w", query, err)
+		}
+		baseOnly := !strings.Contains(query, "/")
+		return func(p string) bool {
+			if baseOnly {
+				p = path.Base(p)
+			}
+			ok, _ := path.Match(query, p)
+			return ok
+		}, nil
+
+	case SearchRegex:
+		re, err := regexp.Compile(query)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex %q: %w", query, err)
+		}
+		return re.MatchString, nil
+
+	default:
+		return nil, fmt.Errorf("invalid search mode %q", mode)
+	}
+}
+
+// searchRuns returns all files of all indexed runs matching q, newest run
+// first. It searches the file changes the index keeps in memory.
+func searchRuns(index *runindex.Index, q searchQuery) SearchResult {
+	changes, truncated := index.Search(q.match, q.limit)
+	result := SearchResult{
+		Query:     q.query,
+		Mode:      q.mode,
+		Matches:   make([]SearchMatch, 0, len(changes)),
+		Truncated: truncated,
+	}
+
+	dates := make(map[string]string) // timestamps recorded inside the run files, by run ID
+	for _, fc := range changes {
+		date, ok := dates[fc.RunID]
+		if !ok {
+			s, _ := index.Get(fc.RunID)
+			date = s.Timestamp
+			dates[fc.RunID] = date
+		}
+		result.Matches = append(result.Matches, SearchMatch{
+			RunID:    fc.RunID,
+			Date:     date,
+			Category: fc.Category,
+			Path:     fc.Entry(),
+		})
+	}
+	return result
+}
+
+// SearchAPI returns an HTTP handler searching file paths across all runs.
+// Query parameters: q (query), mode (substring, glob or regex) and limit.
+func SearchAPI(index *runindex.Index) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q, err := parseSearchQuery(r)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if q.query == "" {
+			writeJSONError(w, http.StatusBadRequest, "missing query parameter q")
+			return
+		}
+
+		writeJSON(w, http.StatusOK, searchRuns(index, q))
+	}
+}
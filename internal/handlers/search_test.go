@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gi8lino/go-snapraid-web/internal/runindex"
+	"github.com/gi8lino/go-snapraid/pkg/snapraid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewPathMatcher(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		query string
+		mode  SearchMode
+		path  string
+		want  bool
+	}{
+		{name: "substring ignores case", query: "zoolander", mode: SearchSubstring, path: "filme/Zoolander/z.mkv", want: true},
+		{name: "substring no match", query: "matrix", mode: SearchSubstring, path: "filme/Zoolander/z.mkv", want: false},
+		{name: "glob on file name", query: "*.mkv", mode: SearchGlob, path: "filme/Zoolander/z.mkv", want: true},
+		{name: "glob on full path", query: "filme/*/z.mkv", mode: SearchGlob, path: "filme/Zoolander/z.mkv", want: true},
+		{name: "glob full path no match", query: "serien/*", mode: SearchGlob, path: "filme/Zoolander/z.mkv", want: false},
+		{name: "regex", query: `S0\dE1[0-9]`, mode: SearchRegex, path: "serien/FBI/FBI.S06E13.mkv", want: true},
+		{name: "regex no match", query: `^filme/`, mode: SearchRegex, path: "serien/FBI/FBI.S06E13.mkv", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			match, err := newPathMatcher(tt.query, tt.mode)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, match(tt.path))
+		})
+	}
+
+	t.Run("invalid patterns", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := newPathMatcher("[", SearchGlob)
+		assert.EqualError(t, err, `invalid glob "[": syntax error in pattern`)
+
+		_, err = newPathMatcher("(", SearchRegex)
+		assert.Error(t, err)
+
+		_, err = newPathMatcher("x", "fuzzy")
+		assert.EqualError(t, err, `invalid search mode "fuzzy"`)
+	})
+}
+
+func TestSearchAPI(t *testing.T) {
+	t.Parallel()
+
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+
+	tmp := t.TempDir()
+	writeRunFile(t, tmp, "2025-06-01T03:00:00Z", snapraid.RunResult{
+		Timestamp: "2025-06-01T03:00:00Z",
+		Result:    snapraid.DiffResult{Added: []string{"filme/Zoolander/z.mkv", "filme/Matrix/m.mkv"}},
+	})
+	writeRunFile(t, tmp, "2025-06-02T03:00:00Z", snapraid.RunResult{
+		Timestamp: "2025-06-02T03:00:00Z",
+		Result:    snapraid.DiffResult{Removed: []string{"filme/Zoolander/z.mkv", `filme/Matrix\ \(1999\)/m.mkv`}},
+	})
+	index := loadIndex(t, tmp, logger)
+
+	t.Run("Finds file across runs", func(t *testing.T) {
+		t.Parallel()
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/search?q=zoolander", nil)
+		rec := httptest.NewRecorder()
+		SearchAPI(index).ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var result SearchResult
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+		assert.Equal(t, SearchSubstring, result.Mode)
+		assert.False(t, result.Truncated)
+		assert.Equal(t, []SearchMatch{
+			{RunID: "2025-06-02T03:00:00Z", Date: "2025-06-02T03:00:00Z", Category: runindex.CategoryRemoved, Path: "filme/Zoolander/z.mkv"},
+			{RunID: "2025-06-01T03:00:00Z", Date: "2025-06-01T03:00:00Z", Category: runindex.CategoryAdded, Path: "filme/Zoolander/z.mkv"},
+		}, result.Matches)
+	})
+
+	t.Run("Matches unescaped paths", func(t *testing.T) {
+		t.Parallel()
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/search?q=Matrix+(1999)", nil)
+		rec := httptest.NewRecorder()
+		SearchAPI(index).ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var result SearchResult
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+		require.Len(t, result.Matches, 1)
+		assert.Equal(t, "filme/Matrix (1999)/m.mkv", result.Matches[0].Path)
+	})
+
+	t.Run("Truncates at limit", func(t *testing.T) {
+		t.Parallel()
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/search?q=*.mkv&mode=glob&limit=2", nil)
+		rec := httptest.NewRecorder()
+		SearchAPI(index).ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var result SearchResult
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+		assert.Len(t, result.Matches, 2)
+		assert.True(t, result.Truncated)
+	})
+
+	t.Run("Missing query", func(t *testing.T) {
+		t.Parallel()
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/search", nil)
+		rec := httptest.NewRecorder()
+		SearchAPI(index).ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.JSONEq(t, `{"error":"missing query parameter q"}`, rec.Body.String())
+	})
+
+	t.Run("Invalid regex", func(t *testing.T) {
+		t.Parallel()
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/search?q=(&mode=regex", nil)
+		rec := httptest.NewRecorder()
+		SearchAPI(index).ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+
+	t.Run("Invalid limit", func(t *testing.T) {
+		t.Parallel()
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/search?q=a&limit=0", nil)
+		rec := httptest.NewRecorder()
+		SearchAPI(index).ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
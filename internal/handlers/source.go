@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gi8lino/go-snapraid-web/internal/api"
+	"github.com/gi8lino/go-snapraid-web/internal/runindex"
+	"github.com/gi8lino/go-snapraid-web/internal/utils"
+)
+
+// PerSource returns an HTTP handler serving each source with its own handler,
+// created once per source by newHandler. The source is selected by the
+// "source" query parameter; without it, the first source is served.
+func PerSource(sources runindex.Sources, newHandler func(index *runindex.Index) http.Handler) http.Handler {
+	bySource := make(map[string]http.Handler, len(sources))
+	for _, src := range sources {
+		bySource[src.Name] = newHandler(src.Index)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		src, ok := sources.Get(r.URL.Query().Get("source"))
+		if !ok {
+			writeJSONError(w, http.StatusNotFound, fmt.Sprintf("source %q not found", r.URL.Query().Get("source")))
+			return
+		}
+		bySource[src.Name].ServeHTTP(w, r)
+	})
+}
+
+// sourceValues returns the "source" query parameter of values, if set, so
+// links built for a source keep selecting it.
+func sourceValues(values url.Values) url.Values {
+	out := url.Values{}
+	if s := values.Get("source"); s != "" {
+		out.Set("source", s)
+	}
+	return out
+}
+
+// SourceSummary is the API representation of a source with its latest run.
+type SourceSummary struct {
+	Name   string          `json:"name"`
+	Runs   int             `json:"runs"`             // number of indexed runs
+	Latest *api.RunSummary `json:"latest"`           // most recent run, null if there is none
+	Remote *RemoteHealth   `json:"remote,omitempty"` // fetch state, omitted for local sources
+}
+
+// RemoteHealth is the API representation of the fetch state of a source
+// mirrored from another instance.
+type RemoteHealth struct {
+	URL         string    `json:"url"`
+	LastFetch   time.Time `json:"last_fetch,omitzero"`   // omitted before the first fetch
+	LastSuccess time.Time `json:"last_success,omitzero"` // omitted before the first successful fetch
+	Error       string    `json:"error,omitempty"`       // error of the last fetch, omitted if it succeeded
+}
+
+// SourcesAPI returns an HTTP handler listing all sources with their latest
+// run as JSON, in the configured order.
+func SourcesAPI(sources runindex.Sources) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		out := make([]SourceSummary, 0, len(sources))
+		for _, src := range sources {
+			s := SourceSummary{Name: src.Name, Runs: len(src.Index.IDs())}
+			if latest, ok := src.Index.Latest(); ok {
+				summary := api.NewRunSummary(latest)
+				s.Latest = &summary
+			}
+			if src.Health != nil {
+				h := src.Health()
+				s.Remote = &RemoteHealth{URL: h.URL, LastFetch: h.LastFetch, LastSuccess: h.LastSuccess, Error: h.Error}
+			}
+			out = append(out, s)
+		}
+
+		writeJSON(w, http.StatusOK, struct {
+			Sources []SourceSummary `json:"sources"`
+		}{
+			Sources: out,
+		})
+	}
+}
+
+// SourceView is a row of the combined overview.
+type SourceView struct {
+	Name   string
+	Runs   int              // number of indexed runs
+	Latest *OverviewView    // most recent run, nil if there is none
+	Remote *runindex.Health // fetch state, nil for local sources
+}
+
+// SourcesPartial returns an HTTP handler rendering the combined overview,
+// showing the latest run of every source.
+func SourcesPartial(webFS fs.FS, sources runindex.Sources, logger *slog.Logger) http.HandlerFunc {
+	tmpl := template.Must(
+		template.New("sources").
+			Funcs(utils.FuncMap()).
+			ParseFS(
+				webFS,
+				"web/templates/overview.html",
+				"web/templates/sources.html",
+			),
+	)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := renderSources(w, tmpl, sources); err != nil {
+			logger.Error("render sources partial", "error", err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+		}
+	}
+}
+
+// renderSources renders the combined overview.
+func renderSources(w io.Writer, tmpl *template.Template, sources runindex.Sources) error {
+	rows := make([]SourceView, 0, len(sources))
+	remotes := false
+	for _, src := range sources {
+		row := SourceView{Name: src.Name, Runs: len(src.Index.IDs())}
+		if latest, ok := src.Index.Latest(); ok {
+			view := newOverviewView(latest)
+			row.Latest = &view
+		}
+		if src.Health != nil {
+			h := src.Health()
+			row.Remote = &h
+			remotes = true
+		}
+		rows = append(rows, row)
+	}
+
+	return tmpl.ExecuteTemplate(w, "sources", struct {
+		Sources []SourceView
+		Remotes bool // whether any source is mirrored from another instance
+	}{
+		Sources: rows,
+		Remotes: remotes,
+	})
+}
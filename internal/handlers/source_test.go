@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/gi8lino/go-snapraid-web/internal/runindex"
+	"github.com/gi8lino/go-snapraid/pkg/snapraid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPerSource(t *testing.T) {
+	t.Parallel()
+
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	media, backup := t.TempDir(), t.TempDir()
+	writeRunFile(t, media, "2025-06-01T03:00:00Z", snapraid.RunResult{})
+	writeRunFile(t, backup, "2025-06-02T03:00:00Z", snapraid.RunResult{})
+
+	sources := runindex.Sources{
+		{Name: "media", Index: loadIndex(t, media, logger)},
+		{Name: "backup", Index: loadIndex(t, backup, logger)},
+	}
+	handler := PerSource(sources, func(index *runindex.Index) http.Handler {
+		return RunsAPI(index)
+	})
+
+	tests := []struct {
+		name   string
+		query  string
+		status int
+		body   string
+	}{
+		{"Default source", "", http.StatusOK, `"2025-06-01T03:00:00Z"`},
+		{"Named source", "?source=backup", http.StatusOK, `"2025-06-02T03:00:00Z"`},
+		{"Unknown source", "?source=photos", http.StatusNotFound, `{"error":"source \"photos\" not found"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/runs"+tt.query, nil))
+
+			assert.Equal(t, tt.status, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.body)
+		})
+	}
+}
+
+func TestSources(t *testing.T) {
+	t.Parallel()
+
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	media := t.TempDir()
+	writeRunFile(t, media, "2025-06-01T03:00:00Z", snapraid.RunResult{})
+	writeRunFile(t, media, "2025-06-02T03:00:00Z", snapraid.RunResult{
+		Result: snapraid.DiffResult{Added: []string{"a"}},
+	})
+
+	fetched := time.Date(2025, 6, 3, 12, 0, 0, 0, time.UTC)
+	sources := runindex.Sources{
+		{Name: "media", Index: loadIndex(t, media, logger)},
+		{Name: "backup", Index: loadIndex(t, t.TempDir(), logger), Health: func() runindex.Health {
+			return runindex.Health{URL: "https://nas2", LastFetch: fetched, LastSuccess: fetched}
+		}},
+	}
+
+	t.Run("API", func(t *testing.T) {
+		t.Parallel()
+
+		rec := httptest.NewRecorder()
+		SourcesAPI(sources).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/sources", nil))
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		body := rec.Body.String()
+		assert.Contains(t, body, `{"name":"media","runs":2,"latest":{"id":"2025-06-02T03:00:00Z"`)
+		assert.Contains(t, body, `{"name":"backup","runs":0,"latest":null,"remote":{"url":"https://nas2","last_fetch":"2025-06-03T12:00:00Z","last_success":"2025-06-03T12:00:00Z"}}`)
+	})
+
+	t.Run("Partial", func(t *testing.T) {
+		t.Parallel()
+
+		webFS := fstest.MapFS{
+			"web/templates/overview.html": &fstest.MapFile{Data: []byte(`{{define "overview"}}{{end}}`)},
+			"web/templates/sources.html":  &fstest.MapFile{Data: []byte(`{{define "sources"}}{{.Remotes}}{{range .Sources}} {{.Name}}:{{.Runs}}:{{with .Latest}}{{.Timestamp}}/{{.Total}}{{else}}none{{end}}{{with .Remote}}:{{.URL}}{{end}}{{end}}{{end}}`)},
+		}
+
+		rec := httptest.NewRecorder()
+		SourcesPartial(webFS, sources, logger).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/partials/sources", nil))
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "true media:2:2025-06-02T03:00:00Z/1 backup:0:none:https://nas2", rec.Body.String())
+	})
+}
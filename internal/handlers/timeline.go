@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"html/template"
+	"io"
+	"net/http"
+
+	"github.com/gi8lino/go-snapraid-web/internal/runindex"
+)
+
+// FileTimeline lists every run that changed a file.
+type FileTimeline struct {
+	Path    string                `json:"path"`
+	Paths   []string              `json:"paths"`   // path and all paths the file was moved from or to
+	Changes []runindex.FileChange `json:"changes"` // oldest first
+}
+
+// newFileTimeline looks up the timeline of the file at path.
+func newFileTimeline(index *runindex.Index, path string) FileTimeline {
+	changes, paths := index.Timeline(path)
+	return FileTimeline{Path: paths[0], Paths: paths, Changes: changes}
+}
+
+// TimelineAPI returns an HTTP handler listing all changes of the file given
+// by the "path" query parameter as JSON.
+func TimelineAPI(index *runindex.Index) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.Query().Get("path")
+		if path == "" {
+			writeJSONError(w, http.StatusBadRequest, "missing path")
+			return
+		}
+		writeJSON(w, http.StatusOK, newFileTimeline(index, path))
+	}
+}
+
+// renderTimeline renders the timeline of a single file.
+func renderTimeline(
+	w io.Writer,
+	tmpl *template.Template,
+	index *runindex.Index,
+	path string,
+) error {
+	return tmpl.ExecuteTemplate(w, "file", struct {
+		Timeline FileTimeline
+	}{
+		Timeline: newFileTimeline(index, path),
+	})
+}
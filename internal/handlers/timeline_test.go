@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gi8lino/go-snapraid/pkg/snapraid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimelineAPI(t *testing.T) {
+	t.Parallel()
+
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+
+	tmp := t.TempDir()
+	writeRunFile(t, tmp, "2025-06-01T03:00:00Z", snapraid.RunResult{
+		Result: snapraid.DiffResult{Added: []string{"filme/a.mkv"}},
+	})
+	writeRunFile(t, tmp, "2025-06-02T03:00:00Z", snapraid.RunResult{
+		Result: snapraid.DiffResult{Moved: []string{"filme/a.mkv -> archiv/a.mkv"}},
+	})
+	index := loadIndex(t, tmp, logger)
+
+	t.Run("Lists changes of a file", func(t *testing.T) {
+		t.Parallel()
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/files?path=archiv/a.mkv", nil)
+		rec := httptest.NewRecorder()
+		TimelineAPI(index).ServeHTTP(rec, req)
+
+		require.Equal(t, http.StatusOK, rec.Code)
+		var got FileTimeline
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&got))
+		assert.Equal(t, "archiv/a.mkv", got.Path)
+		assert.Equal(t, []string{"archiv/a.mkv", "filme/a.mkv"}, got.Paths)
+		require.Len(t, got.Changes, 2)
+		assert.Equal(t, "2025-06-01T03:00:00Z", got.Changes[0].RunID)
+		assert.Equal(t, "filme/a.mkv", got.Changes[1].From)
+	})
+
+	t.Run("Unknown file", func(t *testing.T) {
+		t.Parallel()
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/files?path=nope", nil)
+		rec := httptest.NewRecorder()
+		TimelineAPI(index).ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"path":"nope","paths":["nope"],"changes":[]}`, rec.Body.String())
+	})
+
+	t.Run("Missing path", func(t *testing.T) {
+		t.Parallel()
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/files", nil)
+		rec := httptest.NewRecorder()
+		TimelineAPI(index).ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/gi8lino/go-snapraid-web/internal/api"
+	"github.com/gi8lino/go-snapraid-web/internal/runindex"
+)
+
+// RunViewMode selects how the changed files of a run are displayed.
+type RunViewMode string
+
+const (
+	RunViewList RunViewMode = "list" // flat file list per category
+	RunViewTree RunViewMode = "tree" // files grouped by directory
+)
+
+// parseRunViewMode parses the view query parameter; empty means list.
+func parseRunViewMode(s string) (RunViewMode, error) {
+	switch RunViewMode(s) {
+	case "", RunViewList:
+		return RunViewList, nil
+	case RunViewTree:
+		return RunViewTree, nil
+	default:
+		return "", fmt.Errorf("invalid view %q", s)
+	}
+}
+
+// TreeNode is a directory of the changed files of a run.
+type TreeNode struct {
+	Name   string        // directory name, the full path for the root
+	Path   string        // slash-separated directory path, empty for the top level
+	Counts api.RunCounts // changed files below this directory per category
+	Dirs   []*TreeNode   // subdirectories, sorted by name
+	Files  []TreeFile    // files directly in this directory, sorted by name
+
+	dirs map[string]*TreeNode // subdirectories by name, used while building
+}
+
+// TreeFile is a changed file within a TreeNode.
+type TreeFile struct {
+	Name     string            // file name
+	Path     string            // full path with SnapRAID escapes decoded, the target of moved and copied files
+	From     string            // source path of moved and copied files
+	Raw      string            // shell-escaped path as written by go-snapraid
+	Category runindex.Category // change category
+}
+
+// TreeCrumb is one element of the breadcrumb trail to a tree directory.
+type TreeCrumb struct {
+	Name string // directory name
+	Path string // directory path, empty for the top level
+}
+
+// buildTree groups the changed files of a run below root by directory.
+// It reports false if no changed file lies below root.
+func buildTree(run runindex.Run, root string) (*TreeNode, bool) {
+	root = strings.Trim(root, "/")
+	top := &TreeNode{Name: root, Path: root}
+	found := false
+
+	for _, c := range runindex.Categories {
+		raw := run.RawFiles(c)
+		for i, entry := range run.Files(c) {
+			// moved and copied files are placed at their target
+			p, from := entry, ""
+			if f, to, ok := runindex.SplitMove(entry); ok && (c == runindex.CategoryMoved || c == runindex.CategoryCopied) {
+				p, from = to, f
+			}
+			rel := strings.TrimLeft(p, "/")
+			if root != "" {
+				var ok bool
+				if rel, ok = strings.CutPrefix(rel, root+"/"); !ok {
+					continue
+				}
+			}
+			found = true
+
+			parts := splitPath(rel)
+			if len(parts) == 0 {
+				continue
+			}
+
+			node := top
+			node.Counts.Add(c)
+			for _, dir := range parts[:len(parts)-1] {
+				node = node.child(dir)
+				node.Counts.Add(c)
+			}
+			node.Files = append(node.Files, TreeFile{
+				Name:     parts[len(parts)-1],
+				Path:     p,
+				From:     from,
+				Raw:      raw[i],
+				Category: c,
+			})
+		}
+	}
+
+	top.sort()
+	return top, found
+}
+
+// child returns the subdirectory with the given name, creating it if needed.
+func (n *TreeNode) child(name string) *TreeNode {
+	if n.dirs == nil {
+		n.dirs = make(map[string]*TreeNode)
+	}
+	c, ok := n.dirs[name]
+	if !ok {
+		c = &TreeNode{Name: name, Path: path.Join(n.Path, name)}
+		n.dirs[name] = c
+		n.Dirs = append(n.Dirs, c)
+	}
+	return c
+}
+
+// sort orders subdirectories and files by name, recursively.
+func (n *TreeNode) sort() {
+	sort.Slice(n.Dirs, func(i, j int) bool { return n.Dirs[i].Name < n.Dirs[j].Name })
+	sort.SliceStable(n.Files, func(i, j int) bool { return n.Files[i].Name < n.Files[j].Name })
+	for _, d := range n.Dirs {
+		d.sort()
+	}
+	n.dirs = nil
+}
+
+// treeCrumbs returns the breadcrumb trail from the top level to dir.
+func treeCrumbs(dir string) []TreeCrumb {
+	crumbs := []TreeCrumb{{Name: "/", Path: ""}}
+	var cur string
+	for _, part := range splitPath(dir) {
+		cur = path.Join(cur, part)
+		crumbs = append(crumbs, TreeCrumb{Name: part, Path: cur})
+	}
+	return crumbs
+}
+
+// splitPath splits a slash-separated path into its non-empty elements.
+func splitPath(p string) []string {
+	return strings.FieldsFunc(p, func(r rune) bool { return r == '/' })
+}
@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/gi8lino/go-snapraid-web/internal/api"
+	"github.com/gi8lino/go-snapraid-web/internal/runindex"
+	"github.com/gi8lino/go-snapraid/pkg/snapraid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildTree(t *testing.T) {
+	t.Parallel()
+
+	run := runindex.Run{
+		Result: snapraid.DiffResult{
+			Added:   []string{"serien/b/e2.mkv", "serien/b/e1.mkv", "top.txt"},
+			Removed: []string{"serien/a/e1.mkv", "/filme/x.mkv"},
+			Updated: []string{"serien/b/e1.mkv"},
+		},
+	}
+
+	t.Run("Groups files by directory", func(t *testing.T) {
+		t.Parallel()
+
+		tree, ok := buildTree(run, "")
+		require.True(t, ok)
+
+		assert.Equal(t, api.RunCounts{Added: 3, Removed: 2, Updated: 1, Total: 6}, tree.Counts)
+		require.Len(t, tree.Dirs, 2)
+		assert.Equal(t, "filme", tree.Dirs[0].Name)
+		assert.Equal(t, []TreeFile{{Name: "top.txt", Path: "top.txt", Raw: "top.txt", Category: runindex.CategoryAdded}}, tree.Files)
+
+		serien := tree.Dirs[1]
+		assert.Equal(t, "serien", serien.Path)
+		assert.Equal(t, api.RunCounts{Added: 2, Removed: 1, Updated: 1, Total: 4}, serien.Counts)
+		require.Len(t, serien.Dirs, 2)
+
+		b := serien.Dirs[1]
+		assert.Equal(t, "serien/b", b.Path)
+		assert.Equal(t, []TreeFile{
+			{Name: "e1.mkv", Path: "serien/b/e1.mkv", Raw: "serien/b/e1.mkv", Category: runindex.CategoryAdded},
+			{Name: "e1.mkv", Path: "serien/b/e1.mkv", Raw: "serien/b/e1.mkv", Category: runindex.CategoryUpdated},
+			{Name: "e2.mkv", Path: "serien/b/e2.mkv", Raw: "serien/b/e2.mkv", Category: runindex.CategoryAdded},
+		}, b.Files)
+	})
+
+	t.Run("Drills down into a directory", func(t *testing.T) {
+		t.Parallel()
+
+		tree, ok := buildTree(run, "/serien/b/")
+		require.True(t, ok)
+
+		assert.Equal(t, "serien/b", tree.Path)
+		assert.Equal(t, api.RunCounts{Added: 2, Updated: 1, Total: 3}, tree.Counts)
+		assert.Empty(t, tree.Dirs)
+		assert.Len(t, tree.Files, 3)
+	})
+
+	t.Run("Keeps raw paths", func(t *testing.T) {
+		t.Parallel()
+
+		tree, ok := buildTree(runindex.Run{
+			Result: snapraid.DiffResult{Added: []string{"filme/a b.mkv"}},
+			Raw:    snapraid.DiffResult{Added: []string{`filme/a\ b.mkv`}},
+		}, "filme")
+		require.True(t, ok)
+		assert.Equal(t, []TreeFile{
+			{Name: "a b.mkv", Path: "filme/a b.mkv", Raw: `filme/a\ b.mkv`, Category: runindex.CategoryAdded},
+		}, tree.Files)
+	})
+
+	t.Run("Places moved files at their target", func(t *testing.T) {
+		t.Parallel()
+
+		tree, ok := buildTree(runindex.Run{
+			Result: snapraid.DiffResult{
+				Moved:  []string{"filme/a.mkv -> serien/a/e1.mkv"},
+				Copied: []string{"serien/a/e1.mkv -> backup/e1.mkv"},
+			},
+		}, "")
+		require.True(t, ok)
+
+		assert.Equal(t, api.RunCounts{Moved: 1, Copied: 1, Total: 2}, tree.Counts)
+		require.Len(t, tree.Dirs, 2)
+		assert.Equal(t, "backup", tree.Dirs[0].Path)
+		assert.Equal(t, []TreeFile{
+			{Name: "e1.mkv", Path: "backup/e1.mkv", From: "serien/a/e1.mkv", Raw: "serien/a/e1.mkv -> backup/e1.mkv", Category: runindex.CategoryCopied},
+		}, tree.Dirs[0].Files)
+
+		serien := tree.Dirs[1]
+		require.Len(t, serien.Dirs, 1)
+		assert.Equal(t, "serien/a", serien.Dirs[0].Path)
+		assert.Equal(t, []TreeFile{
+			{Name: "e1.mkv", Path: "serien/a/e1.mkv", From: "filme/a.mkv", Raw: "filme/a.mkv -> serien/a/e1.mkv", Category: runindex.CategoryMoved},
+		}, serien.Dirs[0].Files)
+
+		_, ok = buildTree(runindex.Run{Result: snapraid.DiffResult{Moved: []string{"filme/a.mkv -> serien/a/e1.mkv"}}}, "filme")
+		assert.False(t, ok)
+	})
+
+	t.Run("Keeps arrows in other paths", func(t *testing.T) {
+		t.Parallel()
+
+		tree, ok := buildTree(runindex.Run{Result: snapraid.DiffResult{Added: []string{"filme/a -> b.mkv"}}}, "filme")
+		require.True(t, ok)
+		assert.Equal(t, []TreeFile{
+			{Name: "a -> b.mkv", Path: "filme/a -> b.mkv", Raw: "filme/a -> b.mkv", Category: runindex.CategoryAdded},
+		}, tree.Files)
+	})
+
+	t.Run("Unknown directory", func(t *testing.T) {
+		t.Parallel()
+
+		_, ok := buildTree(run, "serie")
+		assert.False(t, ok)
+	})
+}
+
+func TestTreeCrumbs(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, []TreeCrumb{{Name: "/", Path: ""}}, treeCrumbs(""))
+	assert.Equal(t, []TreeCrumb{
+		{Name: "/", Path: ""},
+		{Name: "serien", Path: "serien"},
+		{Name: "b", Path: "serien/b"},
+	}, treeCrumbs("serien/b"))
+}
+
+func TestParseRunViewMode(t *testing.T) {
+	t.Parallel()
+
+	mode, err := parseRunViewMode("")
+	require.NoError(t, err)
+	assert.Equal(t, RunViewList, mode)
+
+	mode, err = parseRunViewMode("tree")
+	require.NoError(t, err)
+	assert.Equal(t, RunViewTree, mode)
+
+	_, err = parseRunViewMode("grid")
+	assert.EqualError(t, err, `invalid view "grid"`)
+}
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gi8lino/go-snapraid-web/internal/api"
+	"github.com/gi8lino/go-snapraid-web/internal/runindex"
+)
+
+// TrendBucket is the width of the time buckets runs are aggregated into.
+type TrendBucket string
+
+const (
+	BucketDay   TrendBucket = "day"
+	BucketWeek  TrendBucket = "week"
+	BucketMonth TrendBucket = "month"
+)
+
+// parseTrendBucket converts s into a TrendBucket, defaulting to days.
+func parseTrendBucket(s string) (TrendBucket, error) {
+	switch b := TrendBucket(s); b {
+	case "":
+		return BucketDay, nil
+	case BucketDay, BucketWeek, BucketMonth:
+		return b, nil
+	default:
+		return "", fmt.Errorf("invalid bucket %q", s)
+	}
+}
+
+// start returns the beginning of the bucket containing t, in UTC.
+// Weeks start on Monday.
+func (b TrendBucket) start(t time.Time) time.Time {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	switch b {
+	case BucketWeek:
+		offset := (int(day.Weekday()) + 6) % 7 // days since Monday
+		return day.AddDate(0, 0, -offset)
+	case BucketMonth:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return day
+	}
+}
+
+// TrendPoint aggregates all runs of one time bucket.
+type TrendPoint struct {
+	Start     time.Time      `json:"start"`     // beginning of the bucket
+	Runs      int            `json:"runs"`      // number of runs in the bucket
+	Failed    int            `json:"failed"`    // number of failed or partial runs in the bucket
+	Durations api.RunTimings `json:"durations"` // average step durations of the runs
+	Changes   api.RunCounts  `json:"changes"`   // summed file changes; equal is taken from the latest run
+}
+
+// Trends returns an HTTP handler serving step durations and file changes
+// aggregated into day, week or month buckets, oldest first.
+func Trends(index *runindex.Index) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		bucket, err := parseTrendBucket(r.URL.Query().Get("bucket"))
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		writeJSON(w, http.StatusOK, struct {
+			Bucket TrendBucket  `json:"bucket"`
+			Points []TrendPoint `json:"points"`
+		}{
+			Bucket: bucket,
+			Points: aggregateTrends(index.List(), bucket),
+		})
+	}
+}
+
+// aggregateTrends groups the summaries into buckets and returns them oldest first.
+// The summaries are expected newest first, as returned by the index.
+func aggregateTrends(summaries []runindex.Summary, bucket TrendBucket) []TrendPoint {
+	points := []TrendPoint{}
+	for i := len(summaries) - 1; i >= 0; i-- {
+		s := summaries[i]
+		start := bucket.start(s.Time)
+		if len(points) == 0 || !points[len(points)-1].Start.Equal(start) {
+			points = append(points, TrendPoint{Start: start})
+		}
+
+		p := &points[len(points)-1]
+		p.Runs++
+		if s.Status != runindex.StatusSuccess {
+			p.Failed++
+		}
+		// durations are summed here and averaged below
+		p.Durations.Touch += s.Timings.Touch.Seconds()
+		p.Durations.Diff += s.Timings.Diff.Seconds()
+		p.Durations.Sync += s.Timings.Sync.Seconds()
+		p.Durations.Scrub += s.Timings.Scrub.Seconds()
+		p.Durations.Smart += s.Timings.Smart.Seconds()
+		p.Durations.Total += s.Timings.Total.Seconds()
+		p.Changes.Equal = s.Equal
+		p.Changes.Added += s.Added
+		p.Changes.Removed += s.Removed
+		p.Changes.Updated += s.Updated
+		p.Changes.Moved += s.Moved
+		p.Changes.Copied += s.Copied
+		p.Changes.Restored += s.Restored
+		p.Changes.Total += s.Total()
+	}
+
+	for i := range points {
+		n := float64(points[i].Runs)
+		d := &points[i].Durations
+		d.Touch /= n
+		d.Diff /= n
+		d.Sync /= n
+		d.Scrub /= n
+		d.Smart /= n
+		d.Total /= n
+	}
+
+	return points
+}
@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gi8lino/go-snapraid-web/internal/runindex"
+	"github.com/gi8lino/go-snapraid/pkg/snapraid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrendBucket_Start(t *testing.T) {
+	t.Parallel()
+
+	ts := time.Date(2025, 6, 5, 14, 30, 0, 0, time.UTC) // Thursday
+
+	assert.Equal(t, time.Date(2025, 6, 5, 0, 0, 0, 0, time.UTC), BucketDay.start(ts))
+	assert.Equal(t, time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC), BucketWeek.start(ts))
+	assert.Equal(t, time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC), BucketMonth.start(ts))
+
+	sunday := time.Date(2025, 6, 8, 23, 0, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC), BucketWeek.start(sunday))
+}
+
+func TestAggregateTrends(t *testing.T) {
+	t.Parallel()
+
+	summaries := []runindex.Summary{ // newest first
+		{
+			Time:    time.Date(2025, 6, 9, 3, 0, 0, 0, time.UTC),
+			Removed: 4,
+			Equal:   20,
+			Timings: snapraid.RunTimings{Sync: 30 * time.Second},
+			Status:  runindex.StatusFailed,
+		},
+		{
+			Time:    time.Date(2025, 6, 3, 3, 0, 0, 0, time.UTC),
+			Added:   2,
+			Equal:   11,
+			Timings: snapraid.RunTimings{Diff: 4 * time.Second, Total: 4 * time.Second},
+			Status:  runindex.StatusSuccess,
+		},
+		{
+			Time:    time.Date(2025, 6, 2, 3, 0, 0, 0, time.UTC),
+			Added:   1,
+			Equal:   10,
+			Timings: snapraid.RunTimings{Diff: 2 * time.Second, Total: 2 * time.Second},
+			Status:  runindex.StatusSuccess,
+		},
+	}
+
+	t.Run("Daily", func(t *testing.T) {
+		t.Parallel()
+
+		points := aggregateTrends(summaries, BucketDay)
+		require.Len(t, points, 3)
+		assert.Equal(t, time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC), points[0].Start)
+		assert.Equal(t, 1, points[0].Runs)
+	})
+
+	t.Run("Weekly", func(t *testing.T) {
+		t.Parallel()
+
+		points := aggregateTrends(summaries, BucketWeek)
+		require.Len(t, points, 2)
+
+		assert.Equal(t, time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC), points[0].Start)
+		assert.Equal(t, 2, points[0].Runs)
+		assert.Equal(t, 0, points[0].Failed)
+		assert.Equal(t, 3.0, points[0].Durations.Diff)
+		assert.Equal(t, 3.0, points[0].Durations.Total)
+		assert.Equal(t, 3, points[0].Changes.Added)
+		assert.Equal(t, 3, points[0].Changes.Total)
+		assert.Equal(t, 11, points[0].Changes.Equal)
+
+		assert.Equal(t, time.Date(2025, 6, 9, 0, 0, 0, 0, time.UTC), points[1].Start)
+		assert.Equal(t, 1, points[1].Failed)
+		assert.Equal(t, 30.0, points[1].Durations.Sync)
+		assert.Equal(t, 4, points[1].Changes.Removed)
+	})
+
+	t.Run("No runs", func(t *testing.T) {
+		t.Parallel()
+
+		assert.Empty(t, aggregateTrends(nil, BucketMonth))
+	})
+}
+
+func TestTrends(t *testing.T) {
+	t.Parallel()
+
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+
+	tmp := t.TempDir()
+	writeRunFile(t, tmp, "2025-06-01T03:00:00Z", snapraid.RunResult{})
+	writeRunFile(t, tmp, "2025-06-21T03:00:00Z", snapraid.RunResult{})
+	index := loadIndex(t, tmp, logger)
+
+	t.Run("Monthly buckets", func(t *testing.T) {
+		t.Parallel()
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/trends?bucket=month", nil)
+		rec := httptest.NewRecorder()
+		Trends(index).ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var body struct {
+			Bucket TrendBucket  `json:"bucket"`
+			Points []TrendPoint `json:"points"`
+		}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		assert.Equal(t, BucketMonth, body.Bucket)
+		require.Len(t, body.Points, 1)
+		assert.Equal(t, 2, body.Points[0].Runs)
+	})
+
+	t.Run("Defaults to days", func(t *testing.T) {
+		t.Parallel()
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/trends", nil)
+		rec := httptest.NewRecorder()
+		Trends(index).ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"bucket":"day"`)
+	})
+
+	t.Run("Invalid bucket", func(t *testing.T) {
+		t.Parallel()
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/trends?bucket=year", nil)
+		rec := httptest.NewRecorder()
+		Trends(index).ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	})
+}
@@ -0,0 +1,239 @@
+// Package notify delivers webhook notifications for new SnapRAID runs.
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gi8lino/go-snapraid-web/internal/runindex"
+)
+
+// Counts holds the number of files per change category.
+type Counts struct {
+	Equal    int `json:"equal"`
+	Added    int `json:"added"`
+	Removed  int `json:"removed"`
+	Updated  int `json:"updated"`
+	Moved    int `json:"moved"`
+	Copied   int `json:"copied"`
+	Restored int `json:"restored"`
+	Total    int `json:"total"`
+}
+
+// Timings holds the step durations of a run in seconds.
+type Timings struct {
+	Touch float64 `json:"touch_seconds"`
+	Diff  float64 `json:"diff_seconds"`
+	Sync  float64 `json:"sync_seconds"`
+	Scrub float64 `json:"scrub_seconds"`
+	Smart float64 `json:"smart_seconds"`
+	Total float64 `json:"total_seconds"`
+}
+
+// Payload is the notification sent for a new run.
+type Payload struct {
+	Source    string             `json:"source,omitempty"` // name of the source, omitted with a single source
+	ID        string             `json:"id"`
+	Date      time.Time          `json:"date"`
+	Status    runindex.Status    `json:"status"`
+	Error     string             `json:"error,omitempty"`
+	Counts    Counts             `json:"counts"`
+	Timings   Timings            `json:"timings"`
+	Anomalies []runindex.Anomaly `json:"anomalies"`
+}
+
+// NewPayload builds the notification payload for a run summary.
+func NewPayload(s runindex.Summary) Payload {
+	anomalies := s.Anomalies
+	if anomalies == nil {
+		anomalies = []runindex.Anomaly{}
+	}
+	return Payload{
+		ID:     s.ID,
+		Date:   s.Time,
+		Status: s.Status,
+		Error:  s.Error,
+		Counts: Counts{
+			Equal:    s.Equal,
+			Added:    s.Added,
+			Removed:  s.Removed,
+			Updated:  s.Updated,
+			Moved:    s.Moved,
+			Copied:   s.Copied,
+			Restored: s.Restored,
+			Total:    s.Total(),
+		},
+		Timings: Timings{
+			Touch: s.Timings.Touch.Seconds(),
+			Diff:  s.Timings.Diff.Seconds(),
+			Sync:  s.Timings.Sync.Seconds(),
+			Scrub: s.Timings.Scrub.Seconds(),
+			Smart: s.Timings.Smart.Seconds(),
+			Total: s.Timings.Total.Seconds(),
+		},
+		Anomalies: anomalies,
+	}
+}
+
+// eventData is the data passed to webhook body templates. The payload fields
+// are available directly and as a whole via .Payload.
+type eventData struct {
+	Payload
+	Title   string // short human-readable summary
+	Message string // multi-line human-readable details
+	Alert   bool   // true if the run failed or was flagged as anomalous
+}
+
+// newEventData derives the template data for a payload.
+func newEventData(p Payload) eventData {
+	d := eventData{
+		Payload: p,
+		Title:   fmt.Sprintf("SnapRAID run %s: %s", p.ID, p.Status),
+		Alert:   p.Status != runindex.StatusSuccess || len(p.Anomalies) > 0,
+	}
+	if p.Source != "" {
+		d.Title = fmt.Sprintf("SnapRAID run %s on %s: %s", p.ID, p.Source, p.Status)
+	}
+
+	lines := []string{fmt.Sprintf(
+		"%d added, %d removed, %d updated, %d moved, %d copied, %d restored (%s total)",
+		p.Counts.Added, p.Counts.Removed, p.Counts.Updated,
+		p.Counts.Moved, p.Counts.Copied, p.Counts.Restored,
+		time.Duration(p.Timings.Total*float64(time.Second)).Round(time.Second),
+	)}
+	if p.Error != "" {
+		lines = append(lines, "Error: "+p.Error)
+	}
+	for _, a := range p.Anomalies {
+		lines = append(lines, "Warning: "+a.Reason)
+	}
+	d.Message = strings.Join(lines, "\n")
+
+	return d
+}
+
+// Notifier posts a notification to all webhooks whenever a new run appears
+// in the index.
+type Notifier struct {
+	index    *runindex.Index
+	webhooks []Webhook
+	logger   *slog.Logger
+
+	Source   string        // source name added to notifications, empty with a single source
+	Client   *http.Client  // HTTP client used for deliveries
+	Attempts int           // delivery attempts per webhook, including the first
+	Backoff  time.Duration // delay before the first retry, doubled on each further retry
+}
+
+// New creates a notifier for the given webhooks.
+func New(index *runindex.Index, webhooks []Webhook, logger *slog.Logger) *Notifier {
+	return &Notifier{
+		index:    index,
+		webhooks: webhooks,
+		logger:   logger,
+		Client:   &http.Client{Timeout: 10 * time.Second},
+		Attempts: 4,
+		Backoff:  2 * time.Second,
+	}
+}
+
+// Run subscribes to the index and delivers notifications for added runs
+// until ctx is cancelled.
+func (n *Notifier) Run(ctx context.Context) {
+	events, cancel := n.index.Subscribe()
+	defer cancel()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev := <-events:
+			if ev.Type != runindex.EventAdded {
+				continue
+			}
+			s, ok := n.index.Get(ev.ID)
+			if !ok {
+				continue
+			}
+			p := NewPayload(s)
+			p.Source = n.Source
+			n.Notify(ctx, p)
+		}
+	}
+}
+
+// Notify delivers the payload to all webhooks concurrently and waits until
+// every delivery has succeeded or given up.
+func (n *Notifier) Notify(ctx context.Context, p Payload) {
+	d := newEventData(p)
+
+	var wg sync.WaitGroup
+	for _, w := range n.webhooks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := n.deliver(ctx, w, d); err != nil {
+				n.logger.Error("Failed to deliver webhook", "kind", w.Kind, "host", w.Host(), "id", p.ID, "error", err)
+				return
+			}
+			n.logger.Info("Delivered webhook", "kind", w.Kind, "host", w.Host(), "id", p.ID)
+		}()
+	}
+	wg.Wait()
+}
+
+// deliver posts the event to a single webhook, retrying network errors and
+// retryable responses with exponential backoff.
+func (n *Notifier) deliver(ctx context.Context, w Webhook, d eventData) error {
+	backoff := n.Backoff
+	var err error
+	for attempt := 1; ; attempt++ {
+		var retry bool
+		retry, err = n.post(ctx, w, d)
+		if err == nil || !retry || attempt >= n.Attempts {
+			return err
+		}
+
+		n.logger.Warn("Retrying webhook", "kind", w.Kind, "host", w.Host(), "attempt", attempt, "error", err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// post sends one request and reports whether a failure is worth retrying.
+func (n *Notifier) post(ctx context.Context, w Webhook, d eventData) (retry bool, err error) {
+	req, err := w.newRequest(d)
+	if err != nil {
+		return false, err
+	}
+
+	resp, err := n.Client.Do(req.WithContext(ctx))
+	if err != nil {
+		// the error of the client names the full URL, keep only its host
+		var uerr *url.Error
+		if errors.As(err, &uerr) {
+			err = fmt.Errorf("%s %s: %w", uerr.Op, w.Host(), uerr.Err)
+		}
+		return ctx.Err() == nil, err
+	}
+	defer resp.Body.Close()                                       // nolint:errcheck
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10)) // allow connection reuse
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry = resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+	return retry, fmt.Errorf("unexpected status %s", resp.Status)
+}
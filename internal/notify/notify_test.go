@@ -0,0 +1,174 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gi8lino/go-snapraid-web/internal/runindex"
+	"github.com/gi8lino/go-snapraid/pkg/snapraid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNotifier_Notify(t *testing.T) {
+	t.Parallel()
+
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	payload := Payload{ID: "2025-06-01T03:00:00Z", Status: runindex.StatusSuccess}
+
+	newNotifier := func(t *testing.T, srv *httptest.Server) *Notifier {
+		t.Helper()
+		w, err := ParseWebhook(srv.URL)
+		require.NoError(t, err)
+		n := New(nil, []Webhook{w}, logger)
+		n.Backoff = time.Millisecond
+		return n
+	}
+
+	t.Run("Retries server errors", func(t *testing.T) {
+		t.Parallel()
+
+		var calls atomic.Int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if calls.Add(1) < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer srv.Close()
+
+		newNotifier(t, srv).Notify(context.Background(), payload)
+		assert.Equal(t, int32(3), calls.Load())
+	})
+
+	t.Run("Gives up after all attempts", func(t *testing.T) {
+		t.Parallel()
+
+		var calls atomic.Int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+			w.WriteHeader(http.StatusTooManyRequests)
+		}))
+		defer srv.Close()
+
+		n := newNotifier(t, srv)
+		n.Attempts = 2
+		n.Notify(context.Background(), payload)
+		assert.Equal(t, int32(2), calls.Load())
+	})
+
+	t.Run("Does not retry client errors", func(t *testing.T) {
+		t.Parallel()
+
+		var calls atomic.Int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+			w.WriteHeader(http.StatusBadRequest)
+		}))
+		defer srv.Close()
+
+		newNotifier(t, srv).Notify(context.Background(), payload)
+		assert.Equal(t, int32(1), calls.Load())
+	})
+}
+
+func TestNotifier_NotifyRedactsURL(t *testing.T) {
+	t.Parallel()
+
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, nil))
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	ok, err := ParseWebhook("gotify=" + srv.URL + "/message?token=s3cr3t")
+	require.NoError(t, err)
+	unreachable, err := ParseWebhook("ntfy=http://127.0.0.1:1/s3cr3t-topic")
+	require.NoError(t, err)
+	n := New(nil, []Webhook{ok, unreachable}, logger)
+	n.Attempts = 2
+	n.Backoff = time.Millisecond
+	n.Notify(context.Background(), Payload{ID: "2025-06-01T03:00:00Z"})
+
+	assert.Contains(t, logs.String(), "Retrying webhook")
+	assert.Contains(t, logs.String(), "Failed to deliver webhook")
+	assert.Contains(t, logs.String(), "host="+srv.URL)
+	assert.Contains(t, logs.String(), "host=http://127.0.0.1:1")
+	assert.NotContains(t, logs.String(), "s3cr3t")
+}
+
+func TestNotifier_Run(t *testing.T) {
+	t.Parallel()
+
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+
+	received := make(chan Payload, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		var p Payload
+		assert.NoError(t, json.Unmarshal(body, &p))
+		received <- p
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	writeRun(t, dir, "2025-06-01T03:00:00Z", snapraid.RunResult{})
+
+	index := runindex.New(dir, nil, logger)
+	require.NoError(t, index.Refresh())
+
+	w, err := ParseWebhook(srv.URL)
+	require.NoError(t, err)
+	n := New(index, []Webhook{w}, logger)
+	n.Source = "media"
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		n.Run(ctx)
+		close(done)
+	}()
+
+	// Wait until the notifier has subscribed before adding a run.
+	require.Eventually(t, func() bool {
+		writeRun(t, dir, "2025-06-02T03:00:00Z", snapraid.RunResult{
+			Result: snapraid.DiffResult{Removed: []string{"a", "b"}},
+		})
+		require.NoError(t, index.Refresh())
+		select {
+		case p := <-received:
+			assert.Equal(t, "2025-06-02T03:00:00Z", p.ID)
+			assert.Equal(t, "media", p.Source)
+			assert.Equal(t, 2, p.Counts.Removed)
+			return true
+		case <-time.After(50 * time.Millisecond):
+			require.NoError(t, os.Remove(filepath.Join(dir, "2025-06-02T03:00:00Z.json")))
+			require.NoError(t, index.Refresh())
+			return false
+		}
+	}, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+	<-done
+}
+
+func writeRun(t *testing.T, dir, id string, run snapraid.RunResult) {
+	t.Helper()
+	data, err := json.Marshal(run)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, id+".json"), data, 0o600))
+}
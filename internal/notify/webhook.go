@@ -0,0 +1,143 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"text/template"
+)
+
+// Kind selects the body format of a webhook.
+type Kind string
+
+const (
+	KindGeneric Kind = "generic" // the payload as JSON, or a custom template
+	KindGotify  Kind = "gotify"  // Gotify message API
+	KindNtfy    Kind = "ntfy"    // ntfy topic URL, plain-text message
+	KindSlack   Kind = "slack"   // Slack-compatible incoming webhook
+)
+
+// kindFormat describes how a webhook kind renders its request.
+type kindFormat struct {
+	contentType string
+	body        string                           // text/template rendering the body
+	headers     func(h http.Header, d eventData) // optional extra headers
+}
+
+// kindFormats holds the built-in formats of all webhook kinds.
+var kindFormats = map[Kind]kindFormat{
+	KindGeneric: {
+		contentType: "application/json",
+		body:        `{{ json .Payload }}`,
+	},
+	KindGotify: {
+		contentType: "application/json",
+		body:        `{"title":{{ json .Title }},"message":{{ json .Message }},"priority":{{ if .Alert }}8{{ else }}2{{ end }}}`,
+	},
+	KindNtfy: {
+		contentType: "text/plain; charset=utf-8",
+		body:        `{{ .Message }}`,
+		headers: func(h http.Header, d eventData) {
+			h.Set("Title", d.Title)
+			if d.Alert {
+				h.Set("Priority", "high")
+				h.Set("Tags", "warning")
+			}
+		},
+	},
+	KindSlack: {
+		contentType: "application/json",
+		body:        `{"text":{{ json (printf "*%s*\n%s" .Title .Message) }}}`,
+	},
+}
+
+// Webhook is a configured notification target.
+type Webhook struct {
+	Kind Kind
+	URL  string
+	tmpl *template.Template
+}
+
+// ParseWebhook parses a webhook definition of the form "[KIND=]URL".
+// KIND defaults to generic.
+func ParseWebhook(s string) (Webhook, error) {
+	kind, rawURL := KindGeneric, s
+	if k, u, ok := strings.Cut(s, "="); ok && !strings.Contains(k, "/") {
+		kind, rawURL = Kind(k), u
+	}
+	if _, ok := kindFormats[kind]; !ok {
+		return Webhook{}, fmt.Errorf("unknown webhook kind %q", kind)
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return Webhook{}, fmt.Errorf("invalid webhook URL %q", rawURL)
+	}
+
+	return Webhook{Kind: kind, URL: rawURL}, nil
+}
+
+// Host returns the scheme and host of the webhook URL for logging. The path
+// and query are left out, since they often hold secrets such as the app
+// token of Gotify, the webhook path of Slack or the topic of ntfy.
+func (w Webhook) Host() string {
+	u, err := url.Parse(w.URL)
+	if err != nil {
+		return ""
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+// WithTemplate returns a copy of the webhook rendering its body with the
+// given text/template instead of the built-in format of its kind.
+func (w Webhook) WithTemplate(text string) (Webhook, error) {
+	tmpl, err := parseBody(text)
+	if err != nil {
+		return Webhook{}, err
+	}
+	w.tmpl = tmpl
+	return w, nil
+}
+
+// parseBody parses a body template with the notification template functions.
+func parseBody(text string) (*template.Template, error) {
+	return template.New("body").
+		Funcs(template.FuncMap{
+			"json": func(v any) (string, error) {
+				b, err := json.Marshal(v)
+				return string(b), err
+			},
+		}).
+		Parse(text)
+}
+
+// newRequest renders the webhook request for the given event data.
+func (w Webhook) newRequest(d eventData) (*http.Request, error) {
+	format := kindFormats[w.Kind]
+	tmpl := w.tmpl
+	if tmpl == nil {
+		var err error
+		if tmpl, err = parseBody(format.body); err != nil {
+			return nil, err
+		}
+	}
+
+	var body bytes.Buffer
+	if err := tmpl.Execute(&body, d); err != nil {
+		return nil, fmt.Errorf("render webhook body: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, w.URL, &body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", format.contentType)
+	req.Header.Set("User-Agent", "go-snapraid-web")
+	if format.headers != nil {
+		format.headers(req.Header, d)
+	}
+	return req, nil
+}
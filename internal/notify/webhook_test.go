@@ -0,0 +1,142 @@
+package notify
+
+import (
+	"io"
+	"testing"
+
+	"github.com/gi8lino/go-snapraid-web/internal/runindex"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseWebhook(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Defaults to generic", func(t *testing.T) {
+		t.Parallel()
+
+		w, err := ParseWebhook("https://example.com/hook?a=b")
+		require.NoError(t, err)
+		assert.Equal(t, KindGeneric, w.Kind)
+		assert.Equal(t, "https://example.com/hook?a=b", w.URL)
+	})
+
+	t.Run("Explicit kind", func(t *testing.T) {
+		t.Parallel()
+
+		w, err := ParseWebhook("ntfy=https://ntfy.sh/snapraid")
+		require.NoError(t, err)
+		assert.Equal(t, KindNtfy, w.Kind)
+		assert.Equal(t, "https://ntfy.sh/snapraid", w.URL)
+	})
+
+	t.Run("Unknown kind", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := ParseWebhook("teams=https://example.com")
+		require.Error(t, err)
+		assert.EqualError(t, err, `unknown webhook kind "teams"`)
+	})
+
+	t.Run("Invalid URL", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := ParseWebhook("slack=ftp://example.com")
+		require.Error(t, err)
+		assert.EqualError(t, err, `invalid webhook URL "ftp://example.com"`)
+	})
+}
+
+func TestWebhook_newRequest(t *testing.T) {
+	t.Parallel()
+
+	d := newEventData(Payload{
+		ID:        "2025-06-01T03:00:00Z",
+		Status:    runindex.StatusFailed,
+		Error:     "disk full",
+		Counts:    Counts{Added: 1, Removed: 2},
+		Anomalies: []runindex.Anomaly{},
+	})
+
+	body := func(t *testing.T, w Webhook) (string, string) {
+		t.Helper()
+		req, err := w.newRequest(d)
+		require.NoError(t, err)
+		b, err := io.ReadAll(req.Body)
+		require.NoError(t, err)
+		return req.Header.Get("Content-Type"), string(b)
+	}
+
+	t.Run("Generic", func(t *testing.T) {
+		t.Parallel()
+
+		ct, b := body(t, Webhook{Kind: KindGeneric, URL: "http://x"})
+		assert.Equal(t, "application/json", ct)
+		assert.JSONEq(t, `{
+			"id": "2025-06-01T03:00:00Z",
+			"date": "0001-01-01T00:00:00Z",
+			"status": "failed",
+			"error": "disk full",
+			"counts": {"equal":0,"added":1,"removed":2,"updated":0,"moved":0,"copied":0,"restored":0,"total":0},
+			"timings": {"touch_seconds":0,"diff_seconds":0,"sync_seconds":0,"scrub_seconds":0,"smart_seconds":0,"total_seconds":0},
+			"anomalies": []
+		}`, b)
+	})
+
+	t.Run("Gotify", func(t *testing.T) {
+		t.Parallel()
+
+		_, b := body(t, Webhook{Kind: KindGotify, URL: "http://x"})
+		assert.JSONEq(t, `{
+			"title": "SnapRAID run 2025-06-01T03:00:00Z: failed",
+			"message": "1 added, 2 removed, 0 updated, 0 moved, 0 copied, 0 restored (0s total)\nError: disk full",
+			"priority": 8
+		}`, b)
+	})
+
+	t.Run("Ntfy", func(t *testing.T) {
+		t.Parallel()
+
+		req, err := Webhook{Kind: KindNtfy, URL: "http://x"}.newRequest(d)
+		require.NoError(t, err)
+		b, err := io.ReadAll(req.Body)
+		require.NoError(t, err)
+		assert.Equal(t, "1 added, 2 removed, 0 updated, 0 moved, 0 copied, 0 restored (0s total)\nError: disk full", string(b))
+		assert.Equal(t, "SnapRAID run 2025-06-01T03:00:00Z: failed", req.Header.Get("Title"))
+		assert.Equal(t, "high", req.Header.Get("Priority"))
+	})
+
+	t.Run("Slack", func(t *testing.T) {
+		t.Parallel()
+
+		_, b := body(t, Webhook{Kind: KindSlack, URL: "http://x"})
+		assert.JSONEq(t, `{"text":"*SnapRAID run 2025-06-01T03:00:00Z: failed*\n1 added, 2 removed, 0 updated, 0 moved, 0 copied, 0 restored (0s total)\nError: disk full"}`, b)
+	})
+
+	t.Run("Source", func(t *testing.T) {
+		t.Parallel()
+
+		d := newEventData(Payload{ID: "2025-06-01T03:00:00Z", Source: "media", Status: runindex.StatusSuccess})
+		req, err := Webhook{Kind: KindGotify, URL: "http://x"}.newRequest(d)
+		require.NoError(t, err)
+		b, err := io.ReadAll(req.Body)
+		require.NoError(t, err)
+		assert.Contains(t, string(b), `"title":"SnapRAID run 2025-06-01T03:00:00Z on media: success"`)
+	})
+
+	t.Run("Custom template", func(t *testing.T) {
+		t.Parallel()
+
+		w, err := Webhook{Kind: KindGeneric, URL: "http://x"}.WithTemplate(`{"run":{{ json .ID }},"removed":{{ .Counts.Removed }}}`)
+		require.NoError(t, err)
+		_, b := body(t, w)
+		assert.JSONEq(t, `{"run":"2025-06-01T03:00:00Z","removed":2}`, b)
+	})
+
+	t.Run("Invalid template", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := Webhook{}.WithTemplate(`{{ .ID `)
+		require.Error(t, err)
+	})
+}
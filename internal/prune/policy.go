@@ -0,0 +1,82 @@
+package prune
+
+import (
+	"errors"
+	"slices"
+	"time"
+)
+
+// Policy selects the runs to keep. A run is kept if any rule selects it;
+// all other runs are pruned.
+type Policy struct {
+	KeepLast   int // number of most recent runs to keep
+	KeepDaily  int // number of days, including today, for which the newest run of each day is kept
+	KeepWeekly int // number of weeks, including the current one, for which the newest run of each week is kept
+}
+
+// Validate rejects negative values and policies without any rule, which
+// would prune every run.
+func (p Policy) Validate() error {
+	if p.KeepLast < 0 || p.KeepDaily < 0 || p.KeepWeekly < 0 {
+		return errors.New("retention values must not be negative")
+	}
+	if p.KeepLast == 0 && p.KeepDaily == 0 && p.KeepWeekly == 0 {
+		return errors.New("no retention rule set, refusing to prune all runs")
+	}
+	return nil
+}
+
+// Decision is the outcome of the policy for a run.
+type Decision struct {
+	Run
+	Keep    bool
+	Reasons []string // rules keeping the run: last, daily or weekly
+	Action  Action   // applied to the run, empty if it is kept or nothing is left to do
+}
+
+// Apply decides for each run whether to keep it, relative to now. Days and
+// weeks are in UTC, weeks start on Monday. Decisions are returned newest first.
+func (p Policy) Apply(runs []Run, now time.Time) []Decision {
+	sorted := slices.Clone(runs)
+	slices.SortFunc(sorted, func(a, b Run) int { return b.Time.Compare(a.Time) })
+
+	today := startOfDay(now.UTC())
+	firstDay := today.AddDate(0, 0, -(p.KeepDaily - 1))
+	firstWeek := startOfWeek(today).AddDate(0, 0, -7*(p.KeepWeekly-1))
+
+	days := make(map[time.Time]bool)
+	weeks := make(map[time.Time]bool)
+	decisions := make([]Decision, len(sorted))
+	for i, r := range sorted {
+		d := Decision{Run: r}
+		if i < p.KeepLast {
+			d.Reasons = append(d.Reasons, "last")
+		}
+
+		// runs are sorted newest first, so the first run seen of a day or week is its newest
+		day := startOfDay(r.Time.UTC())
+		if p.KeepDaily > 0 && !day.Before(firstDay) && !days[day] {
+			days[day] = true
+			d.Reasons = append(d.Reasons, "daily")
+		}
+		week := startOfWeek(day)
+		if p.KeepWeekly > 0 && !week.Before(firstWeek) && !weeks[week] {
+			weeks[week] = true
+			d.Reasons = append(d.Reasons, "weekly")
+		}
+
+		d.Keep = len(d.Reasons) > 0
+		decisions[i] = d
+	}
+	return decisions
+}
+
+// startOfDay truncates t to midnight.
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// startOfWeek returns the Monday of the week of day.
+func startOfWeek(day time.Time) time.Time {
+	return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+}
@@ -0,0 +1,112 @@
+package prune
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicy_Validate(t *testing.T) {
+	t.Parallel()
+
+	require.NoError(t, Policy{KeepLast: 1}.Validate())
+	require.NoError(t, Policy{KeepWeekly: 4}.Validate())
+
+	err := Policy{}.Validate()
+	require.Error(t, err)
+	assert.EqualError(t, err, "no retention rule set, refusing to prune all runs")
+
+	err = Policy{KeepLast: 3, KeepDaily: -1}.Validate()
+	require.Error(t, err)
+	assert.EqualError(t, err, "retention values must not be negative")
+}
+
+func TestPolicy_Apply(t *testing.T) {
+	t.Parallel()
+
+	// Wednesday
+	now := time.Date(2025, 6, 11, 12, 0, 0, 0, time.UTC)
+	runs := []Run{
+		{ID: "2025-05-26T03:00:00Z", Time: time.Date(2025, 5, 26, 3, 0, 0, 0, time.UTC)}, // Monday, two weeks ago
+		{ID: "2025-06-02T03:00:00Z", Time: time.Date(2025, 6, 2, 3, 0, 0, 0, time.UTC)},  // Monday, last week
+		{ID: "2025-06-06T03:00:00Z", Time: time.Date(2025, 6, 6, 3, 0, 0, 0, time.UTC)},  // Friday, last week
+		{ID: "2025-06-10T03:00:00Z", Time: time.Date(2025, 6, 10, 3, 0, 0, 0, time.UTC)}, // yesterday
+		{ID: "2025-06-11T03:00:00Z", Time: time.Date(2025, 6, 11, 3, 0, 0, 0, time.UTC)}, // today
+		{ID: "2025-06-11T09:00:00Z", Time: time.Date(2025, 6, 11, 9, 0, 0, 0, time.UTC)}, // today, newest
+		{ID: "2025-06-09T03:00:00Z", Time: time.Date(2025, 6, 9, 3, 0, 0, 0, time.UTC)},  // Monday, this week
+	}
+
+	kept := func(decisions []Decision) map[string][]string {
+		out := map[string][]string{}
+		for _, d := range decisions {
+			if d.Keep {
+				out[d.ID] = d.Reasons
+			}
+		}
+		return out
+	}
+
+	t.Run("Newest first", func(t *testing.T) {
+		t.Parallel()
+
+		decisions := Policy{KeepLast: 1}.Apply(runs, now)
+		require.Len(t, decisions, len(runs))
+		assert.Equal(t, "2025-06-11T09:00:00Z", decisions[0].ID)
+		assert.Equal(t, "2025-05-26T03:00:00Z", decisions[len(decisions)-1].ID)
+	})
+
+	t.Run("Keep last", func(t *testing.T) {
+		t.Parallel()
+
+		decisions := Policy{KeepLast: 2}.Apply(runs, now)
+		assert.Equal(t, map[string][]string{
+			"2025-06-11T09:00:00Z": {"last"},
+			"2025-06-11T03:00:00Z": {"last"},
+		}, kept(decisions))
+	})
+
+	t.Run("Keep daily", func(t *testing.T) {
+		t.Parallel()
+
+		decisions := Policy{KeepDaily: 3}.Apply(runs, now)
+		assert.Equal(t, map[string][]string{
+			"2025-06-11T09:00:00Z": {"daily"},
+			"2025-06-10T03:00:00Z": {"daily"},
+			"2025-06-09T03:00:00Z": {"daily"},
+		}, kept(decisions))
+	})
+
+	t.Run("Keep weekly", func(t *testing.T) {
+		t.Parallel()
+
+		decisions := Policy{KeepWeekly: 2}.Apply(runs, now)
+		assert.Equal(t, map[string][]string{
+			"2025-06-11T09:00:00Z": {"weekly"},
+			"2025-06-06T03:00:00Z": {"weekly"},
+		}, kept(decisions))
+	})
+
+	t.Run("Combined rules", func(t *testing.T) {
+		t.Parallel()
+
+		decisions := Policy{KeepLast: 1, KeepDaily: 2, KeepWeekly: 3}.Apply(runs, now)
+		assert.Equal(t, map[string][]string{
+			"2025-06-11T09:00:00Z": {"last", "daily", "weekly"},
+			"2025-06-10T03:00:00Z": {"daily"},
+			"2025-06-06T03:00:00Z": {"weekly"},
+			"2025-05-26T03:00:00Z": {"weekly"},
+		}, kept(decisions))
+	})
+}
+
+func TestStartOfWeek(t *testing.T) {
+	t.Parallel()
+
+	monday := time.Date(2025, 6, 9, 0, 0, 0, 0, time.UTC)
+	for d := range 7 {
+		day := monday.AddDate(0, 0, d)
+		assert.Equal(t, monday, startOfWeek(day), day.Weekday().String())
+	}
+}
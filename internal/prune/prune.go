@@ -0,0 +1,303 @@
+package prune
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/gi8lino/go-snapraid-web/internal/runindex"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Action is what happens to the files of a pruned run.
+type Action string
+
+const (
+	ActionDelete Action = "delete" // remove all files of the run
+	ActionGzip   Action = "gzip"   // compress the run file to .json.gz
+	ActionZstd   Action = "zstd"   // compress the run file to .json.zst
+)
+
+// ParseAction parses a prune action; empty means delete.
+func ParseAction(s string) (Action, error) {
+	switch a := Action(s); a {
+	case "":
+		return ActionDelete, nil
+	case ActionDelete, ActionGzip, ActionZstd:
+		return a, nil
+	default:
+		return "", fmt.Errorf("invalid prune action %q", s)
+	}
+}
+
+// compresses reports whether the action keeps the run in compressed form.
+func (a Action) compresses() bool {
+	return a == ActionGzip || a == ActionZstd
+}
+
+// Run is a run in the output directory with all of its files.
+type Run struct {
+	ID    string
+	Time  time.Time
+	Files []string // file names, uncompressed first
+}
+
+// uncompressed returns the name of the uncompressed run file, if any.
+func (r Run) uncompressed() (string, bool) {
+	i := slices.IndexFunc(r.Files, func(name string) bool { return strings.HasSuffix(name, ".json") })
+	if i < 0 {
+		return "", false
+	}
+	return r.Files[i], true
+}
+
+// Scan lists the runs in dir. Files that are not run files are ignored.
+func Scan(dir string) ([]Run, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("read directory %q failed: %w", dir, err)
+	}
+
+	var runs []Run
+	byID := make(map[string]int)
+	for _, de := range entries {
+		if de.IsDir() {
+			continue
+		}
+		id, t, ok := runindex.ParseID(de.Name())
+		if !ok {
+			continue
+		}
+		i, ok := byID[id]
+		if !ok {
+			i = len(runs)
+			byID[id] = i
+			runs = append(runs, Run{ID: id, Time: t})
+		}
+		// entries are sorted by name, so the uncompressed file comes first
+		runs[i].Files = append(runs[i].Files, de.Name())
+	}
+	return runs, nil
+}
+
+// Pruner applies a retention policy to the run files of a directory.
+type Pruner struct {
+	dir    string
+	policy Policy
+	action Action
+	logger *slog.Logger
+
+	Now func() time.Time // reference time of the policy, defaults to time.Now
+}
+
+// New returns a Pruner for dir. Runs not kept by policy are deleted or
+// compressed, depending on action.
+func New(dir string, policy Policy, action Action, logger *slog.Logger) *Pruner {
+	return &Pruner{
+		dir:    dir,
+		policy: policy,
+		action: action,
+		logger: logger,
+		Now:    time.Now,
+	}
+}
+
+// Prune applies the policy to the directory and returns the decisions for
+// all runs, newest first. With dryRun, no file is changed. Pruning stops at
+// the first run that cannot be deleted or compressed.
+func (p *Pruner) Prune(dryRun bool) ([]Decision, error) {
+	runs, err := Scan(p.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	decisions := p.policy.Apply(runs, p.Now())
+	for i := range decisions {
+		d := &decisions[i]
+		if d.Keep {
+			continue
+		}
+		if _, ok := d.uncompressed(); p.action.compresses() && !ok {
+			continue // compressed before
+		}
+		d.Action = p.action
+		if dryRun {
+			continue
+		}
+		if err := p.apply(d.Run); err != nil {
+			return decisions, fmt.Errorf("%s run %q failed: %w", p.action, d.ID, err)
+		}
+	}
+	return decisions, nil
+}
+
+// apply deletes or compresses the files of run.
+func (p *Pruner) apply(run Run) error {
+	if p.action == ActionDelete {
+		for _, name := range run.Files {
+			if err := os.Remove(filepath.Join(p.dir, name)); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		return nil
+	}
+
+	name, _ := run.uncompressed()
+	return compress(p.dir, name, p.action)
+}
+
+// compress replaces the run file name in dir by a compressed copy. The copy
+// is written to a temporary file first, so the index never sees a partial
+// file, and keeps the permissions and modification time of the original.
+func compress(dir, name string, action Action) error {
+	src := filepath.Join(dir, name)
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close() // nolint:errcheck
+
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+name+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // nolint:errcheck
+	defer tmp.Close()           // nolint:errcheck
+
+	ext, err := writeCompressed(tmp, in, action)
+	if err != nil {
+		return err
+	}
+	if err := tmp.Chmod(info.Mode().Perm()); err != nil {
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chtimes(tmp.Name(), info.ModTime(), info.ModTime()); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), src+ext); err != nil {
+		return err
+	}
+	return os.Remove(src)
+}
+
+// writeCompressed compresses r into w and returns the extension appended to
+// the name of the compressed file.
+func writeCompressed(w io.Writer, r io.Reader, action Action) (string, error) {
+	var zw io.WriteCloser
+	ext := ".gz"
+	if action == ActionZstd {
+		enc, err := zstd.NewWriter(w)
+		if err != nil {
+			return "", err
+		}
+		zw, ext = enc, ".zst"
+	} else {
+		zw = gzip.NewWriter(w)
+	}
+
+	if _, err := io.Copy(zw, r); err != nil {
+		zw.Close() // nolint:errcheck
+		return "", err
+	}
+	return ext, zw.Close()
+}
+
+// Run prunes the directory every interval until ctx is cancelled, starting
+// immediately. Failures are logged and retried on the next interval.
+func (p *Pruner) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		decisions, err := p.Prune(false)
+		if err != nil {
+			p.logger.Error("failed to prune run files", "dir", p.dir, "error", err)
+		}
+		if pruned := countPruned(decisions); pruned > 0 {
+			p.logger.Info("pruned run files", "dir", p.dir, "action", p.action, "runs", pruned)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// countPruned returns the number of decisions with an action.
+func countPruned(decisions []Decision) int {
+	n := 0
+	for _, d := range decisions {
+		if d.Action != "" {
+			n++
+		}
+	}
+	return n
+}
+
+// WriteReport writes one line per run: the applied action, "keep" with the
+// rules keeping the run, or "skip" for runs compressed before. In a dry run,
+// actions are prefixed with "would". A summary line follows.
+func WriteReport(w io.Writer, decisions []Decision, action Action, dryRun bool) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	kept := 0
+	for _, d := range decisions {
+		switch {
+		case d.Keep:
+			kept++
+			_, _ = fmt.Fprintf(tw, "keep\t%s\t%s\n", d.ID, strings.Join(d.Reasons, ", "))
+		case d.Action == "":
+			_, _ = fmt.Fprintf(tw, "skip\t%s\talready compressed\n", d.ID)
+		default:
+			_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\n", reportVerb(d.Action, dryRun), d.ID, strings.Join(d.Files, ", "))
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	_, err := fmt.Fprintf(w, "%d runs kept, %d runs %s\n", kept, countPruned(decisions), reportSummary(action, dryRun))
+	return err
+}
+
+// reportVerb returns the report column of a pruned run.
+func reportVerb(action Action, dryRun bool) string {
+	if dryRun {
+		return "would " + string(action)
+	}
+	return string(action)
+}
+
+// reportSummary describes what happened to the pruned runs.
+func reportSummary(action Action, dryRun bool) string {
+	done := "deleted"
+	if action.compresses() {
+		done = "compressed with " + string(action)
+	}
+	if dryRun {
+		return "would be " + done
+	}
+	return done
+}
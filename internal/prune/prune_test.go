@@ -0,0 +1,229 @@
+package prune
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gi8lino/go-snapraid-web/internal/runindex"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAction(t *testing.T) {
+	t.Parallel()
+
+	a, err := ParseAction("")
+	require.NoError(t, err)
+	assert.Equal(t, ActionDelete, a)
+
+	a, err = ParseAction("zstd")
+	require.NoError(t, err)
+	assert.Equal(t, ActionZstd, a)
+
+	_, err = ParseAction("bzip2")
+	require.Error(t, err)
+	assert.EqualError(t, err, `invalid prune action "bzip2"`)
+}
+
+func TestScan(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	for _, name := range []string{
+		"2025-06-01T03:00:00Z.json",
+		"2025-06-01T03:00:00Z.json.gz",
+		"2025-06-02T03:00:00Z.json.zst",
+		"notes.txt",
+		".2025-06-03T03:00:00Z.json.123.tmp",
+	} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("{}"), 0o600))
+	}
+
+	runs, err := Scan(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []Run{
+		{
+			ID:    "2025-06-01T03:00:00Z",
+			Time:  time.Date(2025, 6, 1, 3, 0, 0, 0, time.UTC),
+			Files: []string{"2025-06-01T03:00:00Z.json", "2025-06-01T03:00:00Z.json.gz"},
+		},
+		{
+			ID:    "2025-06-02T03:00:00Z",
+			Time:  time.Date(2025, 6, 2, 3, 0, 0, 0, time.UTC),
+			Files: []string{"2025-06-02T03:00:00Z.json.zst"},
+		},
+	}, runs)
+
+	_, err = Scan(filepath.Join(dir, "missing"))
+	assert.Error(t, err)
+}
+
+func TestPruner_Prune(t *testing.T) {
+	t.Parallel()
+
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	now := func() time.Time { return time.Date(2025, 6, 10, 12, 0, 0, 0, time.UTC) }
+
+	// writeRuns creates an uncompressed run file per ID.
+	writeRuns := func(t *testing.T, dir string, ids ...string) {
+		t.Helper()
+		for _, id := range ids {
+			data := []byte(`{"timestamp":"` + id + `","result":{"added_files":["a"]}}`)
+			require.NoError(t, os.WriteFile(filepath.Join(dir, id+".json"), data, 0o640))
+		}
+	}
+	// names lists the file names in dir.
+	names := func(t *testing.T, dir string) []string {
+		t.Helper()
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		var out []string
+		for _, e := range entries {
+			out = append(out, e.Name())
+		}
+		return out
+	}
+
+	t.Run("Deletes runs", func(t *testing.T) {
+		t.Parallel()
+
+		dir := t.TempDir()
+		writeRuns(t, dir, "2025-06-01T03:00:00Z", "2025-06-02T03:00:00Z", "2025-06-03T03:00:00Z")
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "2025-06-01T03:00:00Z.json.gz"), []byte("x"), 0o600))
+
+		p := New(dir, Policy{KeepLast: 1}, ActionDelete, logger)
+		p.Now = now
+		decisions, err := p.Prune(false)
+		require.NoError(t, err)
+
+		require.Len(t, decisions, 3)
+		assert.True(t, decisions[0].Keep)
+		assert.Equal(t, ActionDelete, decisions[1].Action)
+		assert.Equal(t, ActionDelete, decisions[2].Action)
+		assert.Equal(t, []string{"2025-06-03T03:00:00Z.json"}, names(t, dir))
+	})
+
+	t.Run("Dry run changes nothing", func(t *testing.T) {
+		t.Parallel()
+
+		dir := t.TempDir()
+		writeRuns(t, dir, "2025-06-01T03:00:00Z", "2025-06-02T03:00:00Z")
+
+		p := New(dir, Policy{KeepLast: 1}, ActionGzip, logger)
+		p.Now = now
+		decisions, err := p.Prune(true)
+		require.NoError(t, err)
+
+		assert.Equal(t, ActionGzip, decisions[1].Action)
+		assert.Equal(t, []string{"2025-06-01T03:00:00Z.json", "2025-06-02T03:00:00Z.json"}, names(t, dir))
+	})
+
+	for _, tc := range []struct {
+		action Action
+		ext    string
+	}{
+		{ActionGzip, ".json.gz"},
+		{ActionZstd, ".json.zst"},
+	} {
+		t.Run("Compresses runs with "+string(tc.action), func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+			writeRuns(t, dir, "2025-06-01T03:00:00Z", "2025-06-02T03:00:00Z")
+			mtime := time.Date(2025, 6, 1, 3, 5, 0, 0, time.UTC)
+			require.NoError(t, os.Chtimes(filepath.Join(dir, "2025-06-01T03:00:00Z.json"), mtime, mtime))
+
+			p := New(dir, Policy{KeepLast: 1}, tc.action, logger)
+			p.Now = now
+			_, err := p.Prune(false)
+			require.NoError(t, err)
+			assert.Equal(t, []string{"2025-06-01T03:00:00Z" + tc.ext, "2025-06-02T03:00:00Z.json"}, names(t, dir))
+
+			info, err := os.Stat(filepath.Join(dir, "2025-06-01T03:00:00Z"+tc.ext))
+			require.NoError(t, err)
+			assert.Equal(t, os.FileMode(0o640), info.Mode().Perm())
+			assert.True(t, mtime.Equal(info.ModTime()))
+
+			// the index reads the compressed run unchanged
+			idx := runindex.New(dir, nil, logger)
+			require.NoError(t, idx.Refresh())
+			run, err := idx.Load("2025-06-01T03:00:00Z")
+			require.NoError(t, err)
+			assert.Equal(t, []string{"a"}, run.Result.Added)
+
+			// a second pass has nothing left to do
+			decisions, err := p.Prune(false)
+			require.NoError(t, err)
+			assert.False(t, decisions[1].Keep)
+			assert.Empty(t, decisions[1].Action)
+		})
+	}
+}
+
+func TestPruner_Run(t *testing.T) {
+	t.Parallel()
+
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, nil))
+
+	dir := t.TempDir()
+	for _, id := range []string{"2025-06-01T03:00:00Z", "2025-06-02T03:00:00Z"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, id+".json"), []byte("{}"), 0o600))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		New(dir, Policy{KeepLast: 1}, ActionDelete, logger).Run(ctx, time.Hour)
+		close(done)
+	}()
+
+	assert.Eventually(t, func() bool {
+		_, err := os.Stat(filepath.Join(dir, "2025-06-01T03:00:00Z.json"))
+		return os.IsNotExist(err)
+	}, time.Second, 10*time.Millisecond)
+
+	cancel()
+	<-done
+	assert.Contains(t, logs.String(), "pruned run files")
+	assert.FileExists(t, filepath.Join(dir, "2025-06-02T03:00:00Z.json"))
+}
+
+func TestWriteReport(t *testing.T) {
+	t.Parallel()
+
+	decisions := []Decision{
+		{Run: Run{ID: "2025-06-03T03:00:00Z"}, Keep: true, Reasons: []string{"last", "daily"}},
+		{Run: Run{ID: "2025-06-02T03:00:00Z", Files: []string{"2025-06-02T03:00:00Z.json"}}, Action: ActionGzip},
+		{Run: Run{ID: "2025-06-01T03:00:00Z", Files: []string{"2025-06-01T03:00:00Z.json.gz"}}},
+	}
+
+	t.Run("Dry run", func(t *testing.T) {
+		t.Parallel()
+
+		var buf bytes.Buffer
+		require.NoError(t, WriteReport(&buf, decisions, ActionGzip, true))
+		assert.Equal(t, ""+
+			"keep        2025-06-03T03:00:00Z  last, daily\n"+
+			"would gzip  2025-06-02T03:00:00Z  2025-06-02T03:00:00Z.json\n"+
+			"skip        2025-06-01T03:00:00Z  already compressed\n"+
+			"1 runs kept, 1 runs would be compressed with gzip\n",
+			buf.String())
+	})
+
+	t.Run("Applied", func(t *testing.T) {
+		t.Parallel()
+
+		var buf bytes.Buffer
+		require.NoError(t, WriteReport(&buf, decisions[:1], ActionDelete, false))
+		assert.Equal(t, ""+
+			"keep  2025-06-03T03:00:00Z  last, daily\n"+
+			"1 runs kept, 0 runs deleted\n",
+			buf.String())
+	})
+}
@@ -0,0 +1,303 @@
+// Package remote mirrors the run history of other go-snapraid-web instances
+// into a local cache directory, using their JSON API.
+package remote
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/gi8lino/go-snapraid-web/internal/api"
+	"github.com/gi8lino/go-snapraid-web/internal/runindex"
+	"github.com/gi8lino/go-snapraid/pkg/snapraid"
+)
+
+// Mirror keeps a cache directory in sync with the runs of another instance.
+// Runs are written as regular run files, so the cache directory is indexed
+// like any local output directory.
+type Mirror struct {
+	base   *url.URL // base URL of the other instance, its query is kept on every request
+	dir    string
+	index  *runindex.Index
+	logger *slog.Logger
+
+	Client *http.Client // HTTP client used for fetches
+	Token  string       // bearer token sent with every request, empty sends none
+
+	mu     sync.Mutex
+	health runindex.Health
+}
+
+// New returns a Mirror of the instance at rawURL into dir. index must index
+// dir; it is refreshed after every fetch. A "source" query parameter in
+// rawURL selects a source of the other instance; credentials in rawURL are
+// sent as basic auth, set Token to authenticate with a bearer token instead.
+func New(rawURL, dir string, index *runindex.Index, logger *slog.Logger) (*Mirror, error) {
+	base, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if base.Scheme != "http" && base.Scheme != "https" || base.Host == "" {
+		return nil, fmt.Errorf("invalid URL %q, expected http(s)://HOST[/PATH]", rawURL)
+	}
+	return &Mirror{
+		base:   base,
+		dir:    dir,
+		index:  index,
+		logger: logger,
+		Client: &http.Client{Timeout: 30 * time.Second},
+		health: runindex.Health{URL: base.Redacted()},
+	}, nil
+}
+
+// Health returns the state of the last fetch.
+func (m *Mirror) Health() runindex.Health {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.health
+}
+
+// Run fetches the runs every interval until ctx is cancelled, starting
+// immediately. Failures are logged and retried on the next interval.
+func (m *Mirror) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := m.Sync(ctx); err != nil && ctx.Err() == nil {
+			m.logger.Error("failed to fetch remote runs", "url", m.base.Redacted(), "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sync fetches the list of runs and downloads every run that is missing or
+// differs from its cached copy. Cached runs the other instance no longer has
+// are removed.
+func (m *Mirror) Sync(ctx context.Context) error {
+	start := time.Now()
+	err := m.sync(ctx)
+
+	m.mu.Lock()
+	m.health.LastFetch = start
+	m.health.Error = ""
+	if err != nil {
+		m.health.Error = err.Error()
+	} else {
+		m.health.LastSuccess = start
+	}
+	m.mu.Unlock()
+
+	return err
+}
+
+// sync performs a single fetch.
+func (m *Mirror) sync(ctx context.Context) error {
+	if err := os.MkdirAll(m.dir, 0o755); err != nil {
+		return err
+	}
+
+	var list struct {
+		Runs []api.RunSummary `json:"runs"`
+	}
+	if err := m.get(ctx, &list, "api/v1/runs"); err != nil {
+		return err
+	}
+
+	remote := make(map[string]bool, len(list.Runs))
+	var fetched int
+	for _, s := range list.Runs {
+		if _, _, ok := runindex.ParseID(s.ID + ".json"); !ok {
+			return fmt.Errorf("invalid run ID %q", s.ID)
+		}
+		remote[s.ID] = true
+		if cached, ok := m.index.Get(s.ID); ok && unchanged(cached, s) {
+			continue
+		}
+
+		var detail api.RunDetail
+		if err := m.get(ctx, &detail, "api/v1/runs", url.PathEscape(s.ID)); err != nil {
+			return err
+		}
+		if err := checkFiles(detail); err != nil {
+			return fmt.Errorf("run %q: %w", s.ID, err)
+		}
+		if err := writeRun(m.dir, s.ID, detail); err != nil {
+			return fmt.Errorf("write run %q failed: %w", s.ID, err)
+		}
+		fetched++
+	}
+
+	removed, err := removeStale(m.dir, remote)
+	if err != nil {
+		return err
+	}
+	if fetched > 0 || removed > 0 {
+		m.logger.Info("fetched remote runs", "url", m.base.Redacted(), "fetched", fetched, "removed", removed)
+	}
+
+	return m.index.Refresh()
+}
+
+// get decodes the JSON response of the API endpoint below the base URL,
+// given as escaped path elements, into v.
+func (m *Mirror) get(ctx context.Context, v any, elem ...string) error {
+	u := m.base.JoinPath(elem...)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if m.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+m.Token)
+	}
+
+	resp, err := m.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close() // nolint:errcheck
+
+	if resp.StatusCode != http.StatusOK {
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10)) // allow connection reuse
+		return fmt.Errorf("GET %s: unexpected status %s", u.Redacted(), resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("GET %s: decode response failed: %w", u.Redacted(), err)
+	}
+	return nil
+}
+
+// unchanged reports whether the cached run matches the listed remote run.
+func unchanged(cached runindex.Summary, s api.RunSummary) bool {
+	return cached.Status == s.Status &&
+		cached.Error == s.Error &&
+		cached.Timestamp == s.Date &&
+		cached.Equal == s.Counts.Equal &&
+		cached.Total() == s.Counts.Total
+}
+
+// checkFiles reports an error if the file lists of a run do not match its
+// counts. The other instance omits the file lists for viewers when file
+// paths are hidden, and caching such a run would lose its files.
+func checkFiles(d api.RunDetail) error {
+	lists := []struct {
+		category runindex.Category
+		files    []string
+		count    int
+	}{
+		{runindex.CategoryAdded, d.RawFiles.Added, d.Counts.Added},
+		{runindex.CategoryRemoved, d.RawFiles.Removed, d.Counts.Removed},
+		{runindex.CategoryUpdated, d.RawFiles.Updated, d.Counts.Updated},
+		{runindex.CategoryMoved, d.RawFiles.Moved, d.Counts.Moved},
+		{runindex.CategoryCopied, d.RawFiles.Copied, d.Counts.Copied},
+		{runindex.CategoryRestored, d.RawFiles.Restored, d.Counts.Restored},
+	}
+	for _, l := range lists {
+		if len(l.files) != l.count {
+			return fmt.Errorf("lists %d of %d %s files, the remote may hide file paths from this instance", len(l.files), l.count, l.category)
+		}
+	}
+	return nil
+}
+
+// runFile is the content of a run file as written by go-snapraid.
+type runFile struct {
+	Timestamp string              `json:"timestamp"`
+	Result    snapraid.DiffResult `json:"result"`
+	Timings   snapraid.RunTimings `json:"timings"`
+	Error     string              `json:"error,omitempty"`
+}
+
+// newRunFile converts a run of the API back into a run file. The raw paths
+// are used, so SnapRAID escapes are decoded again when the file is indexed.
+func newRunFile(d api.RunDetail) runFile {
+	return runFile{
+		Timestamp: d.Date,
+		Result: snapraid.DiffResult{
+			Equal:    d.Counts.Equal,
+			Added:    d.RawFiles.Added,
+			Removed:  d.RawFiles.Removed,
+			Updated:  d.RawFiles.Updated,
+			Moved:    d.RawFiles.Moved,
+			Copied:   d.RawFiles.Copied,
+			Restored: d.RawFiles.Restored,
+		},
+		Timings: snapraid.RunTimings{
+			Touch: seconds(d.Timings.Touch),
+			Diff:  seconds(d.Timings.Diff),
+			Sync:  seconds(d.Timings.Sync),
+			Scrub: seconds(d.Timings.Scrub),
+			Smart: seconds(d.Timings.Smart),
+			Total: seconds(d.Timings.Total),
+		},
+		Error: d.Error,
+	}
+}
+
+// seconds converts a duration in seconds as reported by the API.
+func seconds(s float64) time.Duration {
+	return time.Duration(math.Round(s * float64(time.Second)))
+}
+
+// writeRun writes the run file of id into dir. The file is written to a
+// temporary file first, so the index never sees a partial file.
+func writeRun(dir, id string, d api.RunDetail) error {
+	tmp, err := os.CreateTemp(dir, "."+id+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // nolint:errcheck
+	defer tmp.Close()           // nolint:errcheck
+
+	if err := json.NewEncoder(tmp).Encode(newRunFile(d)); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(dir, id+".json"))
+}
+
+// removeStale removes the cached run files whose ID is not in keep and
+// returns the number of removed files.
+func removeStale(dir string, keep map[string]bool) (int, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, err
+	}
+
+	var errs []error
+	removed := 0
+	for _, de := range entries {
+		if de.IsDir() {
+			continue
+		}
+		id, _, ok := runindex.ParseID(de.Name())
+		if !ok || keep[id] {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, de.Name())); err != nil && !os.IsNotExist(err) {
+			errs = append(errs, err)
+			continue
+		}
+		removed++
+	}
+	return removed, errors.Join(errs...)
+}
@@ -0,0 +1,64 @@
+package runindex
+
+import "fmt"
+
+// Threshold limits the number of changed files of a category in a single run.
+// A zero value disables the respective limit.
+type Threshold struct {
+	Count   int     // absolute number of files
+	Percent float64 // number of files relative to the equal files, in percent
+}
+
+// Thresholds maps file categories to their limits.
+type Thresholds map[Category]Threshold
+
+// Anomaly describes a category of a run that exceeded its threshold.
+type Anomaly struct {
+	Category Category `json:"category"`
+	Count    int      `json:"count"`   // number of files in the category
+	Percent  float64  `json:"percent"` // number of files relative to the equal files, in percent
+	Reason   string   `json:"reason"`  // human-readable explanation
+}
+
+// percentOf returns n relative to equal in percent. Changes on an array
+// without equal files count as 100 percent.
+func percentOf(n, equal int) float64 {
+	if n == 0 {
+		return 0
+	}
+	if equal == 0 {
+		return 100
+	}
+	return float64(n) / float64(equal) * 100
+}
+
+// evaluate returns the anomalies of s, in category order.
+func (t Thresholds) evaluate(s Summary) []Anomaly {
+	var anomalies []Anomaly
+	for _, c := range Categories {
+		limit, ok := t[c]
+		if !ok {
+			continue
+		}
+
+		count := s.Count(c)
+		percent := percentOf(count, s.Equal)
+		switch {
+		case limit.Count > 0 && count >= limit.Count:
+			anomalies = append(anomalies, Anomaly{
+				Category: c,
+				Count:    count,
+				Percent:  percent,
+				Reason:   fmt.Sprintf("%d %s files reach the threshold of %d", count, c, limit.Count),
+			})
+		case limit.Percent > 0 && percent >= limit.Percent:
+			anomalies = append(anomalies, Anomaly{
+				Category: c,
+				Count:    count,
+				Percent:  percent,
+				Reason:   fmt.Sprintf("%d %s files (%.1f%% of equal) reach the threshold of %.1f%%", count, c, percent, limit.Percent),
+			})
+		}
+	}
+	return anomalies
+}
@@ -0,0 +1,90 @@
+package runindex
+
+import (
+	"bytes"
+	"log/slog"
+	"testing"
+
+	"github.com/gi8lino/go-snapraid/pkg/snapraid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestThresholds_Evaluate(t *testing.T) {
+	t.Parallel()
+
+	thresholds := Thresholds{
+		CategoryRemoved: {Count: 100, Percent: 5},
+		CategoryUpdated: {Percent: 50},
+	}
+
+	t.Run("Below thresholds", func(t *testing.T) {
+		t.Parallel()
+		s := Summary{Equal: 1000, Removed: 49, Updated: 400, Added: 5000}
+		assert.Empty(t, thresholds.evaluate(s))
+	})
+
+	t.Run("Count threshold", func(t *testing.T) {
+		t.Parallel()
+		s := Summary{Equal: 100000, Removed: 100}
+		assert.Equal(t, []Anomaly{{
+			Category: CategoryRemoved,
+			Count:    100,
+			Percent:  0.1,
+			Reason:   "100 removed files reach the threshold of 100",
+		}}, thresholds.evaluate(s))
+	})
+
+	t.Run("Percent threshold", func(t *testing.T) {
+		t.Parallel()
+		s := Summary{Equal: 1000, Removed: 50, Updated: 600}
+		assert.Equal(t, []Anomaly{
+			{
+				Category: CategoryRemoved,
+				Count:    50,
+				Percent:  5,
+				Reason:   "50 removed files (5.0% of equal) reach the threshold of 5.0%",
+			},
+			{
+				Category: CategoryUpdated,
+				Count:    600,
+				Percent:  60,
+				Reason:   "600 updated files (60.0% of equal) reach the threshold of 50.0%",
+			},
+		}, thresholds.evaluate(s))
+	})
+
+	t.Run("No equal files", func(t *testing.T) {
+		t.Parallel()
+		s := Summary{Removed: 1}
+		anomalies := thresholds.evaluate(s)
+		require.Len(t, anomalies, 1)
+		assert.Equal(t, 100.0, anomalies[0].Percent)
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		t.Parallel()
+		s := Summary{Removed: 1000}
+		assert.Empty(t, Thresholds{CategoryRemoved: {}}.evaluate(s))
+		assert.Empty(t, Thresholds(nil).evaluate(s))
+	})
+}
+
+func TestIndex_Anomalies(t *testing.T) {
+	t.Parallel()
+
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+
+	dir := t.TempDir()
+	writeRun(t, dir, "2025-06-01T03:00:00Z", snapraid.RunResult{
+		Result: snapraid.DiffResult{Equal: 10, Removed: []string{"a", "b"}},
+	})
+
+	idx := New(dir, Thresholds{CategoryRemoved: {Count: 2}}, logger)
+	require.NoError(t, idx.Refresh())
+
+	s, ok := idx.Get("2025-06-01T03:00:00Z")
+	require.True(t, ok)
+	require.Len(t, s.Anomalies, 1)
+	assert.Equal(t, CategoryRemoved, s.Anomalies[0].Category)
+}
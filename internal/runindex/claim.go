@@ -0,0 +1,71 @@
+package runindex
+
+import (
+	"context"
+	"slices"
+	"sync"
+)
+
+// Claim is held by a command writing run files into the directory of an
+// index. Commands claiming the same index run one at a time, so every new
+// run file is attributed to the command that produced it.
+type Claim struct {
+	idx    *Index
+	before []string // IDs indexed when the claim was taken
+	once   sync.Once
+}
+
+// Claim waits until no other command holds a claim on the index and takes
+// it. It returns ctx.Err() if ctx is cancelled first.
+func (idx *Index) Claim(ctx context.Context) (*Claim, error) {
+	select {
+	case idx.writer <- struct{}{}:
+		return &Claim{idx: idx, before: idx.IDs()}, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// TryClaim takes the claim on the index if no other command holds it.
+func (idx *Index) TryClaim() (*Claim, bool) {
+	select {
+	case idx.writer <- struct{}{}:
+		return &Claim{idx: idx, before: idx.IDs()}, true
+	default:
+		return nil, false
+	}
+}
+
+// Claimed reports whether a command holds a claim on the index.
+func (idx *Index) Claimed() bool {
+	return len(idx.writer) > 0
+}
+
+// Refresh refreshes the index and returns the ID of the newest run added
+// since the claim was taken, or an empty string if none appeared.
+func (c *Claim) Refresh() (string, error) {
+	if err := c.idx.Refresh(); err != nil {
+		return "", err
+	}
+	return newestNew(c.before, c.idx.IDs()), nil
+}
+
+// Release gives up the claim. Further calls have no effect.
+func (c *Claim) Release() {
+	c.once.Do(func() { <-c.idx.writer })
+}
+
+// newestNew returns the newest ID of after that is not in before. after is
+// sorted oldest first.
+func newestNew(before, after []string) string {
+	seen := make(map[string]bool, len(before))
+	for _, id := range before {
+		seen[id] = true
+	}
+	for _, id := range slices.Backward(after) {
+		if !seen[id] {
+			return id
+		}
+	}
+	return ""
+}
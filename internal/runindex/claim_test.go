@@ -0,0 +1,67 @@
+package runindex
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIndex_Claim(t *testing.T) {
+	t.Parallel()
+
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+
+	t.Run("One command at a time", func(t *testing.T) {
+		t.Parallel()
+
+		idx := New(t.TempDir(), nil, logger)
+		claim, ok := idx.TryClaim()
+		require.True(t, ok)
+		assert.True(t, idx.Claimed())
+
+		_, ok = idx.TryClaim()
+		assert.False(t, ok)
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		_, err := idx.Claim(ctx)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+		claim.Release()
+		claim.Release() // no effect
+		assert.False(t, idx.Claimed())
+		claim, err = idx.Claim(context.Background())
+		require.NoError(t, err)
+		claim.Release()
+	})
+
+	t.Run("Refresh returns the new run", func(t *testing.T) {
+		t.Parallel()
+
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "2025-06-01T03:00:00Z.json"), []byte(`{}`), 0o600))
+		idx := New(dir, nil, logger)
+		require.NoError(t, idx.Refresh())
+
+		claim, ok := idx.TryClaim()
+		require.True(t, ok)
+		defer claim.Release()
+
+		id, err := claim.Refresh()
+		require.NoError(t, err)
+		assert.Empty(t, id)
+
+		for _, name := range []string{"2025-06-02T03:00:00Z.json", "2025-06-02T04:00:00Z.json"} {
+			require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(`{}`), 0o600))
+		}
+		id, err = claim.Refresh()
+		require.NoError(t, err)
+		assert.Equal(t, "2025-06-02T04:00:00Z", id)
+	})
+}
@@ -0,0 +1,59 @@
+package runindex
+
+import (
+	"strings"
+
+	"github.com/gi8lino/go-snapraid/pkg/snapraid"
+)
+
+// Unescape decodes the shell-style backslash escapes SnapRAID writes into
+// paths, e.g. `filme/Zoolander\ \(2001\)` becomes `filme/Zoolander (2001)`.
+// A trailing lone backslash is kept.
+func Unescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// unescapeEntry decodes a file entry of a run. Both sides of moved and
+// copied entries ("old -> new") are decoded separately, so an escaped
+// separator inside a path does not split it.
+func unescapeEntry(entry string) string {
+	if from, to, ok := SplitMove(entry); ok {
+		return Unescape(from) + moveSeparator + Unescape(to)
+	}
+	return Unescape(entry)
+}
+
+// unescapeAll returns a copy of entries with all escapes decoded.
+func unescapeAll(entries []string) []string {
+	if entries == nil {
+		return nil
+	}
+	out := make([]string, len(entries))
+	for i, e := range entries {
+		out[i] = unescapeEntry(e)
+	}
+	return out
+}
+
+// normalize returns a copy of result with all file entries decoded.
+func normalize(result snapraid.DiffResult) snapraid.DiffResult {
+	result.Added = unescapeAll(result.Added)
+	result.Removed = unescapeAll(result.Removed)
+	result.Updated = unescapeAll(result.Updated)
+	result.Moved = unescapeAll(result.Moved)
+	result.Copied = unescapeAll(result.Copied)
+	result.Restored = unescapeAll(result.Restored)
+	return result
+}
@@ -0,0 +1,55 @@
+package runindex
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnescape(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{name: "plain path", in: "filme/a.mkv", want: "filme/a.mkv"},
+		{name: "spaces and parentheses", in: `filme/Zoolander\ \(2001\)/z.mkv`, want: "filme/Zoolander (2001)/z.mkv"},
+		{name: "quotes and ampersand", in: `serien/Tom\ \&\ Jerry/Jerry\'s.mkv`, want: "serien/Tom & Jerry/Jerry's.mkv"},
+		{name: "escaped backslash", in: `a\\b`, want: `a\b`},
+		{name: "trailing backslash", in: `a\`, want: `a\`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tt.want, Unescape(tt.in))
+		})
+	}
+}
+
+func TestUnescapeEntry(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "a b/x -> c d/x", unescapeEntry(`a\ b/x -> c\ d/x`))
+	assert.Equal(t, "a -> b.mkv", unescapeEntry(`a\ ->\ b.mkv`))
+}
+
+func TestDecodeRun_Unescapes(t *testing.T) {
+	t.Parallel()
+
+	run, err := decodeRun(strings.NewReader(`{"result":{
+		"added_files":["filme/Zoolander\\ \\(2001\\)/z.mkv"],
+		"moved_files":["a\\ b -> c\\ d"]
+	}}`))
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"filme/Zoolander (2001)/z.mkv"}, run.Files(CategoryAdded))
+	assert.Equal(t, []string{`filme/Zoolander\ \(2001\)/z.mkv`}, run.RawFiles(CategoryAdded))
+	assert.Equal(t, []string{"a b -> c d"}, run.Files(CategoryMoved))
+	assert.Equal(t, []string{`a\ b -> c\ d`}, run.RawFiles(CategoryMoved))
+	assert.Nil(t, run.Files(CategoryRemoved))
+}
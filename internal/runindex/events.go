@@ -0,0 +1,60 @@
+package runindex
+
+// EventType describes how a run changed.
+type EventType string
+
+const (
+	EventAdded   EventType = "added"   // a new run file appeared
+	EventChanged EventType = "changed" // an existing run file was modified
+	EventRemoved EventType = "removed" // a run file was deleted
+)
+
+// Event notifies subscribers about a change of the indexed runs.
+type Event struct {
+	Type EventType `json:"type"`
+	ID   string    `json:"id"` // run ID
+}
+
+// subscriberBuffer is the number of events buffered per subscriber.
+// Events are dropped for subscribers that fall behind.
+const subscriberBuffer = 64
+
+// Subscribe returns a channel receiving all future index events and a
+// function to cancel the subscription. The channel is closed on cancel.
+func (idx *Index) Subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBuffer)
+
+	idx.subMu.Lock()
+	idx.subs[ch] = struct{}{}
+	idx.subMu.Unlock()
+
+	cancel := func() {
+		idx.subMu.Lock()
+		defer idx.subMu.Unlock()
+		if _, ok := idx.subs[ch]; ok {
+			delete(idx.subs, ch)
+			close(ch)
+		}
+	}
+	return ch, cancel
+}
+
+// publish delivers events to all subscribers without blocking.
+func (idx *Index) publish(events []Event) {
+	if len(events) == 0 {
+		return
+	}
+
+	idx.subMu.Lock()
+	defer idx.subMu.Unlock()
+
+	for ch := range idx.subs {
+		for _, ev := range events {
+			select {
+			case ch <- ev:
+			default:
+				idx.logger.Warn("dropping run index event for slow subscriber", "type", ev.Type, "id", ev.ID)
+			}
+		}
+	}
+}
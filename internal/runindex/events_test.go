@@ -0,0 +1,82 @@
+package runindex
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gi8lino/go-snapraid/pkg/snapraid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIndex_Subscribe(t *testing.T) {
+	t.Parallel()
+
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+
+	t.Run("Publishes added, changed and removed runs", func(t *testing.T) {
+		t.Parallel()
+
+		dir := t.TempDir()
+		writeRun(t, dir, "2025-06-01T03:00:00Z", snapraid.RunResult{})
+
+		idx := New(dir, nil, logger)
+		require.NoError(t, idx.Refresh())
+
+		events, cancel := idx.Subscribe()
+		defer cancel()
+
+		writeRun(t, dir, "2025-06-02T03:00:00Z", snapraid.RunResult{})
+		require.NoError(t, idx.Refresh())
+		assert.Equal(t, Event{Type: EventAdded, ID: "2025-06-02T03:00:00Z"}, <-events)
+
+		future := time.Now().Add(time.Minute)
+		require.NoError(t, os.Chtimes(filepath.Join(dir, "2025-06-02T03:00:00Z.json"), future, future))
+		require.NoError(t, idx.Refresh())
+		assert.Equal(t, Event{Type: EventChanged, ID: "2025-06-02T03:00:00Z"}, <-events)
+
+		require.NoError(t, os.Remove(filepath.Join(dir, "2025-06-01T03:00:00Z.json")))
+		require.NoError(t, idx.Refresh())
+		assert.Equal(t, Event{Type: EventRemoved, ID: "2025-06-01T03:00:00Z"}, <-events)
+
+		require.NoError(t, idx.Refresh())
+		assert.Empty(t, events)
+	})
+
+	t.Run("Broken file removes run", func(t *testing.T) {
+		t.Parallel()
+
+		dir := t.TempDir()
+		writeRun(t, dir, "2025-06-01T03:00:00Z", snapraid.RunResult{})
+
+		idx := New(dir, nil, logger)
+		require.NoError(t, idx.Refresh())
+
+		events, cancel := idx.Subscribe()
+		defer cancel()
+
+		path := filepath.Join(dir, "2025-06-01T03:00:00Z.json")
+		require.NoError(t, os.WriteFile(path, []byte("{broken"), 0o600))
+		future := time.Now().Add(time.Minute)
+		require.NoError(t, os.Chtimes(path, future, future))
+		require.NoError(t, idx.Refresh())
+
+		assert.Equal(t, Event{Type: EventRemoved, ID: "2025-06-01T03:00:00Z"}, <-events)
+	})
+
+	t.Run("Cancel closes channel", func(t *testing.T) {
+		t.Parallel()
+
+		idx := New(t.TempDir(), nil, logger)
+		events, cancel := idx.Subscribe()
+		cancel()
+		cancel() // idempotent
+
+		_, ok := <-events
+		assert.False(t, ok)
+	})
+}
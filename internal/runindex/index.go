@@ -0,0 +1,266 @@
+package runindex
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrNotFound is returned when a requested run is not part of the index.
+var ErrNotFound = errors.New("run not found")
+
+// fileState identifies a version of a run file on disk.
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+// entry is an indexed run file.
+type entry struct {
+	name    string    // file name inside the directory
+	state   fileState // file state the summary was built from
+	summary Summary
+	changes []FileChange // file changes recorded by the run
+	valid   bool         // false if the file could not be decoded
+}
+
+// Index keeps summaries of all run files of a directory in memory.
+// It is safe for concurrent use.
+type Index struct {
+	dir        string
+	thresholds Thresholds
+	logger     *slog.Logger
+
+	refreshMu sync.Mutex // serializes Refresh, so no stale listing drops newer runs
+
+	mu      sync.RWMutex
+	entries map[string]*entry       // keyed by run ID
+	ids     []string                // IDs of valid entries, oldest first
+	paths   map[string][]FileChange // file changes of valid entries, keyed by path
+
+	subMu sync.Mutex
+	subs  map[chan Event]struct{}
+
+	writer chan struct{} // held by the command claiming the index, see Claim
+}
+
+// New returns an empty index for dir. Call Refresh to load it.
+// Runs exceeding the thresholds are flagged with anomalies.
+func New(dir string, thresholds Thresholds, logger *slog.Logger) *Index {
+	return &Index{
+		dir:        dir,
+		thresholds: thresholds,
+		logger:     logger,
+		entries:    make(map[string]*entry),
+		paths:      make(map[string][]FileChange),
+		subs:       make(map[chan Event]struct{}),
+		writer:     make(chan struct{}, 1),
+	}
+}
+
+// Dir returns the directory the index is built from.
+func (idx *Index) Dir() string { return idx.dir }
+
+// Refresh scans the directory and updates the index with new, modified
+// and removed run files. Unchanged files are not decoded again. Concurrent
+// calls run one after the other. A missing directory is treated as empty.
+// Changes are published to subscribers.
+func (idx *Index) Refresh() error {
+	idx.refreshMu.Lock()
+	defer idx.refreshMu.Unlock()
+
+	dirEntries, err := os.ReadDir(idx.dir)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("read directory %q failed: %w", idx.dir, err)
+	}
+
+	seen := make(map[string]string, len(dirEntries)) // file name per run ID
+	updates := make(map[string]*entry)
+
+	idx.mu.RLock()
+	for _, de := range dirEntries {
+		if de.IsDir() {
+			continue
+		}
+		id, _, ok := ParseID(de.Name())
+		if !ok {
+			continue
+		}
+		if name, dup := seen[id]; dup && extRank(name) <= extRank(de.Name()) {
+			continue // e.g. the original while its compressed copy is written
+		}
+		info, err := de.Info()
+		if err != nil {
+			continue // removed since ReadDir
+		}
+		seen[id] = de.Name()
+
+		state := fileState{modTime: info.ModTime(), size: info.Size()}
+		if e, ok := idx.entries[id]; ok && e.name == de.Name() && e.state == state {
+			delete(updates, id)
+			continue
+		}
+		updates[id] = &entry{name: de.Name(), state: state}
+	}
+	idx.mu.RUnlock()
+
+	// decode outside the lock so readers are not blocked by disk I/O
+	for _, e := range updates {
+		run, err := idx.read(e.name)
+		if err != nil {
+			idx.logger.Warn("skipping unreadable run file", "file", e.name, "error", err)
+			continue
+		}
+		e.summary = idx.summarize(run)
+		e.changes = fileChanges(run)
+		e.valid = true
+	}
+
+	idx.mu.Lock()
+	var events []Event
+	for id, e := range updates {
+		old, existed := idx.entries[id]
+		idx.entries[id] = e
+		if existed {
+			idx.unindexChanges(old.changes)
+		}
+		idx.indexChanges(e.changes)
+
+		switch wasValid := existed && old.valid; {
+		case e.valid && wasValid:
+			events = append(events, Event{Type: EventChanged, ID: id})
+		case e.valid:
+			events = append(events, Event{Type: EventAdded, ID: id})
+		case wasValid:
+			events = append(events, Event{Type: EventRemoved, ID: id})
+		}
+	}
+	for id, e := range idx.entries {
+		if _, ok := seen[id]; !ok {
+			delete(idx.entries, id)
+			idx.unindexChanges(e.changes)
+			if e.valid {
+				events = append(events, Event{Type: EventRemoved, ID: id})
+			}
+		}
+	}
+
+	idx.ids = idx.ids[:0]
+	for id, e := range idx.entries {
+		if e.valid {
+			idx.ids = append(idx.ids, id)
+		}
+	}
+	slices.Sort(idx.ids) // RFC3339 IDs sort lexicographically by time
+	idx.mu.Unlock()
+
+	slices.SortFunc(events, func(a, b Event) int { return strings.Compare(a.ID, b.ID) })
+	idx.publish(events)
+
+	return nil
+}
+
+// Watch refreshes the index every interval until ctx is cancelled.
+// Polling is used instead of inotify so network mounts are picked up as well.
+func (idx *Index) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := idx.Refresh(); err != nil {
+				idx.logger.Error("refresh run index", "error", err)
+			}
+		}
+	}
+}
+
+// IDs returns the IDs of all indexed runs, oldest first.
+func (idx *Index) IDs() []string {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	return slices.Clone(idx.ids)
+}
+
+// List returns the summaries of all indexed runs, newest first.
+func (idx *Index) List() []Summary {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	summaries := make([]Summary, 0, len(idx.ids))
+	for i := len(idx.ids) - 1; i >= 0; i-- {
+		summaries = append(summaries, idx.entries[idx.ids[i]].summary)
+	}
+	return summaries
+}
+
+// Get returns the summary of the run with the given ID.
+func (idx *Index) Get(id string) (Summary, bool) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	e, ok := idx.entries[id]
+	if !ok || !e.valid {
+		return Summary{}, false
+	}
+	return e.summary, true
+}
+
+// Latest returns the summary of the most recent run.
+func (idx *Index) Latest() (Summary, bool) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	if len(idx.ids) == 0 {
+		return Summary{}, false
+	}
+	return idx.entries[idx.ids[len(idx.ids)-1]].summary, true
+}
+
+// Load reads the full run, including its file lists, from disk.
+// It returns ErrNotFound if the run is not part of the index.
+func (idx *Index) Load(id string) (Run, error) {
+	idx.mu.RLock()
+	e, ok := idx.entries[id]
+	idx.mu.RUnlock()
+	if !ok || !e.valid {
+		return Run{}, ErrNotFound
+	}
+
+	run, err := idx.read(e.name)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return Run{}, ErrNotFound
+		}
+		return Run{}, err
+	}
+	return run, nil
+}
+
+// summarize returns the summary of run including its anomalies.
+func (idx *Index) summarize(run Run) Summary {
+	s := run.Summary()
+	s.Anomalies = idx.thresholds.evaluate(s)
+	return s
+}
+
+// read decodes the run file name inside the index directory.
+func (idx *Index) read(name string) (Run, error) {
+	run, err := readRun(filepath.Join(idx.dir, name))
+	if err != nil {
+		return Run{}, err
+	}
+	run.ID, run.Time, _ = ParseID(name)
+	return run, nil
+}
@@ -0,0 +1,343 @@
+package runindex
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gi8lino/go-snapraid/pkg/snapraid"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIndex_Refresh(t *testing.T) {
+	t.Parallel()
+
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+
+	t.Run("Loads run files", func(t *testing.T) {
+		t.Parallel()
+
+		dir := t.TempDir()
+		writeRun(t, dir, "2025-06-01T03:00:00Z", snapraid.RunResult{
+			Timestamp: "2025-06-01T03:00:00Z",
+			Result:    snapraid.DiffResult{Equal: 5, Added: []string{"a", "b"}},
+			Timings:   snapraid.RunTimings{Diff: time.Second},
+		})
+		writeRun(t, dir, "2025-06-02T03:00:00Z", snapraid.RunResult{
+			Result: snapraid.DiffResult{Removed: []string{"c"}},
+		})
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "notes.json"), []byte("{}"), 0o600))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("hi"), 0o600))
+
+		idx := New(dir, nil, logger)
+		require.NoError(t, idx.Refresh())
+
+		assert.Equal(t, []string{"2025-06-01T03:00:00Z", "2025-06-02T03:00:00Z"}, idx.IDs())
+
+		list := idx.List()
+		require.Len(t, list, 2)
+		assert.Equal(t, "2025-06-02T03:00:00Z", list[0].ID)
+		assert.Equal(t, 1, list[0].Removed)
+
+		s, ok := idx.Get("2025-06-01T03:00:00Z")
+		require.True(t, ok)
+		assert.Equal(t, 5, s.Equal)
+		assert.Equal(t, 2, s.Added)
+		assert.Equal(t, 2, s.Total())
+		assert.Equal(t, time.Second, s.Timings.Diff)
+		assert.Equal(t, time.Date(2025, 6, 1, 3, 0, 0, 0, time.UTC), s.Time)
+
+		latest, ok := idx.Latest()
+		require.True(t, ok)
+		assert.Equal(t, "2025-06-02T03:00:00Z", latest.ID)
+	})
+
+	t.Run("Tracks added, modified and removed files", func(t *testing.T) {
+		t.Parallel()
+
+		dir := t.TempDir()
+		writeRun(t, dir, "2025-06-01T03:00:00Z", snapraid.RunResult{})
+
+		idx := New(dir, nil, logger)
+		require.NoError(t, idx.Refresh())
+		assert.Equal(t, []string{"2025-06-01T03:00:00Z"}, idx.IDs())
+
+		writeRun(t, dir, "2025-06-02T03:00:00Z", snapraid.RunResult{})
+		writeRun(t, dir, "2025-06-01T03:00:00Z", snapraid.RunResult{
+			Result: snapraid.DiffResult{Added: []string{"new"}},
+		})
+		// make sure the modification is visible even on coarse mtime filesystems
+		future := time.Now().Add(time.Minute)
+		require.NoError(t, os.Chtimes(filepath.Join(dir, "2025-06-01T03:00:00Z.json"), future, future))
+
+		require.NoError(t, idx.Refresh())
+		assert.Equal(t, []string{"2025-06-01T03:00:00Z", "2025-06-02T03:00:00Z"}, idx.IDs())
+		s, _ := idx.Get("2025-06-01T03:00:00Z")
+		assert.Equal(t, 1, s.Added)
+
+		require.NoError(t, os.Remove(filepath.Join(dir, "2025-06-01T03:00:00Z.json")))
+		require.NoError(t, idx.Refresh())
+		assert.Equal(t, []string{"2025-06-02T03:00:00Z"}, idx.IDs())
+		_, ok := idx.Get("2025-06-01T03:00:00Z")
+		assert.False(t, ok)
+	})
+
+	t.Run("Concurrent refreshes announce each run once", func(t *testing.T) {
+		t.Parallel()
+
+		dir := t.TempDir()
+		idx := New(dir, nil, logger)
+		events, cancel := idx.Subscribe()
+		added := make(map[string]int)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for ev := range events {
+				if ev.Type == EventAdded {
+					added[ev.ID]++
+				}
+			}
+		}()
+
+		// refresh while runs are written, like Watch next to a claim
+		stop := make(chan struct{})
+		var wg sync.WaitGroup
+		for range 8 {
+			wg.Go(func() {
+				for {
+					select {
+					case <-stop:
+						return
+					default:
+						assert.NoError(t, idx.Refresh())
+					}
+				}
+			})
+		}
+		start := time.Date(2025, 6, 1, 3, 0, 0, 0, time.UTC)
+		for i := range 50 {
+			writeRun(t, dir, start.AddDate(0, 0, i).Format(time.RFC3339), snapraid.RunResult{})
+		}
+		close(stop)
+		wg.Wait()
+		require.NoError(t, idx.Refresh())
+		cancel()
+		<-done
+
+		assert.Len(t, added, 50)
+		for id, n := range added {
+			assert.Equal(t, 1, n, id)
+		}
+	})
+
+	t.Run("Skips undecodable files", func(t *testing.T) {
+		t.Parallel()
+
+		var logs bytes.Buffer
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "2025-06-01T03:00:00Z.json"), []byte("{broken"), 0o600))
+
+		idx := New(dir, nil, slog.New(slog.NewTextHandler(&logs, nil)))
+		require.NoError(t, idx.Refresh())
+
+		assert.Empty(t, idx.IDs())
+		assert.Contains(t, logs.String(), "skipping unreadable run file")
+
+		_, err := idx.Load("2025-06-01T03:00:00Z")
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("Missing directory is empty", func(t *testing.T) {
+		t.Parallel()
+
+		idx := New(filepath.Join(t.TempDir(), "missing"), nil, logger)
+		require.NoError(t, idx.Refresh())
+		assert.Empty(t, idx.List())
+
+		_, ok := idx.Latest()
+		assert.False(t, ok)
+	})
+}
+
+func TestIndex_Load(t *testing.T) {
+	t.Parallel()
+
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+
+	dir := t.TempDir()
+	writeRun(t, dir, "2025-06-01T03:00:00Z", snapraid.RunResult{
+		Timestamp: "2025-06-01T03:00:00Z",
+		Result:    snapraid.DiffResult{Added: []string{"a"}},
+	})
+
+	idx := New(dir, nil, logger)
+	require.NoError(t, idx.Refresh())
+
+	t.Run("Loads file lists", func(t *testing.T) {
+		t.Parallel()
+
+		run, err := idx.Load("2025-06-01T03:00:00Z")
+		require.NoError(t, err)
+		assert.Equal(t, "2025-06-01T03:00:00Z", run.ID)
+		assert.Equal(t, []string{"a"}, run.Result.Added)
+	})
+
+	t.Run("Unknown run", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := idx.Load("../../etc/passwd")
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+}
+
+func TestIndex_Compressed(t *testing.T) {
+	t.Parallel()
+
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+
+	t.Run("Loads gzip and zstd run files", func(t *testing.T) {
+		t.Parallel()
+
+		dir := t.TempDir()
+		writeRun(t, dir, "2025-06-01T03:00:00Z", snapraid.RunResult{Result: snapraid.DiffResult{Added: []string{"a"}}})
+		writeCompressedRun(t, dir, "2025-06-02T03:00:00Z", ".json.gz", snapraid.RunResult{Result: snapraid.DiffResult{Added: []string{`b\ c`}}})
+		writeCompressedRun(t, dir, "2025-06-03T03:00:00Z", ".json.zst", snapraid.RunResult{Result: snapraid.DiffResult{Removed: []string{"d"}}})
+
+		idx := New(dir, nil, logger)
+		require.NoError(t, idx.Refresh())
+
+		assert.Equal(t, []string{"2025-06-01T03:00:00Z", "2025-06-02T03:00:00Z", "2025-06-03T03:00:00Z"}, idx.IDs())
+
+		latest, ok := idx.Latest()
+		require.True(t, ok)
+		assert.Equal(t, "2025-06-03T03:00:00Z", latest.ID)
+		assert.Equal(t, 1, latest.Removed)
+
+		run, err := idx.Load("2025-06-02T03:00:00Z")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"b c"}, run.Result.Added)
+		assert.Equal(t, []string{`b\ c`}, run.Raw.Added)
+	})
+
+	t.Run("Survives compression of a run file", func(t *testing.T) {
+		t.Parallel()
+
+		dir := t.TempDir()
+		run := snapraid.RunResult{Result: snapraid.DiffResult{Added: []string{"a"}}}
+		writeRun(t, dir, "2025-06-01T03:00:00Z", run)
+
+		idx := New(dir, nil, logger)
+		require.NoError(t, idx.Refresh())
+
+		// while compressing, both files exist; the original is preferred
+		writeCompressedRun(t, dir, "2025-06-01T03:00:00Z", ".json.gz", run)
+		require.NoError(t, idx.Refresh())
+		assert.Equal(t, []string{"2025-06-01T03:00:00Z"}, idx.IDs())
+
+		require.NoError(t, os.Remove(filepath.Join(dir, "2025-06-01T03:00:00Z.json")))
+		require.NoError(t, idx.Refresh())
+		assert.Equal(t, []string{"2025-06-01T03:00:00Z"}, idx.IDs())
+
+		loaded, err := idx.Load("2025-06-01T03:00:00Z")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"a"}, loaded.Result.Added)
+	})
+
+	t.Run("Skips corrupt compressed files", func(t *testing.T) {
+		t.Parallel()
+
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "2025-06-01T03:00:00Z.json.gz"), []byte("{}"), 0o600))
+
+		idx := New(dir, nil, logger)
+		require.NoError(t, idx.Refresh())
+		assert.Empty(t, idx.IDs())
+	})
+}
+
+func TestIndex_Watch(t *testing.T) {
+	t.Parallel()
+
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	dir := t.TempDir()
+
+	idx := New(dir, nil, logger)
+	require.NoError(t, idx.Refresh())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go idx.Watch(ctx, 10*time.Millisecond)
+
+	writeRun(t, dir, "2025-06-01T03:00:00Z", snapraid.RunResult{})
+
+	assert.Eventually(t, func() bool {
+		_, ok := idx.Latest()
+		return ok
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestParseID(t *testing.T) {
+	t.Parallel()
+
+	id, ts, ok := ParseID("2025-06-01T03:00:00Z.json")
+	assert.True(t, ok)
+	assert.Equal(t, "2025-06-01T03:00:00Z", id)
+	assert.Equal(t, time.Date(2025, 6, 1, 3, 0, 0, 0, time.UTC), ts)
+
+	id, _, ok = ParseID("2025-06-01T03:00:00Z.json.gz")
+	assert.True(t, ok)
+	assert.Equal(t, "2025-06-01T03:00:00Z", id)
+
+	id, _, ok = ParseID("2025-06-01T03:00:00Z.json.zst")
+	assert.True(t, ok)
+	assert.Equal(t, "2025-06-01T03:00:00Z", id)
+
+	_, _, ok = ParseID("2025-06-01T03:00:00Z.json.bz2")
+	assert.False(t, ok)
+
+	_, _, ok = ParseID("2025-06-01.json")
+	assert.False(t, ok)
+
+	_, _, ok = ParseID("2025-06-01T03:00:00Z.txt")
+	assert.False(t, ok)
+}
+
+func writeRun(t *testing.T, dir, id string, run snapraid.RunResult) {
+	t.Helper()
+	data, err := json.Marshal(run)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, id+".json"), data, 0o600))
+}
+
+// writeCompressedRun writes run as a gzip or zstd compressed run file, selected by ext.
+func writeCompressedRun(t *testing.T, dir, id, ext string, run snapraid.RunResult) {
+	t.Helper()
+	data, err := json.Marshal(run)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch ext {
+	case ".json.gz":
+		w = gzip.NewWriter(&buf)
+	case ".json.zst":
+		w, err = zstd.NewWriter(&buf)
+		require.NoError(t, err)
+	default:
+		t.Fatalf("unsupported extension %q", ext)
+	}
+	_, err = w.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	require.NoError(t, os.WriteFile(filepath.Join(dir, id+ext), buf.Bytes(), 0o600))
+}
@@ -0,0 +1,230 @@
+package runindex
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gi8lino/go-snapraid/pkg/snapraid"
+	"github.com/klauspost/compress/zstd"
+)
+
+// fileExts are the extensions of run files: as written by go-snapraid, or
+// compressed with gzip or zstd. If a run exists with several extensions,
+// the first one listed wins.
+var fileExts = []string{".json", ".json.gz", ".json.zst"}
+
+// Run is a fully decoded go-snapraid run file.
+type Run struct {
+	ID        string              `json:"-"`         // run ID, the RFC3339 file name without extension
+	Time      time.Time           `json:"-"`         // time parsed from the run ID
+	Timestamp string              `json:"timestamp"` // timestamp recorded inside the run file
+	Result    snapraid.DiffResult `json:"result"`    // file paths with SnapRAID escapes decoded
+	Raw       snapraid.DiffResult `json:"-"`         // file paths as written by go-snapraid, shell-escaped
+	Timings   snapraid.RunTimings `json:"timings"`
+	Error     json.RawMessage     `json:"error"` // kept raw, since go-snapraid serializes it as an arbitrary JSON value
+}
+
+// Category is a file change category of a run.
+type Category string
+
+const (
+	CategoryAdded    Category = "added"
+	CategoryRemoved  Category = "removed"
+	CategoryUpdated  Category = "updated"
+	CategoryMoved    Category = "moved"
+	CategoryCopied   Category = "copied"
+	CategoryRestored Category = "restored"
+)
+
+// Categories lists all file change categories in display order.
+var Categories = []Category{
+	CategoryAdded,
+	CategoryRemoved,
+	CategoryUpdated,
+	CategoryMoved,
+	CategoryCopied,
+	CategoryRestored,
+}
+
+// ParseCategory converts s into a Category, reporting false for unknown values.
+func ParseCategory(s string) (Category, bool) {
+	c := Category(s)
+	return c, slices.Contains(Categories, c)
+}
+
+// Files returns the changed file paths of the given category, with SnapRAID
+// escapes decoded.
+func (r Run) Files(c Category) []string {
+	switch c {
+	case CategoryAdded:
+		return r.Result.Added
+	case CategoryRemoved:
+		return r.Result.Removed
+	case CategoryUpdated:
+		return r.Result.Updated
+	case CategoryMoved:
+		return r.Result.Moved
+	case CategoryCopied:
+		return r.Result.Copied
+	case CategoryRestored:
+		return r.Result.Restored
+	default:
+		return nil
+	}
+}
+
+// RawFiles returns the changed file paths of the given category as written
+// by go-snapraid. The result is parallel to Files; runs without raw paths,
+// e.g. not decoded from a file, return Files.
+func (r Run) RawFiles(c Category) []string {
+	raw, files := Run{Result: r.Raw}.Files(c), r.Files(c)
+	if len(raw) != len(files) {
+		return files
+	}
+	return raw
+}
+
+// Summary holds the per-run data kept in memory by the index.
+type Summary struct {
+	ID        string              // run ID, the RFC3339 file name without extension
+	Time      time.Time           // time parsed from the run ID
+	Timestamp string              // timestamp recorded inside the run file
+	Equal     int                 // number of unchanged files
+	Added     int                 // number of added files
+	Removed   int                 // number of removed files
+	Updated   int                 // number of updated files
+	Moved     int                 // number of moved files
+	Copied    int                 // number of copied files
+	Restored  int                 // number of restored files
+	Timings   snapraid.RunTimings // per-step durations
+	Error     string              // error message, empty if the run succeeded
+	Status    Status              // outcome of the run
+	Anomalies []Anomaly           // categories exceeding their thresholds, set by the index
+}
+
+// Total returns the number of changed files across all categories.
+func (s Summary) Total() int {
+	return s.Added + s.Removed + s.Updated + s.Moved + s.Copied + s.Restored
+}
+
+// Count returns the number of files of the given category.
+func (s Summary) Count(c Category) int {
+	switch c {
+	case CategoryAdded:
+		return s.Added
+	case CategoryRemoved:
+		return s.Removed
+	case CategoryUpdated:
+		return s.Updated
+	case CategoryMoved:
+		return s.Moved
+	case CategoryCopied:
+		return s.Copied
+	case CategoryRestored:
+		return s.Restored
+	default:
+		return 0
+	}
+}
+
+// Summary returns the in-memory summary of the run.
+func (r Run) Summary() Summary {
+	s := Summary{
+		ID:        r.ID,
+		Time:      r.Time,
+		Timestamp: r.Timestamp,
+		Equal:     r.Result.Equal,
+		Added:     len(r.Result.Added),
+		Removed:   len(r.Result.Removed),
+		Updated:   len(r.Result.Updated),
+		Moved:     len(r.Result.Moved),
+		Copied:    len(r.Result.Copied),
+		Restored:  len(r.Result.Restored),
+		Timings:   r.Timings,
+		Error:     parseError(r.Error),
+	}
+	s.Status = s.status()
+	return s
+}
+
+// ParseID extracts the run ID and its time from a run file name.
+// It reports false for files that are not go-snapraid run files.
+// Compressed run files have the same ID as their uncompressed original.
+func ParseID(name string) (string, time.Time, bool) {
+	for _, ext := range fileExts {
+		id, ok := strings.CutSuffix(name, ext)
+		if !ok {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, id)
+		if err != nil {
+			return "", time.Time{}, false
+		}
+		return id, t, true
+	}
+	return "", time.Time{}, false
+}
+
+// extRank returns the position of the extension of name in fileExts.
+func extRank(name string) int {
+	return slices.IndexFunc(fileExts, func(ext string) bool {
+		return strings.HasSuffix(name, ext)
+	})
+}
+
+// decodeRun decodes a run file from r. The escapes of its file paths are
+// decoded into Result, the original paths are kept in Raw.
+func decodeRun(r io.Reader) (Run, error) {
+	var run Run
+	if err := json.NewDecoder(r).Decode(&run); err != nil {
+		return Run{}, err
+	}
+	run.Raw = run.Result
+	run.Result = normalize(run.Result)
+	return run, nil
+}
+
+// readRun opens and decodes the run file at fullPath, decompressing it
+// according to its extension.
+func readRun(fullPath string) (Run, error) {
+	f, err := os.Open(fullPath)
+	if err != nil {
+		return Run{}, err
+	}
+	defer f.Close() // nolint:errcheck
+
+	r, err := decompress(f, fullPath)
+	if err != nil {
+		return Run{}, fmt.Errorf("decompress %q failed: %w", fullPath, err)
+	}
+	defer r.Close() // nolint:errcheck
+
+	run, err := decodeRun(r)
+	if err != nil {
+		return Run{}, fmt.Errorf("JSON decode of %q failed: %w", fullPath, err)
+	}
+	return run, nil
+}
+
+// decompress wraps r in a decompressor matching the extension of name.
+// Uncompressed files are returned as is.
+func decompress(r io.Reader, name string) (io.ReadCloser, error) {
+	switch {
+	case strings.HasSuffix(name, ".gz"):
+		return gzip.NewReader(r)
+	case strings.HasSuffix(name, ".zst"):
+		d, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		return d.IOReadCloser(), nil
+	default:
+		return io.NopCloser(r), nil
+	}
+}
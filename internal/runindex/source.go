@@ -0,0 +1,48 @@
+package runindex
+
+import "time"
+
+// Source is a named run history, e.g. the output directory of one NAS.
+type Source struct {
+	Name   string
+	Index  *Index
+	Health func() Health // fetch state of a mirrored source, nil for local sources
+}
+
+// Health is the fetch state of a source mirrored from another instance.
+type Health struct {
+	URL         string    // base URL of the other instance
+	LastFetch   time.Time // start of the last fetch, zero before the first one
+	LastSuccess time.Time // start of the last successful fetch
+	Error       string    // error of the last fetch, empty if it succeeded
+}
+
+// Sources are the run histories served together. The first source is the
+// default.
+type Sources []Source
+
+// Get returns the source with the given name. An empty name selects the
+// default source.
+func (s Sources) Get(name string) (Source, bool) {
+	if len(s) == 0 {
+		return Source{}, false
+	}
+	if name == "" {
+		return s[0], true
+	}
+	for _, src := range s {
+		if src.Name == name {
+			return src, true
+		}
+	}
+	return Source{}, false
+}
+
+// Names returns the names of all sources in order.
+func (s Sources) Names() []string {
+	names := make([]string, len(s))
+	for i, src := range s {
+		names[i] = src.Name
+	}
+	return names
+}
@@ -0,0 +1,30 @@
+package runindex
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSources(t *testing.T) {
+	t.Parallel()
+
+	media, backup := &Index{dir: "/output/media"}, &Index{dir: "/output/backup"}
+	sources := Sources{{Name: "media", Index: media}, {Name: "backup", Index: backup}}
+
+	assert.Equal(t, []string{"media", "backup"}, sources.Names())
+
+	src, ok := sources.Get("")
+	assert.True(t, ok)
+	assert.Equal(t, "media", src.Name)
+
+	src, ok = sources.Get("backup")
+	assert.True(t, ok)
+	assert.Same(t, backup, src.Index)
+
+	_, ok = sources.Get("photos")
+	assert.False(t, ok)
+
+	_, ok = Sources{}.Get("")
+	assert.False(t, ok)
+}
@@ -0,0 +1,75 @@
+package runindex
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// Status classifies the outcome of a run.
+type Status string
+
+const (
+	StatusSuccess Status = "success" // run finished without error
+	StatusFailed  Status = "failed"  // run failed before any step completed
+	StatusPartial Status = "partial" // run failed after at least one step completed
+)
+
+// ParseStatus converts s into a Status, reporting false for unknown values.
+func ParseStatus(s string) (Status, bool) {
+	switch st := Status(s); st {
+	case StatusSuccess, StatusFailed, StatusPartial:
+		return st, true
+	default:
+		return "", false
+	}
+}
+
+// unknownError is reported for errors go-snapraid serialized without a message,
+// e.g. Go error values encoded as an empty object.
+const unknownError = "run failed with an unknown error"
+
+// parseError extracts a human-readable message from the raw error value of a
+// run file. It returns an empty string if the run has no error.
+func parseError(raw json.RawMessage) string {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+		return ""
+	}
+
+	var msg string
+	if err := json.Unmarshal(raw, &msg); err == nil {
+		return msg
+	}
+
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &obj); err == nil {
+		for _, key := range []string{"message", "error", "msg", "Err"} {
+			if v, ok := obj[key]; ok {
+				if msg := parseError(v); msg != "" {
+					return msg
+				}
+			}
+		}
+		if len(obj) == 0 {
+			return unknownError
+		}
+	}
+
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, raw); err != nil {
+		return string(raw)
+	}
+	return compact.String()
+}
+
+// status determines the outcome of a run from its error and step timings.
+func (s Summary) status() Status {
+	if s.Error == "" {
+		return StatusSuccess
+	}
+	t := s.Timings
+	if t.Touch > 0 || t.Diff > 0 || t.Sync > 0 || t.Scrub > 0 || t.Smart > 0 {
+		return StatusPartial
+	}
+	return StatusFailed
+}
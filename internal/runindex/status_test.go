@@ -0,0 +1,74 @@
+package runindex
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/gi8lino/go-snapraid/pkg/snapraid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseError(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		raw  string
+		want string
+	}{
+		{name: "absent", raw: "", want: ""},
+		{name: "null", raw: "null", want: ""},
+		{name: "string", raw: `"sync failed"`, want: "sync failed"},
+		{name: "object with message", raw: `{"message":"disk full","code":28}`, want: "disk full"},
+		{name: "object with error", raw: `{"error":"boom"}`, want: "boom"},
+		{name: "empty object", raw: `{}`, want: unknownError},
+		{name: "unknown object", raw: `{ "code": 28 }`, want: `{"code":28}`},
+		{name: "number", raw: `42`, want: "42"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tt.want, parseError(json.RawMessage(tt.raw)))
+		})
+	}
+}
+
+func TestRun_Status(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Success", func(t *testing.T) {
+		t.Parallel()
+		s := Run{Error: json.RawMessage("null")}.Summary()
+		assert.Equal(t, StatusSuccess, s.Status)
+		assert.Empty(t, s.Error)
+	})
+
+	t.Run("Failed", func(t *testing.T) {
+		t.Parallel()
+		s := Run{Error: json.RawMessage(`"diff failed"`)}.Summary()
+		assert.Equal(t, StatusFailed, s.Status)
+		assert.Equal(t, "diff failed", s.Error)
+	})
+
+	t.Run("Partial", func(t *testing.T) {
+		t.Parallel()
+		s := Run{
+			Timings: snapraid.RunTimings{Diff: time.Second},
+			Error:   json.RawMessage(`"sync failed"`),
+		}.Summary()
+		assert.Equal(t, StatusPartial, s.Status)
+	})
+}
+
+func TestParseStatus(t *testing.T) {
+	t.Parallel()
+
+	st, ok := ParseStatus("partial")
+	assert.True(t, ok)
+	assert.Equal(t, StatusPartial, st)
+
+	_, ok = ParseStatus("broken")
+	assert.False(t, ok)
+}
@@ -0,0 +1,153 @@
+package runindex
+
+import (
+	"slices"
+	"strings"
+	"time"
+)
+
+// moveSeparator separates the source and target path of moved and copied
+// files as reported by SnapRAID, e.g. "old/path -> new/path".
+const moveSeparator = " -> "
+
+// SplitMove splits a moved or copied file entry into its source and target
+// path. It reports false if the entry is a plain path.
+func SplitMove(entry string) (from, to string, ok bool) {
+	return strings.Cut(entry, moveSeparator)
+}
+
+// FileChange is a change of a single file recorded by a run.
+type FileChange struct {
+	RunID    string    `json:"run_id"`
+	Time     time.Time `json:"date"`
+	Category Category  `json:"category"`
+	Path     string    `json:"path"`           // path of the file after the change
+	From     string    `json:"from,omitempty"` // source path of moved and copied files
+}
+
+// fileChanges returns all file changes recorded by run.
+func fileChanges(run Run) []FileChange {
+	var changes []FileChange
+	for _, c := range Categories {
+		for _, entry := range run.Files(c) {
+			fc := FileChange{RunID: run.ID, Time: run.Time, Category: c, Path: entry}
+			if from, to, ok := SplitMove(entry); ok && (c == CategoryMoved || c == CategoryCopied) {
+				fc.From, fc.Path = from, to
+			}
+			changes = append(changes, fc)
+		}
+	}
+	return changes
+}
+
+// Entry returns the path as recorded by the run, "old -> new" for moved and
+// copied files.
+func (fc FileChange) Entry() string {
+	if fc.From != "" {
+		return fc.From + moveSeparator + fc.Path
+	}
+	return fc.Path
+}
+
+// keys returns the paths under which the change is indexed.
+func (fc FileChange) keys() []string {
+	if fc.From != "" && fc.From != fc.Path {
+		return []string{fc.Path, fc.From}
+	}
+	return []string{fc.Path}
+}
+
+// indexChanges adds the file changes of an entry to the path index.
+// The caller must hold idx.mu for writing.
+func (idx *Index) indexChanges(changes []FileChange) {
+	for _, fc := range changes {
+		for _, key := range fc.keys() {
+			idx.paths[key] = append(idx.paths[key], fc)
+		}
+	}
+}
+
+// unindexChanges removes the file changes of an entry from the path index.
+// The caller must hold idx.mu for writing.
+func (idx *Index) unindexChanges(changes []FileChange) {
+	for _, fc := range changes {
+		for _, key := range fc.keys() {
+			rest := slices.DeleteFunc(idx.paths[key], func(other FileChange) bool {
+				return other.RunID == fc.RunID
+			})
+			if len(rest) == 0 {
+				delete(idx.paths, key)
+				continue
+			}
+			idx.paths[key] = rest
+		}
+	}
+}
+
+// Search returns the file changes whose recorded entry, see
+// FileChange.Entry, matches, newest run first and in category order within
+// a run. It returns at most limit changes and reports whether more exist.
+// Only the changes kept in memory are searched, no run file is read.
+func (idx *Index) Search(match func(entry string) bool, limit int) ([]FileChange, bool) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	changes := []FileChange{}
+	for i := len(idx.ids) - 1; i >= 0; i-- {
+		for _, fc := range idx.entries[idx.ids[i]].changes {
+			if !match(fc.Entry()) {
+				continue
+			}
+			if len(changes) == limit {
+				return changes, true
+			}
+			changes = append(changes, fc)
+		}
+	}
+	return changes, false
+}
+
+// Timeline returns every recorded change of the file at path, oldest first.
+// Moves are followed in both directions, so the timeline covers the history
+// of the file under all its previous and later paths. The
+// second return value lists these paths, starting with path. A moved or
+// copied entry of the form "old -> new" is looked up by its new path.
+func (idx *Index) Timeline(path string) ([]FileChange, []string) {
+	if _, to, ok := SplitMove(path); ok {
+		path = to
+	}
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	paths := []string{path}
+	seen := map[string]struct{}{path: {}}
+	changes := []FileChange{}
+	recorded := make(map[FileChange]struct{})
+
+	for i := 0; i < len(paths); i++ {
+		for _, fc := range idx.paths[paths[i]] {
+			if _, ok := recorded[fc]; !ok {
+				recorded[fc] = struct{}{}
+				changes = append(changes, fc)
+			}
+			if fc.Category != CategoryMoved {
+				continue
+			}
+			for _, key := range fc.keys() {
+				if _, ok := seen[key]; !ok {
+					seen[key] = struct{}{}
+					paths = append(paths, key)
+				}
+			}
+		}
+	}
+
+	slices.SortStableFunc(changes, func(a, b FileChange) int {
+		if c := strings.Compare(a.RunID, b.RunID); c != 0 {
+			return c
+		}
+		return slices.Index(Categories, a.Category) - slices.Index(Categories, b.Category)
+	})
+	return changes, paths
+}
@@ -0,0 +1,184 @@
+package runindex
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gi8lino/go-snapraid/pkg/snapraid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitMove(t *testing.T) {
+	t.Parallel()
+
+	from, to, ok := SplitMove("filme/a.mkv -> archiv/a.mkv")
+	assert.True(t, ok)
+	assert.Equal(t, "filme/a.mkv", from)
+	assert.Equal(t, "archiv/a.mkv", to)
+
+	_, _, ok = SplitMove("filme/a.mkv")
+	assert.False(t, ok)
+}
+
+func TestFileChange_Entry(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "filme/a.mkv", FileChange{Path: "filme/a.mkv"}.Entry())
+	assert.Equal(t, "filme/a.mkv -> archiv/a.mkv", FileChange{Path: "archiv/a.mkv", From: "filme/a.mkv"}.Entry())
+}
+
+func TestIndex_Search(t *testing.T) {
+	t.Parallel()
+
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+
+	dir := t.TempDir()
+	writeRun(t, dir, "2025-06-01T03:00:00Z", snapraid.RunResult{
+		Result: snapraid.DiffResult{Added: []string{"filme/a.mkv", "filme/b.mkv"}},
+	})
+	writeRun(t, dir, "2025-06-02T03:00:00Z", snapraid.RunResult{
+		Result: snapraid.DiffResult{
+			Updated: []string{"serien/c.mkv"},
+			Moved:   []string{"filme/a.mkv -> archiv/a.mkv"},
+		},
+	})
+	idx := New(dir, nil, logger)
+	require.NoError(t, idx.Refresh())
+
+	// the file lists are kept in memory, no run file is read
+	require.NoError(t, os.RemoveAll(dir))
+
+	entries := func(changes []FileChange) []string {
+		var got []string
+		for _, c := range changes {
+			got = append(got, c.RunID+" "+string(c.Category)+" "+c.Entry())
+		}
+		return got
+	}
+	filme := func(entry string) bool { return strings.Contains(entry, "filme/") }
+
+	changes, truncated := idx.Search(filme, 10)
+	assert.False(t, truncated)
+	assert.Equal(t, []string{
+		"2025-06-02T03:00:00Z moved filme/a.mkv -> archiv/a.mkv",
+		"2025-06-01T03:00:00Z added filme/a.mkv",
+		"2025-06-01T03:00:00Z added filme/b.mkv",
+	}, entries(changes))
+
+	changes, truncated = idx.Search(filme, 2)
+	assert.True(t, truncated)
+	assert.Len(t, changes, 2)
+
+	changes, truncated = idx.Search(func(string) bool { return false }, 10)
+	assert.False(t, truncated)
+	assert.Empty(t, changes)
+}
+
+func TestIndex_Timeline(t *testing.T) {
+	t.Parallel()
+
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+
+	dir := t.TempDir()
+	writeRun(t, dir, "2025-06-01T03:00:00Z", snapraid.RunResult{
+		Result: snapraid.DiffResult{Added: []string{"filme/a.mkv", "filme/b.mkv"}},
+	})
+	writeRun(t, dir, "2025-06-02T03:00:00Z", snapraid.RunResult{
+		Result: snapraid.DiffResult{
+			Updated: []string{"filme/a.mkv"},
+			Copied:  []string{"filme/a.mkv -> backup/a.mkv"},
+		},
+	})
+	writeRun(t, dir, "2025-06-03T03:00:00Z", snapraid.RunResult{
+		Result: snapraid.DiffResult{Moved: []string{"filme/a.mkv -> archiv/a.mkv"}},
+	})
+	writeRun(t, dir, "2025-06-04T03:00:00Z", snapraid.RunResult{
+		Result: snapraid.DiffResult{Removed: []string{"archiv/a.mkv"}},
+	})
+
+	idx := New(dir, nil, logger)
+	require.NoError(t, idx.Refresh())
+
+	t.Run("Follows moves in both directions", func(t *testing.T) {
+		t.Parallel()
+
+		for _, path := range []string{"filme/a.mkv", "archiv/a.mkv", "filme/a.mkv -> archiv/a.mkv"} {
+			changes, paths := idx.Timeline(path)
+
+			var got []string
+			for _, c := range changes {
+				got = append(got, c.RunID+" "+string(c.Category)+" "+c.Path)
+			}
+			assert.Equal(t, []string{
+				"2025-06-01T03:00:00Z added filme/a.mkv",
+				"2025-06-02T03:00:00Z updated filme/a.mkv",
+				"2025-06-02T03:00:00Z copied backup/a.mkv",
+				"2025-06-03T03:00:00Z moved archiv/a.mkv",
+				"2025-06-04T03:00:00Z removed archiv/a.mkv",
+			}, got, path)
+			assert.ElementsMatch(t, []string{"filme/a.mkv", "archiv/a.mkv"}, paths)
+		}
+	})
+
+	t.Run("Does not follow copies", func(t *testing.T) {
+		t.Parallel()
+
+		changes, paths := idx.Timeline("backup/a.mkv")
+		require.Len(t, changes, 1)
+		assert.Equal(t, FileChange{
+			RunID:    "2025-06-02T03:00:00Z",
+			Time:     changes[0].Time,
+			Category: CategoryCopied,
+			Path:     "backup/a.mkv",
+			From:     "filme/a.mkv",
+		}, changes[0])
+		assert.Equal(t, []string{"backup/a.mkv"}, paths)
+	})
+
+	t.Run("Unknown path", func(t *testing.T) {
+		t.Parallel()
+
+		changes, paths := idx.Timeline("nope")
+		assert.Empty(t, changes)
+		assert.Equal(t, []string{"nope"}, paths)
+	})
+}
+
+func TestIndex_TimelineRefresh(t *testing.T) {
+	t.Parallel()
+
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+
+	dir := t.TempDir()
+	writeRun(t, dir, "2025-06-01T03:00:00Z", snapraid.RunResult{
+		Result: snapraid.DiffResult{Added: []string{"a"}},
+	})
+	writeRun(t, dir, "2025-06-02T03:00:00Z", snapraid.RunResult{
+		Result: snapraid.DiffResult{Removed: []string{"a"}},
+	})
+
+	idx := New(dir, nil, logger)
+	require.NoError(t, idx.Refresh())
+
+	changes, _ := idx.Timeline("a")
+	assert.Len(t, changes, 2)
+
+	require.NoError(t, os.Remove(filepath.Join(dir, "2025-06-02T03:00:00Z.json")))
+	require.NoError(t, idx.Refresh())
+
+	changes, _ = idx.Timeline("a")
+	require.Len(t, changes, 1)
+	assert.Equal(t, CategoryAdded, changes[0].Category)
+
+	require.NoError(t, os.Remove(filepath.Join(dir, "2025-06-01T03:00:00Z.json")))
+	require.NoError(t, idx.Refresh())
+
+	changes, _ = idx.Timeline("a")
+	assert.Empty(t, changes)
+	assert.Empty(t, idx.paths)
+}
@@ -0,0 +1,97 @@
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultGrace is the time a run file may take to appear after a scheduled
+// time before the run counts as missed.
+const DefaultGrace = time.Hour
+
+// jobName restricts job names to characters safe in URLs.
+var jobName = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// Config is the content of the schedule configuration file.
+type Config struct {
+	Jobs []JobConfig `yaml:"jobs"`
+}
+
+// JobConfig configures a scheduled job.
+type JobConfig struct {
+	Name     string        `yaml:"name"`     // unique name, used in URLs
+	Schedule string        `yaml:"schedule"` // cron expression with five fields or a descriptor like @daily, optionally prefixed with CRON_TZ=ZONE
+	Command  []string      `yaml:"command"`  // program and arguments, not run through a shell
+	Source   string        `yaml:"source"`   // source receiving the run files, the first source if empty
+	Timeout  time.Duration `yaml:"timeout"`  // the command is stopped after this duration, 0 disables
+	Grace    time.Duration `yaml:"grace"`    // time for the run file to appear, DefaultGrace if 0
+	Paused   bool          `yaml:"paused"`   // initial state, until toggled
+}
+
+// LoadConfig reads and validates the schedule configuration file. Unknown
+// keys are rejected, so typos do not silently disable settings.
+func LoadConfig(path string) (Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Config{}, err
+	}
+	defer f.Close() // nolint:errcheck
+
+	var cfg Config
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+		return Config{}, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return Config{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// Validate checks that the configuration contains at least one job and that
+// all jobs are valid and uniquely named.
+func (c Config) Validate() error {
+	if len(c.Jobs) == 0 {
+		return errors.New("no jobs configured")
+	}
+
+	seen := make(map[string]bool, len(c.Jobs))
+	for i, j := range c.Jobs {
+		if err := j.validate(); err != nil {
+			return fmt.Errorf("job %d: %w", i+1, err)
+		}
+		if seen[j.Name] {
+			return fmt.Errorf("job %d: duplicate name %q", i+1, j.Name)
+		}
+		seen[j.Name] = true
+	}
+	return nil
+}
+
+// validate checks a single job.
+func (j JobConfig) validate() error {
+	if !jobName.MatchString(j.Name) {
+		return fmt.Errorf("invalid name %q, only letters, digits, '_', '.' and '-' are allowed", j.Name)
+	}
+	if _, err := cron.ParseStandard(j.Schedule); err != nil {
+		return fmt.Errorf("%s: invalid schedule %q: %w", j.Name, j.Schedule, err)
+	}
+	if len(j.Command) == 0 || j.Command[0] == "" {
+		return fmt.Errorf("%s: command is required", j.Name)
+	}
+	if j.Timeout < 0 {
+		return fmt.Errorf("%s: timeout must not be negative", j.Name)
+	}
+	if j.Grace < 0 {
+		return fmt.Errorf("%s: grace must not be negative", j.Name)
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package schedule
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadConfig(t *testing.T) {
+	t.Parallel()
+
+	write := func(t *testing.T, content string) string {
+		t.Helper()
+		path := filepath.Join(t.TempDir(), "schedule.yaml")
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+		return path
+	}
+
+	t.Run("Valid config", func(t *testing.T) {
+		t.Parallel()
+
+		path := write(t, `
+jobs:
+  - name: nightly
+    schedule: "0 3 * * *"
+    command: [go-snapraid, --config, /etc/go-snapraid.yml]
+    source: media
+    timeout: 6h
+    grace: 30m
+  - name: weekly-scrub
+    schedule: "@weekly"
+    command: [go-snapraid, --scrub]
+    paused: true
+`)
+		cfg, err := LoadConfig(path)
+		require.NoError(t, err)
+		assert.Equal(t, Config{Jobs: []JobConfig{
+			{
+				Name:     "nightly",
+				Schedule: "0 3 * * *",
+				Command:  []string{"go-snapraid", "--config", "/etc/go-snapraid.yml"},
+				Source:   "media",
+				Timeout:  6 * time.Hour,
+				Grace:    30 * time.Minute,
+			},
+			{
+				Name:     "weekly-scrub",
+				Schedule: "@weekly",
+				Command:  []string{"go-snapraid", "--scrub"},
+				Paused:   true,
+			},
+		}}, cfg)
+	})
+
+	t.Run("Unknown key", func(t *testing.T) {
+		t.Parallel()
+
+		path := write(t, "jobs:\n  - name: nightly\n    cron: \"0 3 * * *\"\n")
+		_, err := LoadConfig(path)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "field cron not found")
+	})
+
+	t.Run("Empty file", func(t *testing.T) {
+		t.Parallel()
+
+		path := write(t, "")
+		_, err := LoadConfig(path)
+		assert.EqualError(t, err, path+": no jobs configured")
+	})
+
+	t.Run("Missing file", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := LoadConfig(filepath.Join(t.TempDir(), "missing.yaml"))
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
+
+func TestConfig_Validate(t *testing.T) {
+	t.Parallel()
+
+	valid := JobConfig{Name: "nightly", Schedule: "0 3 * * *", Command: []string{"go-snapraid"}}
+	with := func(modify func(j *JobConfig)) JobConfig {
+		j := valid
+		modify(&j)
+		return j
+	}
+
+	tests := []struct {
+		name string
+		jobs []JobConfig
+		err  string
+	}{
+		{"Valid", []JobConfig{valid}, ""},
+		{"No jobs", nil, "no jobs configured"},
+		{"Invalid name", []JobConfig{with(func(j *JobConfig) { j.Name = "night ly" })}, `job 1: invalid name "night ly", only letters, digits, '_', '.' and '-' are allowed`},
+		{"Duplicate name", []JobConfig{valid, valid}, `job 2: duplicate name "nightly"`},
+		{"Invalid schedule", []JobConfig{with(func(j *JobConfig) { j.Schedule = "0 3 * *" })}, `job 1: nightly: invalid schedule "0 3 * *": expected exactly 5 fields, found 4: [0 3 * *]`},
+		{"Missing command", []JobConfig{with(func(j *JobConfig) { j.Command = nil })}, "job 1: nightly: command is required"},
+		{"Negative timeout", []JobConfig{with(func(j *JobConfig) { j.Timeout = -time.Second })}, "job 1: nightly: timeout must not be negative"},
+		{"Negative grace", []JobConfig{with(func(j *JobConfig) { j.Grace = -time.Second })}, "job 1: nightly: grace must not be negative"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := Config{Jobs: tt.jobs}.Validate()
+			if tt.err == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, tt.err)
+		})
+	}
+}
@@ -0,0 +1,399 @@
+// Package schedule runs go-snapraid on cron schedules and tracks the run
+// file each execution produced.
+package schedule
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"slices"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/gi8lino/go-snapraid-web/internal/runindex"
+	"github.com/robfig/cron/v3"
+)
+
+const (
+	maxFires    = 20               // fires kept per job
+	maxOutput   = 4 << 10          // bytes of command output kept per fire
+	stopTimeout = 30 * time.Second // time a stopped command gets to exit before it is killed
+)
+
+// FireStatus is the outcome of a scheduled time.
+type FireStatus string
+
+const (
+	FireRunning FireStatus = "running" // the command is still running
+	FireSuccess FireStatus = "success" // the command exited with status 0
+	FireFailed  FireStatus = "failed"  // the command failed, timed out or was stopped
+	FireSkipped FireStatus = "skipped" // the previous command was still running
+)
+
+// Fire is a scheduled time of a job and its outcome.
+type Fire struct {
+	Scheduled time.Time // scheduled time
+	Start     time.Time // start of the command, zero if skipped
+	End       time.Time // end of the command, zero while running or if skipped
+	Status    FireStatus
+	RunID     string // run file produced by the command, empty if none appeared
+	Error     string // reason of failed and skipped fires
+	Output    string // end of the command output of failed fires
+}
+
+// JobState is the state of a scheduled job.
+type JobState struct {
+	JobConfig
+	Paused  bool
+	Running bool
+	Next    time.Time // next scheduled time, zero before the scheduler runs
+	Missed  time.Time // latest scheduled time without a run, zero if none
+	Fires   []Fire    // recent fires, newest first
+}
+
+// job is a scheduled job with its state.
+type job struct {
+	JobConfig
+	schedule cron.Schedule
+	index    *runindex.Index // index of the source receiving the run files, claimed while the command runs
+	logger   *slog.Logger
+
+	mu      sync.Mutex
+	paused  bool
+	running bool
+	next    time.Time
+	fires   []Fire // newest first
+}
+
+// Scheduler runs jobs on their schedules. It is safe for concurrent use.
+type Scheduler struct {
+	jobs   []*job
+	logger *slog.Logger
+
+	mu      sync.Mutex
+	started time.Time // start of Run, scheduled times before it were not observed
+
+	Now func() time.Time // current time, defaults to time.Now
+}
+
+// New returns a Scheduler for the jobs of cfg, which must be valid. Each
+// job writes its run files into one of the local sources. Jobs of the same
+// source run one at a time, and wait for other commands claiming the source
+// such as actions, so every run file is attributed to the command that
+// produced it.
+func New(cfg Config, sources runindex.Sources, logger *slog.Logger) (*Scheduler, error) {
+	s := &Scheduler{logger: logger, Now: time.Now}
+	for _, jc := range cfg.Jobs {
+		src, ok := sources.Get(jc.Source)
+		if !ok {
+			return nil, fmt.Errorf("job %s: source %q not found", jc.Name, jc.Source)
+		}
+		if src.Health != nil {
+			return nil, fmt.Errorf("job %s: source %q is mirrored from another instance", jc.Name, src.Name)
+		}
+		sched, err := cron.ParseStandard(jc.Schedule)
+		if err != nil {
+			return nil, fmt.Errorf("job %s: invalid schedule %q: %w", jc.Name, jc.Schedule, err)
+		}
+		jc.Source = src.Name
+		if jc.Grace == 0 {
+			jc.Grace = DefaultGrace
+		}
+		s.jobs = append(s.jobs, &job{
+			JobConfig: jc,
+			schedule:  sched,
+			index:     src.Index,
+			logger:    logger.With("job", jc.Name),
+			paused:    jc.Paused,
+		})
+	}
+	return s, nil
+}
+
+// Run runs the jobs until ctx is cancelled. Running commands are stopped
+// on cancellation; Run returns once they have exited.
+func (s *Scheduler) Run(ctx context.Context) {
+	s.mu.Lock()
+	s.started = s.Now()
+	s.mu.Unlock()
+
+	for _, j := range s.jobs {
+		if missed := s.missed(j); !missed.IsZero() {
+			j.logger.Warn("scheduled run is missing", "scheduled", missed)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for _, j := range s.jobs {
+		wg.Go(func() { s.runJob(ctx, j) })
+	}
+	wg.Wait()
+}
+
+// runJob fires the job at its scheduled times until ctx is cancelled. Fires
+// run one at a time; scheduled times passing while the command runs are
+// recorded as skipped.
+func (s *Scheduler) runJob(ctx context.Context, j *job) {
+	next := j.schedule.Next(s.Now())
+	for {
+		j.mu.Lock()
+		j.next = next
+		j.mu.Unlock()
+
+		timer := time.NewTimer(next.Sub(s.Now()))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		if j.isPaused() {
+			j.logger.Debug("skipping scheduled run of paused job", "scheduled", next)
+		} else {
+			s.fire(ctx, j, next)
+		}
+
+		now := s.Now()
+		for next = j.schedule.Next(next); !next.After(now); next = j.schedule.Next(next) {
+			j.record(Fire{Scheduled: next, Status: FireSkipped, Error: "previous run still running"})
+		}
+	}
+}
+
+// fire runs the command of the job for the scheduled time and records the
+// run file it produced. It waits for other commands claiming the source.
+func (s *Scheduler) fire(ctx context.Context, j *job, scheduled time.Time) {
+	claim, err := j.index.Claim(ctx)
+	if err != nil {
+		return
+	}
+	defer claim.Release()
+
+	f := Fire{Scheduled: scheduled, Start: s.Now(), Status: FireRunning}
+	j.start(f)
+	j.logger.Info("starting scheduled run", "scheduled", scheduled, "command", j.Command)
+
+	runCtx := ctx
+	if j.Timeout > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(ctx, j.Timeout)
+		defer cancel()
+	}
+
+	output := &tailBuffer{max: maxOutput}
+	cmd := exec.CommandContext(runCtx, j.Command[0], j.Command[1:]...)
+	cmd.Stdout = output
+	cmd.Stderr = output
+	cmd.Cancel = func() error { return cmd.Process.Signal(syscall.SIGTERM) } // let go-snapraid stop SnapRAID cleanly
+	cmd.WaitDelay = stopTimeout
+	err = cmd.Run()
+
+	f.End = s.Now()
+	f.Status = FireSuccess
+	switch {
+	case err == nil:
+	case ctx.Err() != nil:
+		f.Status, f.Error = FireFailed, "stopped by shutdown"
+	case errors.Is(runCtx.Err(), context.DeadlineExceeded):
+		f.Status, f.Error = FireFailed, fmt.Sprintf("timed out after %s", j.Timeout)
+	default:
+		f.Status, f.Error = FireFailed, err.Error()
+	}
+	if f.Status == FireFailed {
+		f.Output = output.String()
+	}
+
+	if f.RunID, err = claim.Refresh(); err != nil {
+		j.logger.Error("failed to refresh run files", "error", err)
+	}
+	j.finish(f)
+
+	if f.Status == FireFailed {
+		j.logger.Error("scheduled run failed", "scheduled", scheduled, "run", f.RunID, "error", f.Error, "output", f.Output)
+		return
+	}
+	j.logger.Info("scheduled run finished", "scheduled", scheduled, "run", f.RunID, "duration", f.End.Sub(f.Start))
+}
+
+// Jobs returns the state of all jobs in configured order.
+func (s *Scheduler) Jobs() []JobState {
+	states := make([]JobState, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		states = append(states, s.state(j))
+	}
+	return states
+}
+
+// Job returns the state of the named job.
+func (s *Scheduler) Job(name string) (JobState, bool) {
+	j, ok := s.job(name)
+	if !ok {
+		return JobState{}, false
+	}
+	return s.state(j), true
+}
+
+// SetPaused pauses or resumes the named job and returns its new state.
+// Paused jobs skip their scheduled times; a running command is not stopped.
+// The state is kept until restart, when the configured state applies again.
+func (s *Scheduler) SetPaused(name string, paused bool) (JobState, bool) {
+	j, ok := s.job(name)
+	if !ok {
+		return JobState{}, false
+	}
+
+	j.mu.Lock()
+	changed := j.paused != paused
+	j.paused = paused
+	j.mu.Unlock()
+
+	if changed {
+		j.logger.Info("changed job state", "paused", paused)
+	}
+	return s.state(j), true
+}
+
+// job returns the named job.
+func (s *Scheduler) job(name string) (*job, bool) {
+	i := slices.IndexFunc(s.jobs, func(j *job) bool { return j.Name == name })
+	if i < 0 {
+		return nil, false
+	}
+	return s.jobs[i], true
+}
+
+// state returns a snapshot of the state of j.
+func (s *Scheduler) state(j *job) JobState {
+	missed := s.missed(j)
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return JobState{
+		JobConfig: j.JobConfig,
+		Paused:    j.paused,
+		Running:   j.running,
+		Next:      j.next,
+		Missed:    missed,
+		Fires:     slices.Clone(j.fires),
+	}
+}
+
+// missed returns the latest scheduled time whose grace period has passed if
+// the source has no run for it, or zero. Scheduled times observed by Run are
+// never missed, since their outcome is recorded as a fire instead; earlier
+// ones tell whether the job ran while go-snapraid-web was not running.
+func (s *Scheduler) missed(j *job) time.Time {
+	s.mu.Lock()
+	started := s.started
+	s.mu.Unlock()
+	if j.isPaused() {
+		return time.Time{}
+	}
+
+	scheduled, ok := prev(j.schedule, s.Now().Add(-j.Grace))
+	if !ok || (!started.IsZero() && !scheduled.Before(started)) {
+		return time.Time{}
+	}
+	end := scheduled.Add(j.Grace)
+	for _, run := range j.index.List() {
+		if !run.Time.Before(scheduled) && !run.Time.After(end) {
+			return time.Time{}
+		}
+	}
+	return scheduled
+}
+
+// prevLookbacks are the ranges searched for the previous scheduled time,
+// shortest first, so frequent schedules are not iterated over a long range.
+var prevLookbacks = []time.Duration{
+	time.Hour,
+	24 * time.Hour,
+	7 * 24 * time.Hour,
+	32 * 24 * time.Hour,
+	366 * 24 * time.Hour,
+}
+
+// prev returns the latest scheduled time of sched not after t, searching up
+// to a year back.
+func prev(sched cron.Schedule, t time.Time) (time.Time, bool) {
+	for _, lookback := range prevLookbacks {
+		next := sched.Next(t.Add(-lookback))
+		if next.IsZero() || next.After(t) {
+			continue
+		}
+		var last time.Time
+		for !next.IsZero() && !next.After(t) {
+			last = next
+			next = sched.Next(next)
+		}
+		return last, true
+	}
+	return time.Time{}, false
+}
+
+// isPaused reports whether the job is paused.
+func (j *job) isPaused() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.paused
+}
+
+// start records a fire whose command is starting.
+func (j *job) start(f Fire) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.running = true
+	j.add(f)
+}
+
+// finish replaces the running fire with its outcome.
+func (j *job) finish(f Fire) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.running = false
+	if len(j.fires) > 0 && j.fires[0].Status == FireRunning {
+		j.fires[0] = f
+		return
+	}
+	j.add(f)
+}
+
+// record records a fire without a command.
+func (j *job) record(f Fire) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.add(f)
+}
+
+// add prepends f to the fires, dropping the oldest beyond maxFires.
+// j.mu must be held.
+func (j *job) add(f Fire) {
+	j.fires = slices.Insert(j.fires, 0, f)
+	if len(j.fires) > maxFires {
+		j.fires = j.fires[:maxFires]
+	}
+}
+
+// tailBuffer keeps the last max bytes written to it.
+type tailBuffer struct {
+	max int
+	buf []byte
+}
+
+// Write appends p, discarding the oldest bytes beyond max.
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.buf = append(b.buf, p...)
+	if over := len(b.buf) - b.max; over > 0 {
+		b.buf = append(b.buf[:0], b.buf[over:]...)
+	}
+	return len(p), nil
+}
+
+// String returns the kept bytes.
+func (b *tailBuffer) String() string {
+	return string(b.buf)
+}
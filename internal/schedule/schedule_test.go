@@ -0,0 +1,359 @@
+package schedule
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gi8lino/go-snapraid-web/internal/runindex"
+	"github.com/robfig/cron/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newSources returns a single source indexing dir.
+func newSources(t *testing.T, dir string, logger *slog.Logger) runindex.Sources {
+	t.Helper()
+	index := runindex.New(dir, nil, logger)
+	require.NoError(t, index.Refresh())
+	return runindex.Sources{{Name: "media", Index: index}}
+}
+
+// writeRun returns a command writing a run file with the given ID into dir.
+func writeRun(dir, id string) []string {
+	return []string{"sh", "-c", `printf '{}' > "$0/$1.json"`, dir, id}
+}
+
+// newScheduler returns a Scheduler with a single job into the source of dir.
+func newScheduler(t *testing.T, dir string, jc JobConfig, logger *slog.Logger) *Scheduler {
+	t.Helper()
+	s, err := New(Config{Jobs: []JobConfig{jc}}, newSources(t, dir, logger), logger)
+	require.NoError(t, err)
+	return s
+}
+
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	job := JobConfig{Name: "nightly", Schedule: "0 3 * * *", Command: []string{"go-snapraid"}}
+
+	t.Run("Defaults", func(t *testing.T) {
+		t.Parallel()
+
+		s := newScheduler(t, t.TempDir(), job, logger)
+		state, ok := s.Job("nightly")
+		require.True(t, ok)
+		assert.Equal(t, "media", state.Source)
+		assert.Equal(t, DefaultGrace, state.Grace)
+		assert.True(t, state.Next.IsZero())
+		assert.Empty(t, state.Fires)
+	})
+
+	t.Run("Unknown source", func(t *testing.T) {
+		t.Parallel()
+
+		j := job
+		j.Source = "backup"
+		_, err := New(Config{Jobs: []JobConfig{j}}, newSources(t, t.TempDir(), logger), logger)
+		assert.EqualError(t, err, `job nightly: source "backup" not found`)
+	})
+
+	t.Run("Remote source", func(t *testing.T) {
+		t.Parallel()
+
+		sources := newSources(t, t.TempDir(), logger)
+		sources[0].Health = func() runindex.Health { return runindex.Health{} }
+		_, err := New(Config{Jobs: []JobConfig{job}}, sources, logger)
+		assert.EqualError(t, err, `job nightly: source "media" is mirrored from another instance`)
+	})
+}
+
+func TestScheduler_Fire(t *testing.T) {
+	t.Parallel()
+
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	scheduled := time.Date(2025, 6, 1, 3, 0, 0, 0, time.UTC)
+
+	t.Run("Records the run file", func(t *testing.T) {
+		t.Parallel()
+
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "2025-05-31T03:00:00Z.json"), []byte(`{}`), 0o600))
+		s := newScheduler(t, dir, JobConfig{Name: "nightly", Schedule: "0 3 * * *", Command: writeRun(dir, "2025-06-01T03:00:00Z")}, logger)
+
+		s.fire(context.Background(), s.jobs[0], scheduled)
+
+		state, _ := s.Job("nightly")
+		assert.False(t, state.Running)
+		require.Len(t, state.Fires, 1)
+		f := state.Fires[0]
+		assert.Equal(t, scheduled, f.Scheduled)
+		assert.Equal(t, FireSuccess, f.Status)
+		assert.Equal(t, "2025-06-01T03:00:00Z", f.RunID)
+		assert.Empty(t, f.Error)
+		assert.False(t, f.End.Before(f.Start))
+	})
+
+	t.Run("Runs jobs of a source one at a time", func(t *testing.T) {
+		t.Parallel()
+
+		dir := t.TempDir()
+		slow := append([]string{"sh", "-c", `sleep 0.2; printf '{}' > "$0/$1.json"`}, dir, "2025-06-01T03:00:00Z")
+		s, err := New(Config{Jobs: []JobConfig{
+			{Name: "slow", Schedule: "0 3 * * *", Command: slow},
+			{Name: "fast", Schedule: "0 3 * * *", Command: writeRun(dir, "2025-06-01T03:00:01Z")},
+		}}, newSources(t, dir, logger), logger)
+		require.NoError(t, err)
+
+		var wg sync.WaitGroup
+		for _, j := range s.jobs {
+			wg.Go(func() { s.fire(context.Background(), j, scheduled) })
+		}
+		wg.Wait()
+
+		slowState, _ := s.Job("slow")
+		fastState, _ := s.Job("fast")
+		require.Len(t, slowState.Fires, 1)
+		require.Len(t, fastState.Fires, 1)
+		assert.Equal(t, "2025-06-01T03:00:00Z", slowState.Fires[0].RunID)
+		assert.Equal(t, "2025-06-01T03:00:01Z", fastState.Fires[0].RunID)
+	})
+
+	t.Run("Waits for other commands of the source", func(t *testing.T) {
+		t.Parallel()
+
+		dir := t.TempDir()
+		s := newScheduler(t, dir, JobConfig{Name: "nightly", Schedule: "0 3 * * *", Command: writeRun(dir, "2025-06-01T03:00:00Z")}, logger)
+
+		// e.g. an action started from the dashboard
+		claim, ok := s.jobs[0].index.TryClaim()
+		require.True(t, ok)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			s.fire(context.Background(), s.jobs[0], scheduled)
+		}()
+
+		time.Sleep(50 * time.Millisecond)
+		state, _ := s.Job("nightly")
+		assert.Empty(t, state.Fires)
+
+		claim.Release()
+		<-done
+		state, _ = s.Job("nightly")
+		require.Len(t, state.Fires, 1)
+		assert.Equal(t, "2025-06-01T03:00:00Z", state.Fires[0].RunID)
+	})
+
+	t.Run("Records failures", func(t *testing.T) {
+		t.Parallel()
+
+		s := newScheduler(t, t.TempDir(), JobConfig{Name: "nightly", Schedule: "0 3 * * *", Command: []string{"sh", "-c", "echo boom >&2; exit 3"}}, logger)
+
+		s.fire(context.Background(), s.jobs[0], scheduled)
+
+		state, _ := s.Job("nightly")
+		require.Len(t, state.Fires, 1)
+		f := state.Fires[0]
+		assert.Equal(t, FireFailed, f.Status)
+		assert.Equal(t, "exit status 3", f.Error)
+		assert.Equal(t, "boom\n", f.Output)
+		assert.Empty(t, f.RunID)
+	})
+
+	t.Run("Stops commands after the timeout", func(t *testing.T) {
+		t.Parallel()
+
+		s := newScheduler(t, t.TempDir(), JobConfig{Name: "nightly", Schedule: "0 3 * * *", Command: []string{"sleep", "10"}, Timeout: 50 * time.Millisecond}, logger)
+
+		s.fire(context.Background(), s.jobs[0], scheduled)
+
+		state, _ := s.Job("nightly")
+		require.Len(t, state.Fires, 1)
+		assert.Equal(t, FireFailed, state.Fires[0].Status)
+		assert.Equal(t, "timed out after 50ms", state.Fires[0].Error)
+	})
+
+	t.Run("Stops commands on shutdown", func(t *testing.T) {
+		t.Parallel()
+
+		s := newScheduler(t, t.TempDir(), JobConfig{Name: "nightly", Schedule: "0 3 * * *", Command: []string{"sleep", "10"}}, logger)
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		s.fire(ctx, s.jobs[0], scheduled)
+
+		state, _ := s.Job("nightly")
+		require.Len(t, state.Fires, 1)
+		assert.Equal(t, FireFailed, state.Fires[0].Status)
+		assert.Equal(t, "stopped by shutdown", state.Fires[0].Error)
+	})
+}
+
+func TestScheduler_Run(t *testing.T) {
+	t.Parallel()
+
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	dir := t.TempDir()
+	s, err := New(Config{Jobs: []JobConfig{
+		{Name: "every-second", Schedule: "@every 1s", Command: writeRun(dir, "2025-06-01T03:00:00Z")},
+		{Name: "paused", Schedule: "@every 1s", Command: writeRun(dir, "2025-06-02T03:00:00Z"), Paused: true},
+	}}, newSources(t, dir, logger), logger)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.Run(ctx)
+	}()
+
+	require.Eventually(t, func() bool {
+		state, _ := s.Job("every-second")
+		return len(state.Fires) > 0 && state.Fires[len(state.Fires)-1].Status == FireSuccess
+	}, 5*time.Second, 10*time.Millisecond)
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after cancellation")
+	}
+
+	state, _ := s.Job("every-second")
+	assert.Equal(t, "2025-06-01T03:00:00Z", state.Fires[len(state.Fires)-1].RunID)
+	assert.False(t, state.Next.IsZero())
+
+	paused, _ := s.Job("paused")
+	assert.True(t, paused.Paused)
+	assert.Empty(t, paused.Fires)
+	assert.NoFileExists(t, filepath.Join(dir, "2025-06-02T03:00:00Z.json"))
+}
+
+func TestScheduler_Missed(t *testing.T) {
+	t.Parallel()
+
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	job := JobConfig{Name: "nightly", Schedule: "CRON_TZ=UTC 0 3 * * *", Command: []string{"go-snapraid"}}
+	now := time.Date(2025, 6, 2, 5, 0, 0, 0, time.UTC)
+
+	newMissed := func(t *testing.T, ids ...string) *Scheduler {
+		t.Helper()
+		dir := t.TempDir()
+		for _, id := range ids {
+			require.NoError(t, os.WriteFile(filepath.Join(dir, id+".json"), []byte(`{}`), 0o600))
+		}
+		s := newScheduler(t, dir, job, logger)
+		s.Now = func() time.Time { return now }
+		return s
+	}
+
+	t.Run("Reports the scheduled time without a run", func(t *testing.T) {
+		t.Parallel()
+
+		s := newMissed(t, "2025-06-01T03:05:00Z")
+		state, _ := s.Job("nightly")
+		assert.Equal(t, time.Date(2025, 6, 2, 3, 0, 0, 0, time.UTC), state.Missed.UTC())
+	})
+
+	t.Run("Run within grace period", func(t *testing.T) {
+		t.Parallel()
+
+		s := newMissed(t, "2025-06-02T03:45:00Z")
+		state, _ := s.Job("nightly")
+		assert.True(t, state.Missed.IsZero())
+	})
+
+	t.Run("Grace period of the latest time not over", func(t *testing.T) {
+		t.Parallel()
+
+		s := newMissed(t)
+		s.Now = func() time.Time { return time.Date(2025, 6, 2, 3, 30, 0, 0, time.UTC) }
+		state, _ := s.Job("nightly")
+		assert.Equal(t, time.Date(2025, 6, 1, 3, 0, 0, 0, time.UTC), state.Missed.UTC())
+	})
+
+	t.Run("Observed by the scheduler", func(t *testing.T) {
+		t.Parallel()
+
+		s := newMissed(t)
+		s.started = time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+		state, _ := s.Job("nightly")
+		assert.True(t, state.Missed.IsZero())
+	})
+
+	t.Run("Paused", func(t *testing.T) {
+		t.Parallel()
+
+		s := newMissed(t)
+		state, ok := s.SetPaused("nightly", true)
+		require.True(t, ok)
+		assert.True(t, state.Paused)
+		assert.True(t, state.Missed.IsZero())
+	})
+}
+
+func TestScheduler_SetPaused(t *testing.T) {
+	t.Parallel()
+
+	logger := slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	s := newScheduler(t, t.TempDir(), JobConfig{Name: "nightly", Schedule: "0 3 * * *", Command: []string{"go-snapraid"}, Paused: true}, logger)
+
+	state, ok := s.SetPaused("nightly", false)
+	require.True(t, ok)
+	assert.False(t, state.Paused)
+
+	_, ok = s.SetPaused("weekly", true)
+	assert.False(t, ok)
+}
+
+func TestPrev(t *testing.T) {
+	t.Parallel()
+
+	at := func(s string) time.Time {
+		ts, err := time.Parse(time.RFC3339, s)
+		require.NoError(t, err)
+		return ts
+	}
+
+	tests := []struct {
+		name     string
+		schedule string
+		t        time.Time
+		want     time.Time
+	}{
+		{"Every minute", "* * * * *", at("2025-06-02T05:00:30Z"), at("2025-06-02T05:00:00Z")},
+		{"Exactly at a scheduled time", "0 3 * * *", at("2025-06-02T03:00:00Z"), at("2025-06-02T03:00:00Z")},
+		{"Daily", "0 3 * * *", at("2025-06-02T02:59:00Z"), at("2025-06-01T03:00:00Z")},
+		{"Weekly", "0 3 * * 0", at("2025-06-02T05:00:00Z"), at("2025-06-01T03:00:00Z")},
+		{"Monthly", "0 3 15 * *", at("2025-06-02T05:00:00Z"), at("2025-05-15T03:00:00Z")},
+		{"Yearly", "@yearly", at("2025-06-02T05:00:00Z"), at("2025-01-01T00:00:00Z")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			sched, err := cron.ParseStandard("CRON_TZ=UTC " + tt.schedule)
+			require.NoError(t, err)
+			got, ok := prev(sched, tt.t)
+			require.True(t, ok)
+			assert.Equal(t, tt.want, got.UTC())
+		})
+	}
+}
+
+func TestTailBuffer(t *testing.T) {
+	t.Parallel()
+
+	b := &tailBuffer{max: 5}
+	n, err := b.Write([]byte("abc"))
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+	_, _ = b.Write([]byte("defg"))
+	assert.Equal(t, "cdefg", b.String())
+}
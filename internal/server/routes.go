@@ -5,27 +5,116 @@ import (
 	"log/slog"
 	"net/http"
 
+	"github.com/gi8lino/go-snapraid-web/internal/action"
+	"github.com/gi8lino/go-snapraid-web/internal/auth"
 	"github.com/gi8lino/go-snapraid-web/internal/handlers"
+	"github.com/gi8lino/go-snapraid-web/internal/runindex"
+	"github.com/gi8lino/go-snapraid-web/internal/schedule"
 )
 
-// NewRouter creates a new HTTP router
+// NewRouter creates a new HTTP router. Handlers of a single source serve the
+// source selected by the "source" query parameter, the first one by default.
+// The schedule routes are only served if scheduler is not nil, the action
+// routes only if actions is not nil. With any authenticators, all routes but
+// /healthz and the login pages of authenticators below /auth/ require
+// authentication, and policy assigns the roles checked by the routes.
 func NewRouter(
 	webFS fs.FS,
-	outputDir string,
+	sources runindex.Sources,
+	scheduler *schedule.Scheduler,
+	actions *action.Runner,
+	authenticators []auth.Authenticator,
+	policy auth.Policy,
 	version string,
 	logger *slog.Logger,
 ) http.Handler {
 	mux := http.NewServeMux()
 
+	// perSource creates a handler for each source
+	perSource := func(newHandler func(index *runindex.Index) http.Handler) http.Handler {
+		return handlers.PerSource(sources, newHandler)
+	}
+
 	// Handler for embedded static files
 	staticContent, _ := fs.Sub(webFS, "web/static")
 	fileServer := http.FileServer(http.FS(staticContent))
 	mux.Handle("GET /static/", http.StripPrefix("/static/", fileServer))
 
-	mux.Handle("/", handlers.HomeHandler(webFS, version)) // no Method allowed, otherwise it crashes
-	mux.Handle("GET /partials/", http.StripPrefix("/partials", handlers.PartialHandler(webFS, outputDir, logger)))
+	mux.Handle("/", handlers.HomeHandler(webFS, version, sources.Names(), scheduler != nil, actions != nil)) // no Method allowed, otherwise it crashes
+	partials := perSource(func(index *runindex.Index) http.Handler {
+		return handlers.PartialHandler(webFS, index, logger)
+	})
+	mux.Handle("GET /partials/", http.StripPrefix("/partials", partials))
+	mux.Handle("GET /partials/sources", handlers.SourcesPartial(webFS, sources, logger))
+
+	mux.Handle("GET /api/v1/sources", handlers.SourcesAPI(sources))
+	mux.Handle("GET /api/v1/runs", perSource(func(index *runindex.Index) http.Handler {
+		return handlers.RunsAPI(index)
+	}))
+	mux.Handle("GET /api/v1/runs/{id}", perSource(func(index *runindex.Index) http.Handler {
+		return handlers.RunAPI(index, logger)
+	}))
+	mux.Handle("GET /api/v1/runs/{id}/files", auth.RequirePaths(perSource(func(index *runindex.Index) http.Handler {
+		return handlers.RunFilesAPI(index, logger)
+	})))
+	mux.Handle("GET /api/v1/trends", perSource(func(index *runindex.Index) http.Handler {
+		return handlers.Trends(index)
+	}))
+	mux.Handle("GET /api/v1/search", auth.RequirePaths(perSource(func(index *runindex.Index) http.Handler {
+		return handlers.SearchAPI(index)
+	})))
+	mux.Handle("GET /api/v1/compare", auth.RequirePaths(perSource(func(index *runindex.Index) http.Handler {
+		return handlers.CompareAPI(index, logger)
+	})))
+	mux.Handle("GET /api/v1/files", auth.RequirePaths(perSource(func(index *runindex.Index) http.Handler {
+		return handlers.TimelineAPI(index)
+	})))
+	mux.Handle("GET /api/v1/export/runs", perSource(func(index *runindex.Index) http.Handler {
+		return handlers.ExportRunsAPI(index, logger)
+	}))
+	mux.Handle("GET /api/v1/export/runs/{id}/files", auth.RequirePaths(perSource(func(index *runindex.Index) http.Handler {
+		return handlers.ExportRunFilesAPI(index, logger)
+	})))
+
+	// Browsers send basic auth credentials and cookies along with requests
+	// of other sites, so state-changing routes reject cross-origin requests
+	csrf := http.NewCrossOriginProtection()
+
+	if scheduler != nil {
+		mux.Handle("GET /partials/schedule", handlers.SchedulePartial(webFS, scheduler, logger))
+		mux.Handle("GET /api/v1/schedule", handlers.ScheduleAPI(scheduler))
+		mux.Handle("GET /api/v1/schedule/{job}", handlers.JobAPI(scheduler))
+		mux.Handle("POST /api/v1/schedule/{job}/pause", csrf.Handler(auth.RequireRole(auth.RoleOperator, handlers.PauseJobAPI(scheduler, true))))
+		mux.Handle("POST /api/v1/schedule/{job}/resume", csrf.Handler(auth.RequireRole(auth.RoleOperator, handlers.PauseJobAPI(scheduler, false))))
+	}
+
+	if actions != nil {
+		mux.Handle("GET /partials/actions", handlers.ActionsPartial(webFS, actions, logger))
+		mux.Handle("GET /api/v1/actions", handlers.ActionsAPI(actions))
+		mux.Handle("GET /api/v1/actions/{action}", handlers.ActionAPI(actions))
+		mux.Handle("GET /api/v1/actions/{action}/output", auth.RequirePaths(handlers.ActionOutput(actions, logger)))
+		mux.Handle("POST /api/v1/actions/{action}/run", csrf.Handler(auth.RequireRole(auth.RoleOperator, handlers.StartActionAPI(actions))))
+	}
+
+	mux.Handle("GET /events", perSource(func(index *runindex.Index) http.Handler {
+		return handlers.Events(index, logger)
+	}))
 
 	mux.Handle("GET /healthz", handlers.Healthz())
+	mux.Handle("GET /metrics", handlers.Metrics(sources))
+
+	if len(authenticators) == 0 {
+		return mux
+	}
 
-	return mux
+	// Liveness probes cannot authenticate and users log in before they are
+	root := http.NewServeMux()
+	root.Handle("GET /healthz", handlers.Healthz())
+	for _, a := range authenticators {
+		if h, ok := a.(http.Handler); ok {
+			root.Handle("/auth/", h)
+		}
+	}
+	root.Handle("/", auth.Require(authenticators, policy.Apply(mux)))
+	return root
 }
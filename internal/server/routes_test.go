@@ -8,24 +8,51 @@ import (
 	"testing"
 	"testing/fstest"
 
+	"github.com/gi8lino/go-snapraid-web/internal/action"
+	"github.com/gi8lino/go-snapraid-web/internal/auth"
+	"github.com/gi8lino/go-snapraid-web/internal/runindex"
+	"github.com/gi8lino/go-snapraid-web/internal/schedule"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
-func TestNewRouter(t *testing.T) {
-	t.Parallel()
-
-	// in-memory file system with minimal template files
-	webFS := fstest.MapFS{
+// testWebFS returns an in-memory file system with minimal template files.
+func testWebFS() fstest.MapFS {
+	return fstest.MapFS{
 		"web/static/css/go-snapraid.css": &fstest.MapFile{Data: []byte("body { background: white; }")},
 		"web/templates/base.html":        &fstest.MapFile{Data: []byte(`{{define "base"}}<html>{{template "navbar"}}<footer>{{.Version}}</footer>{{end}}`)},
 		"web/templates/navbar.html":      &fstest.MapFile{Data: []byte(`{{define "navbar"}}<nav>nav</nav>{{end}}`)},
 		"web/templates/overview.html":    &fstest.MapFile{Data: []byte(` {{ define "overview" }}<div id="overview">Overview page</div>{{ end }}`)},
 		"web/templates/run.html":         &fstest.MapFile{Data: []byte(` {{ define "run" }}<div id="run">Run page</div>{{ end }}`)},
+		"web/templates/trends.html":      &fstest.MapFile{Data: []byte(` {{ define "trends" }}<div id="trends">Trends page</div>{{ end }}`)},
+		"web/templates/search.html":      &fstest.MapFile{Data: []byte(` {{ define "search" }}<div id="search">Search page</div>{{ end }}`)},
+		"web/templates/compare.html":     &fstest.MapFile{Data: []byte(` {{ define "compare" }}<div id="compare">Compare page</div>{{ end }}`)},
+		"web/templates/file.html":        &fstest.MapFile{Data: []byte(` {{ define "file" }}<div id="file">File page</div>{{ end }}`)},
+		"web/templates/sources.html":     &fstest.MapFile{Data: []byte(` {{ define "sources" }}<div id="sources">{{ range .Sources }}{{ .Name }} {{ end }}</div>{{ end }}`)},
+		"web/templates/schedule.html":    &fstest.MapFile{Data: []byte(` {{ define "schedule" }}<div id="schedule">{{ range .Jobs }}{{ .Name }} {{ end }}</div>{{ end }}`)},
+		"web/templates/actions.html":     &fstest.MapFile{Data: []byte(` {{ define "actions" }}<div id="actions">{{ range .Actions }}{{ .Name }} {{ end }}</div>{{ end }}`)},
 		"web/templates/footer.html":      &fstest.MapFile{Data: []byte(`{{define "footer"}}<!-- footer -->{{end}}`)},
 	}
+}
+
+func TestNewRouter(t *testing.T) {
+	t.Parallel()
 
+	webFS := testWebFS()
 	logger := slog.New(slog.NewTextHandler(&strings.Builder{}, nil))
-	router := NewRouter(webFS, "/does-not-matter", "test-version", logger)
+	sources := runindex.Sources{
+		{Name: "media", Index: runindex.New("/does-not-matter", nil, logger)},
+		{Name: "backup", Index: runindex.New("/does-not-matter", nil, logger)},
+	}
+	scheduler, err := schedule.New(schedule.Config{Jobs: []schedule.JobConfig{
+		{Name: "nightly", Schedule: "0 3 * * *", Command: []string{"go-snapraid"}},
+	}}, sources, logger)
+	require.NoError(t, err)
+	actions, err := action.New(action.Config{Actions: []action.ActionConfig{
+		{Name: "sync", Command: []string{"go-snapraid"}},
+	}}, sources, logger)
+	require.NoError(t, err)
+	router := NewRouter(webFS, sources, scheduler, actions, nil, auth.Policy{}, "test-version", logger)
 
 	t.Run("GET /static/css/go-snapraid.css", func(t *testing.T) {
 		t.Parallel()
@@ -47,6 +74,36 @@ func TestNewRouter(t *testing.T) {
 		assert.Equal(t, "ok", rec.Body.String())
 	})
 
+	t.Run("GET /api/v1/trends", func(t *testing.T) {
+		t.Parallel()
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/trends?bucket=week", nil)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"bucket":"week","points":[]}`, rec.Body.String())
+	})
+
+	t.Run("GET /api/v1/search", func(t *testing.T) {
+		t.Parallel()
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/search?q=movie", nil)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"query":"movie","mode":"substring","matches":[],"truncated":false}`, rec.Body.String())
+	})
+
+	t.Run("GET /metrics", func(t *testing.T) {
+		t.Parallel()
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), "snapraid_runs")
+	})
+
 	t.Run("GET / (Home)", func(t *testing.T) {
 		t.Parallel()
 		req := httptest.NewRequest(http.MethodGet, "/", nil)
@@ -74,4 +131,277 @@ func TestNewRouter(t *testing.T) {
 
 		assert.NotEqual(t, http.StatusNotFound, rec.Code)
 	})
+
+	t.Run("GET /partials/sources", func(t *testing.T) {
+		t.Parallel()
+		req := httptest.NewRequest(http.MethodGet, "/partials/sources", nil)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, `<div id="sources">media backup </div>`, strings.TrimSpace(rec.Body.String()))
+	})
+
+	t.Run("GET /api/v1/sources", func(t *testing.T) {
+		t.Parallel()
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/sources", nil)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"sources":[{"name":"media","runs":0,"latest":null},{"name":"backup","runs":0,"latest":null}]}`, rec.Body.String())
+	})
+
+	t.Run("GET /api/v1/runs?source=backup", func(t *testing.T) {
+		t.Parallel()
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/runs?source=backup", nil)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("GET /api/v1/runs?source=unknown", func(t *testing.T) {
+		t.Parallel()
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/runs?source=unknown", nil)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.JSONEq(t, `{"error":"source \"unknown\" not found"}`, rec.Body.String())
+	})
+
+	t.Run("GET /api/v1/runs", func(t *testing.T) {
+		t.Parallel()
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/runs", nil)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	})
+
+	t.Run("GET /api/v1/runs/{id}", func(t *testing.T) {
+		t.Parallel()
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/runs/2025-06-01T03:00:00Z", nil)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	})
+
+	t.Run("GET /partials/schedule", func(t *testing.T) {
+		t.Parallel()
+		req := httptest.NewRequest(http.MethodGet, "/partials/schedule", nil)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, `<div id="schedule">nightly </div>`, strings.TrimSpace(rec.Body.String()))
+	})
+
+	t.Run("GET /api/v1/schedule", func(t *testing.T) {
+		t.Parallel()
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/schedule", nil)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"name":"nightly"`)
+	})
+
+	t.Run("POST /api/v1/schedule/{job}/pause", func(t *testing.T) {
+		t.Parallel()
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/schedule/nightly/pause", nil)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"paused":true`)
+	})
+
+	t.Run("POST /api/v1/schedule/{job}/pause from other site", func(t *testing.T) {
+		t.Parallel()
+		for _, header := range []http.Header{
+			{"Sec-Fetch-Site": {"cross-site"}},
+			{"Origin": {"https://evil.example"}},
+		} {
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/schedule/nightly/resume", nil)
+			req.Header = header
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusForbidden, rec.Code, header)
+		}
+
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/schedule/nightly/resume", nil)
+		req.Header.Set("Sec-Fetch-Site", "same-origin")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("GET /partials/actions", func(t *testing.T) {
+		t.Parallel()
+		req := httptest.NewRequest(http.MethodGet, "/partials/actions", nil)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, `<div id="actions">sync </div>`, strings.TrimSpace(rec.Body.String()))
+	})
+
+	t.Run("GET /api/v1/actions", func(t *testing.T) {
+		t.Parallel()
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/actions", nil)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"name":"sync"`)
+	})
+
+	t.Run("POST /api/v1/actions/{action}/run", func(t *testing.T) {
+		t.Parallel()
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/actions/scrub/run", nil)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	t.Run("POST /api/v1/actions/{action}/run from other site", func(t *testing.T) {
+		t.Parallel()
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/actions/sync/run", nil)
+		req.Header.Set("Sec-Fetch-Site", "cross-site")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+}
+
+func TestNewRouter_Auth(t *testing.T) {
+	t.Parallel()
+
+	webFS := testWebFS()
+	logger := slog.New(slog.NewTextHandler(&strings.Builder{}, nil))
+	sources := runindex.Sources{{Name: "media", Index: runindex.New("/does-not-matter", nil, logger)}}
+	token, err := auth.NewToken("secret")
+	require.NoError(t, err)
+	router := NewRouter(webFS, sources, nil, nil, []auth.Authenticator{token, loginPages{}}, auth.Policy{Default: auth.RoleAdmin}, "test-version", logger)
+
+	t.Run("Health check is public", func(t *testing.T) {
+		t.Parallel()
+		req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("Requires authentication", func(t *testing.T) {
+		t.Parallel()
+		for _, path := range []string{"/", "/static/css/go-snapraid.css", "/api/v1/runs", "/metrics"} {
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusUnauthorized, rec.Code, path)
+			assert.Equal(t, `Bearer realm="go-snapraid-web"`, rec.Header().Get("WWW-Authenticate"), path)
+		}
+	})
+
+	t.Run("Authenticated", func(t *testing.T) {
+		t.Parallel()
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/runs", nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+	t.Run("Login pages are public", func(t *testing.T) {
+		t.Parallel()
+		req := httptest.NewRequest(http.MethodGet, "/auth/login", nil)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "login page", rec.Body.String())
+	})
+}
+
+// loginPages is an authenticator serving its own login pages.
+type loginPages struct{}
+
+func (loginPages) Authenticate(*http.Request) (auth.Identity, bool) { return auth.Identity{}, false }
+func (loginPages) Challenge() string                                { return "" }
+func (loginPages) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	_, _ = w.Write([]byte("login page"))
+}
+
+func TestNewRouter_Roles(t *testing.T) {
+	t.Parallel()
+
+	webFS := testWebFS()
+	logger := slog.New(slog.NewTextHandler(&strings.Builder{}, nil))
+	sources := runindex.Sources{{Name: "media", Index: runindex.New("/does-not-matter", nil, logger)}}
+	scheduler, err := schedule.New(schedule.Config{Jobs: []schedule.JobConfig{
+		{Name: "nightly", Schedule: "0 3 * * *", Command: []string{"go-snapraid"}},
+	}}, sources, logger)
+	require.NoError(t, err)
+	actions, err := action.New(action.Config{Actions: []action.ActionConfig{
+		{Name: "sync", Command: []string{"go-snapraid"}},
+	}}, sources, logger)
+	require.NoError(t, err)
+	token, err := auth.NewToken("secret")
+	require.NoError(t, err)
+	policy := auth.Policy{Default: auth.RoleViewer, HidePaths: true}
+	router := NewRouter(webFS, sources, scheduler, actions, []auth.Authenticator{token}, policy, "test-version", logger)
+
+	t.Run("Viewer reads runs", func(t *testing.T) {
+		t.Parallel()
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/runs", nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("Viewer cannot pause jobs", func(t *testing.T) {
+		t.Parallel()
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/schedule/nightly/pause", nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		assert.Equal(t, "forbidden, requires role operator\n", rec.Body.String())
+	})
+
+	t.Run("Viewer cannot run actions", func(t *testing.T) {
+		t.Parallel()
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/actions/sync/run", nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		assert.Equal(t, "forbidden, requires role operator\n", rec.Body.String())
+	})
+
+	t.Run("Viewer cannot see file paths", func(t *testing.T) {
+		t.Parallel()
+		for _, path := range []string{"/api/v1/search?q=a", "/api/v1/runs/latest/files", "/api/v1/export/runs/latest/files", "/partials/search?q=a", "/api/v1/actions/sync/output"} {
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			req.Header.Set("Authorization", "Bearer secret")
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusForbidden, rec.Code, path)
+		}
+	})
 }
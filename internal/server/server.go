@@ -8,8 +8,10 @@ import (
 	"time"
 )
 
-// Run sets up and manages the reverse proxy HTTP server.
-func Run(ctx context.Context, listenAddr string, router http.Handler, logger *slog.Logger) error {
+// Run sets up and manages the reverse proxy HTTP server. With a certificate
+// in tlsCfg, HTTPS is served instead and, if configured, plain HTTP requests
+// are redirected to it.
+func Run(ctx context.Context, listenAddr string, router http.Handler, tlsCfg TLSConfig, logger *slog.Logger) error {
 	// Create server
 	server := &http.Server{
 		Addr:              listenAddr,
@@ -18,15 +20,50 @@ func Run(ctx context.Context, listenAddr string, router http.Handler, logger *sl
 		WriteTimeout:      15 * time.Second,
 		IdleTimeout:       60 * time.Second,
 	}
+	servers := []*http.Server{server}
+
+	if tlsCfg.CertFile != "" {
+		certs, err := loadCertificates(tlsCfg, logger)
+		if err != nil {
+			return err
+		}
+		server.TLSConfig = certs.config()
+		go certs.watch(ctx, tlsCfg.ReloadInterval)
+	}
 
 	// Start server in a goroutine
 	go func() {
-		logger.Info("starting server", "listenAddr", server.Addr)
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		logger.Info("starting server", "listenAddr", server.Addr, "tls", server.TLSConfig != nil)
+		var err error
+		if server.TLSConfig != nil {
+			err = server.ListenAndServeTLS("", "")
+		} else {
+			err = server.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			logger.Error("server error", "err", err)
 		}
 	}()
 
+	// Redirect plain HTTP to HTTPS
+	if tlsCfg.CertFile != "" && tlsCfg.RedirectAddr != "" {
+		redirect := &http.Server{
+			Addr:              tlsCfg.RedirectAddr,
+			Handler:           redirectHandler(listenAddr),
+			ReadHeaderTimeout: 10 * time.Second,
+			WriteTimeout:      15 * time.Second,
+			IdleTimeout:       60 * time.Second,
+		}
+		servers = append(servers, redirect)
+
+		go func() {
+			logger.Info("starting HTTPS redirect", "listenAddr", redirect.Addr)
+			if err := redirect.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				logger.Error("redirect server error", "err", err)
+			}
+		}()
+	}
+
 	// Graceful shutdown on context cancel
 	var wg sync.WaitGroup
 	wg.Add(1)
@@ -38,8 +75,10 @@ func Run(ctx context.Context, listenAddr string, router http.Handler, logger *sl
 
 		shutdownCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
 		defer cancel()
-		if err := server.Shutdown(shutdownCtx); err != nil {
-			logger.Error("shutdown error", "err", err)
+		for _, s := range servers {
+			if err := s.Shutdown(shutdownCtx); err != nil {
+				logger.Error("shutdown error", "err", err)
+			}
 		}
 	}()
 
@@ -26,7 +26,7 @@ func TestRun(t *testing.T) {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			err := Run(ctx, ":0", mux, logger) // :0 = random port
+			err := Run(ctx, ":0", mux, TLSConfig{}, logger) // :0 = random port
 			assert.NoError(t, err, "Run function should not return an error")
 		}()
 
@@ -0,0 +1,188 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gi8lino/go-snapraid-web/internal/handlers"
+)
+
+// TLSConfig configures serving HTTPS. The zero value serves plain HTTP.
+type TLSConfig struct {
+	CertFile       string        // certificate chain in PEM format, empty serves plain HTTP
+	KeyFile        string        // private key of the certificate in PEM format
+	ClientCAFile   string        // CA bundle client certificates must be signed by, empty disables mTLS
+	RedirectAddr   string        // address redirecting HTTP to HTTPS, empty disables
+	ReloadInterval time.Duration // interval for checking the files for changes
+}
+
+// certificates holds the TLS configuration loaded from the files of a
+// TLSConfig and reloads it when they change, so renewed certificates are
+// used without a restart.
+type certificates struct {
+	cfg    TLSConfig
+	logger *slog.Logger
+
+	mu      sync.RWMutex
+	current *tls.Config
+	stamp   string // sizes and modification times of the files current was loaded from
+}
+
+// loadCertificates loads the files of cfg.
+func loadCertificates(cfg TLSConfig, logger *slog.Logger) (*certificates, error) {
+	c := &certificates{cfg: cfg, logger: logger}
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// config returns the server configuration, handing out the most recently
+// loaded certificate and client CAs on every handshake.
+func (c *certificates) config() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			c.mu.RLock()
+			defer c.mu.RUnlock()
+			return c.current, nil
+		},
+	}
+}
+
+// watch reloads the files every interval if they changed until ctx is
+// cancelled. Polling is used instead of inotify so the symlink swaps of
+// Kubernetes secret volumes are picked up as well. If loading fails, the
+// previous certificate stays in use.
+func (c *certificates) watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			stamp, err := c.stat()
+			if err != nil {
+				c.logger.Error("check TLS certificate", "error", err)
+				continue
+			}
+			c.mu.RLock()
+			changed := stamp != c.stamp
+			c.mu.RUnlock()
+			if !changed {
+				continue
+			}
+			if err := c.load(); err != nil {
+				c.logger.Error("reload TLS certificate", "error", err)
+				continue
+			}
+			c.logger.Info("reloaded TLS certificate", "certFile", c.cfg.CertFile, "notAfter", c.notAfter())
+		}
+	}
+}
+
+// load reads the files and replaces the current configuration. The stamp is
+// updated even on failure, so a broken file is reported once per change.
+func (c *certificates) load() error {
+	stamp, err := c.stat()
+	if err != nil {
+		return err
+	}
+	config, err := c.read()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stamp = stamp
+	if err != nil {
+		return err
+	}
+	c.current = config
+	return nil
+}
+
+// read parses the certificate, key and client CA bundle.
+func (c *certificates) read() (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(c.cfg.CertFile, c.cfg.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("load certificate: %w", err)
+	}
+	config := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+		NextProtos:   []string{"h2", "http/1.1"},
+	}
+	if c.cfg.ClientCAFile == "" {
+		return config, nil
+	}
+
+	pem, err := os.ReadFile(c.cfg.ClientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("load client CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("load client CA: %s: no certificates found", c.cfg.ClientCAFile)
+	}
+	config.ClientCAs = pool
+	config.ClientAuth = tls.RequireAndVerifyClientCert
+	return config, nil
+}
+
+// stat returns the sizes and modification times of the files.
+func (c *certificates) stat() (string, error) {
+	var b strings.Builder
+	for _, path := range []string{c.cfg.CertFile, c.cfg.KeyFile, c.cfg.ClientCAFile} {
+		if path == "" {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&b, "%s:%d:%d;", path, info.Size(), info.ModTime().UnixNano())
+	}
+	return b.String(), nil
+}
+
+// notAfter returns the expiry of the current certificate.
+func (c *certificates) notAfter() time.Time {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if leaf := c.current.Certificates[0].Leaf; leaf != nil {
+		return leaf.NotAfter
+	}
+	return time.Time{}
+}
+
+// redirectHandler redirects requests to HTTPS on the port of listenAddr.
+// /healthz is answered directly, so liveness probes work without a client
+// certificate.
+func redirectHandler(listenAddr string) http.Handler {
+	_, port, _ := net.SplitHostPort(listenAddr)
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /healthz", handlers.Healthz())
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		target := net.JoinHostPort(host, port)
+		if port == "443" {
+			target = strings.TrimSuffix(target, ":443")
+		}
+		http.Redirect(w, r, "https://"+target+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+	return mux
+}
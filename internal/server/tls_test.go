@@ -0,0 +1,288 @@
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadCertificates(t *testing.T) {
+	t.Parallel()
+
+	logger := slog.New(slog.NewTextHandler(&strings.Builder{}, nil))
+	ca := newTestCA(t)
+	dir := t.TempDir()
+	certFile, keyFile := ca.writeCert(t, dir, "server", x509.ExtKeyUsageServerAuth)
+	_, otherKey := ca.writeCert(t, t.TempDir(), "other", x509.ExtKeyUsageServerAuth)
+	caFile := filepath.Join(dir, "ca.crt")
+	require.NoError(t, os.WriteFile(caFile, ca.pem, 0o600))
+	emptyFile := filepath.Join(dir, "empty.crt")
+	require.NoError(t, os.WriteFile(emptyFile, []byte("no certificates"), 0o600))
+
+	t.Run("Certificate", func(t *testing.T) {
+		t.Parallel()
+		certs, err := loadCertificates(TLSConfig{CertFile: certFile, KeyFile: keyFile}, logger)
+		require.NoError(t, err)
+		assert.Len(t, certs.current.Certificates, 1)
+		assert.Equal(t, tls.NoClientCert, certs.current.ClientAuth)
+		assert.False(t, certs.notAfter().IsZero())
+	})
+
+	t.Run("Client CA", func(t *testing.T) {
+		t.Parallel()
+		certs, err := loadCertificates(TLSConfig{CertFile: certFile, KeyFile: keyFile, ClientCAFile: caFile}, logger)
+		require.NoError(t, err)
+		assert.Equal(t, tls.RequireAndVerifyClientCert, certs.current.ClientAuth)
+		assert.NotNil(t, certs.current.ClientCAs)
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		t.Parallel()
+		tests := []struct {
+			name string
+			cfg  TLSConfig
+			err  string
+		}{
+			{name: "missing file", cfg: TLSConfig{CertFile: filepath.Join(dir, "missing.crt"), KeyFile: keyFile}, err: "no such file or directory"},
+			{name: "key of other certificate", cfg: TLSConfig{CertFile: certFile, KeyFile: otherKey}, err: "load certificate: tls: private key does not match public key"},
+			{name: "empty CA bundle", cfg: TLSConfig{CertFile: certFile, KeyFile: keyFile, ClientCAFile: emptyFile}, err: "load client CA: " + emptyFile + ": no certificates found"},
+		}
+		for _, tt := range tests {
+			_, err := loadCertificates(tt.cfg, logger)
+			assert.ErrorContains(t, err, tt.err, tt.name)
+		}
+	})
+}
+
+func TestCertificates_Watch(t *testing.T) {
+	t.Parallel()
+
+	var logs strings.Builder
+	logger := slog.New(slog.NewTextHandler(&logs, nil))
+	ca := newTestCA(t)
+	dir := t.TempDir()
+	certFile, keyFile := ca.writeCert(t, dir, "old.example.com", x509.ExtKeyUsageServerAuth)
+
+	certs, err := loadCertificates(TLSConfig{CertFile: certFile, KeyFile: keyFile}, logger)
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go certs.watch(ctx, 10*time.Millisecond)
+
+	srv := newTLSServer(t, certs)
+	client := ca.client(nil)
+	assert.Equal(t, "old.example.com", servedName(t, client, srv.URL))
+
+	// a broken key keeps the old certificate in use
+	require.NoError(t, os.WriteFile(keyFile, []byte("broken"), 0o600))
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, "old.example.com", servedName(t, client, srv.URL))
+
+	ca.writeCert(t, dir, "renewed.example.com", x509.ExtKeyUsageServerAuth)
+	assert.Eventually(t, func() bool {
+		client.CloseIdleConnections()
+		return servedName(t, client, srv.URL) == "renewed.example.com"
+	}, 2*time.Second, 10*time.Millisecond)
+	cancel()
+
+	assert.Contains(t, logs.String(), "reload TLS certificate")
+	assert.Contains(t, logs.String(), "reloaded TLS certificate")
+}
+
+func TestCertificates_ClientCA(t *testing.T) {
+	t.Parallel()
+
+	logger := slog.New(slog.NewTextHandler(&strings.Builder{}, nil))
+	ca := newTestCA(t)
+	dir := t.TempDir()
+	certFile, keyFile := ca.writeCert(t, dir, "server", x509.ExtKeyUsageServerAuth)
+	caFile := filepath.Join(dir, "ca.crt")
+	require.NoError(t, os.WriteFile(caFile, ca.pem, 0o600))
+
+	certs, err := loadCertificates(TLSConfig{CertFile: certFile, KeyFile: keyFile, ClientCAFile: caFile}, logger)
+	require.NoError(t, err)
+	srv := newTLSServer(t, certs)
+
+	t.Run("Without client certificate", func(t *testing.T) {
+		t.Parallel()
+		resp, err := ca.client(nil).Get(srv.URL)
+		if err == nil {
+			_ = resp.Body.Close()
+		}
+		assert.Error(t, err)
+	})
+
+	t.Run("Client certificate of other CA", func(t *testing.T) {
+		t.Parallel()
+		other := newTestCA(t)
+		clientCert := other.issue(t, "mallory", x509.ExtKeyUsageClientAuth)
+		resp, err := ca.client(&clientCert).Get(srv.URL)
+		if err == nil {
+			_ = resp.Body.Close()
+		}
+		assert.Error(t, err)
+	})
+
+	t.Run("Client certificate", func(t *testing.T) {
+		t.Parallel()
+		clientCert := ca.issue(t, "alice", x509.ExtKeyUsageClientAuth)
+		resp, err := ca.client(&clientCert).Get(srv.URL)
+		require.NoError(t, err)
+		defer resp.Body.Close() // nolint:errcheck
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+}
+
+func TestRedirectHandler(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		listenAddr string
+		target     string
+		location   string
+	}{
+		{listenAddr: ":8443", target: "http://nas/runs?id=1", location: "https://nas:8443/runs?id=1"},
+		{listenAddr: ":443", target: "http://nas:80/", location: "https://nas/"},
+		{listenAddr: ":443", target: "http://[::1]:8080/a%20b", location: "https://[::1]/a%20b"},
+		{listenAddr: "127.0.0.1:8443", target: "http://127.0.0.1/", location: "https://127.0.0.1:8443/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			t.Parallel()
+			req := httptest.NewRequest(http.MethodPost, tt.target, nil)
+			rec := httptest.NewRecorder()
+			redirectHandler(tt.listenAddr).ServeHTTP(rec, req)
+
+			assert.Equal(t, http.StatusPermanentRedirect, rec.Code)
+			assert.Equal(t, tt.location, rec.Header().Get("Location"))
+		})
+	}
+
+	t.Run("Health check", func(t *testing.T) {
+		t.Parallel()
+		req := httptest.NewRequest(http.MethodGet, "http://nas/healthz", nil)
+		rec := httptest.NewRecorder()
+		redirectHandler(":443").ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+}
+
+// testCA issues certificates for tests.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue returns a certificate for name, also valid for localhost.
+func (ca testCA) issue(t *testing.T, name string, usage x509.ExtKeyUsage) tls.Certificate {
+	t.Helper()
+	certPEM, keyPEM := ca.issuePEM(t, name, usage)
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	require.NoError(t, err)
+	return cert
+}
+
+func (ca testCA) issuePEM(t *testing.T, name string, usage x509.ExtKeyUsage) (certPEM, keyPEM []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name, "localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+// writeCert writes a certificate for name and its key to tls.crt and
+// tls.key in dir.
+func (ca testCA) writeCert(t *testing.T, dir, name string, usage x509.ExtKeyUsage) (certFile, keyFile string) {
+	t.Helper()
+	certPEM, keyPEM := ca.issuePEM(t, name, usage)
+	certFile, keyFile = filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	require.NoError(t, os.WriteFile(certFile, certPEM, 0o600))
+	require.NoError(t, os.WriteFile(keyFile, keyPEM, 0o600))
+	return certFile, keyFile
+}
+
+// client returns an HTTPS client trusting ca, presenting cert if not nil.
+func (ca testCA) client(cert *tls.Certificate) *http.Client {
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	config := &tls.Config{RootCAs: pool, ServerName: "localhost"}
+	if cert != nil {
+		config.Certificates = []tls.Certificate{*cert}
+	}
+	return &http.Client{Transport: &http.Transport{TLSClientConfig: config}}
+}
+
+// newTLSServer starts an HTTPS server with the configuration of certs.
+func newTLSServer(t *testing.T, certs *certificates) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	srv.TLS = certs.config()
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// servedName returns the common name of the certificate served at url.
+func servedName(t *testing.T, client *http.Client, url string) string {
+	t.Helper()
+	resp, err := client.Get(url)
+	require.NoError(t, err)
+	defer resp.Body.Close() // nolint:errcheck
+	return resp.TLS.PeerCertificates[0].Subject.CommonName
+}
@@ -1,51 +1,209 @@
-function attachFilter({ inputId, rowSelector, getText }) {
-  const input = document.getElementById(inputId);
-  if (!input) return;
-
-  const wrapper = input.closest(".input-group.filter");
-  const clearBtn = wrapper?.querySelector(".clear-filter-btn");
-
-  const filterRows = () => {
-    const needle = input.value.trim().toLowerCase();
-    document.querySelectorAll(rowSelector).forEach((row) => {
-      const txt = getText(row).trim().toLowerCase();
-      row.style.display = !needle || txt.includes(needle) ? "" : "none";
+const SVG_NS = "http://www.w3.org/2000/svg";
+
+// svgEl creates an SVG element with the given attributes.
+function svgEl(name, attrs) {
+  const el = document.createElementNS(SVG_NS, name);
+  Object.entries(attrs).forEach(([k, v]) => el.setAttribute(k, v));
+  return el;
+}
+
+// formatSeconds renders a duration in seconds as e.g. "1h2m" or "42s".
+function formatSeconds(sec) {
+  sec = Math.round(sec);
+  const h = Math.floor(sec / 3600);
+  const m = Math.floor((sec % 3600) / 60);
+  const s = sec % 60;
+  if (h) return `${h}h${m}m`;
+  if (m) return `${m}m${s}s`;
+  return `${s}s`;
+}
+
+// renderLineChart draws one polyline per series into container.
+// series: [{ name, color, values }], all values aligned with labels.
+function renderLineChart(container, labels, series, formatValue) {
+  container.innerHTML = "";
+  if (!labels.length) {
+    container.innerHTML = "<p class='text-muted'>No runs yet.</p>";
+    return;
+  }
+
+  const width = 900;
+  const height = 260;
+  const pad = { top: 10, right: 10, bottom: 30, left: 60 };
+  const plotW = width - pad.left - pad.right;
+  const plotH = height - pad.top - pad.bottom;
+  const max = Math.max(1, ...series.flatMap((s) => s.values));
+
+  // a single point is centered, otherwise points span the full width
+  const stepX = labels.length > 1 ? plotW / (labels.length - 1) : 0;
+  const offsetX = labels.length > 1 ? 0 : plotW / 2;
+  const x = (i) => pad.left + offsetX + i * stepX;
+  const y = (v) => pad.top + plotH - (v / max) * plotH;
+
+  const svg = svgEl("svg", { viewBox: `0 0 ${width} ${height}` });
+
+  // horizontal grid lines with value labels
+  for (let i = 0; i <= 4; i++) {
+    const v = (max * i) / 4;
+    const line = svgEl("line", {
+      class: "axis",
+      x1: pad.left,
+      x2: width - pad.right,
+      y1: y(v),
+      y2: y(v),
     });
-  };
+    svg.appendChild(line);
+    const t = svgEl("text", {
+      x: pad.left - 6,
+      y: y(v) + 4,
+      "text-anchor": "end",
+    });
+    t.textContent = formatValue(v);
+    svg.appendChild(t);
+  }
 
-  input.addEventListener("input", () => {
-    if (clearBtn) clearBtn.style.display = input.value ? "block" : "none";
-    filterRows();
+  // at most ~8 date labels on the x axis
+  const step = Math.max(1, Math.ceil(labels.length / 8));
+  labels.forEach((label, i) => {
+    if (i % step !== 0) return;
+    const t = svgEl("text", {
+      x: x(i),
+      y: height - 10,
+      "text-anchor": "middle",
+    });
+    t.textContent = label;
+    svg.appendChild(t);
   });
 
-  if (clearBtn) {
-    clearBtn.addEventListener("click", () => {
-      input.value = "";
-      clearBtn.style.display = "none";
-      input.focus();
-      document.querySelectorAll(rowSelector).forEach((row) => {
-        row.style.display = "";
-      });
+  series.forEach((s) => {
+    const points = s.values.map((v, i) => `${x(i)},${y(v)}`).join(" ");
+    const line = svgEl("polyline", {
+      points,
+      fill: "none",
+      stroke: s.color,
+      "stroke-width": 2,
     });
+    svg.appendChild(line);
+    s.values.forEach((v, i) => {
+      const dot = svgEl("circle", { cx: x(i), cy: y(v), r: 3, fill: s.color });
+      const title = svgEl("title", {});
+      title.textContent = `${labels[i]} ${s.name}: ${formatValue(v)}`;
+      dot.appendChild(title);
+      svg.appendChild(dot);
+    });
+  });
 
-    clearBtn.style.display = input.value ? "block" : "none";
-  }
+  const legend = document.createElement("div");
+  legend.className = "trend-legend";
+  series.forEach((s) => {
+    const item = document.createElement("span");
+    item.innerHTML = `<span style="color:${s.color}">&#9632;</span>`;
+    item.appendChild(document.createTextNode(s.name));
+    legend.appendChild(item);
+  });
+
+  container.appendChild(svg);
+  container.appendChild(legend);
+}
+
+// loadTrends fetches the aggregated run history and renders the trend charts.
+async function loadTrends(bucket) {
+  const url = `/api/v1/trends?bucket=${encodeURIComponent(bucket)}`;
+  const res = await fetch(withSource(url));
+  if (!res.ok) throw new Error(`trends request failed: ${res.status}`);
+  const data = await res.json();
+
+  const labels = data.points.map((p) => p.start.slice(0, 10));
+  const series = (obj) => (name, color, key) => ({
+    name,
+    color,
+    values: data.points.map((p) => p[obj][key]),
+  });
+  const duration = series("durations");
+  const changes = series("changes");
+
+  renderLineChart(
+    document.getElementById("durationChart"),
+    labels,
+    [
+      duration("Touch", "#6f42c1", "touch_seconds"),
+      duration("Diff", "#0d6efd", "diff_seconds"),
+      duration("Sync", "#198754", "sync_seconds"),
+      duration("Scrub", "#fd7e14", "scrub_seconds"),
+      duration("Smart", "#20c997", "smart_seconds"),
+      duration("Total", "#f8f9fa", "total_seconds"),
+    ],
+    formatSeconds,
+  );
+
+  renderLineChart(
+    document.getElementById("changesChart"),
+    labels,
+    [
+      changes("Added", "#198754", "added"),
+      changes("Removed", "#dc3545", "removed"),
+      changes("Updated", "#0d6efd", "updated"),
+      changes("Moved", "#ffc107", "moved"),
+      changes("Copied", "#6f42c1", "copied"),
+      changes("Restored", "#20c997", "restored"),
+    ],
+    (v) => String(Math.round(v)),
+  );
+}
+
+// currentSource returns the source selected in the navbar, empty if the
+// server has a single source.
+function currentSource() {
+  const selector = document.getElementById("sourceSelector");
+  return selector ? selector.value : "";
+}
+
+// withSource adds the selected source to a request URL.
+function withSource(url) {
+  const source = currentSource();
+  if (!source) return url;
+  const sep = url.includes("?") ? "&" : "?";
+  return `${url}${sep}source=${encodeURIComponent(source)}`;
+}
+
+// selectSource switches to the given source, remembers it and follows its
+// live updates.
+function selectSource(source) {
+  const selector = document.getElementById("sourceSelector");
+  if (!selector) return;
+  selector.value = source;
+  localStorage.setItem("source", source);
+  subscribeRunEvents();
+}
+
+// currentQuery returns the query string of the current hash route, e.g.
+// "status=failed" for "#/overview?status=failed".
+function currentQuery() {
+  const hash = window.location.hash.slice(1);
+  const idx = hash.indexOf("?");
+  return idx === -1 ? "" : hash.slice(idx + 1);
 }
 
 async function loadSection(sec) {
   try {
+    if (sec !== "actions" && actionOutput) actionOutput.close();
+
     let url = `/partials/${sec}`;
 
+    if (["overview", "compare", "trends", "search", "file"].includes(sec)) {
+      const query = currentQuery();
+      if (query) url += `?${query}`;
+    }
+
     if (sec === "run") {
-      const hash = window.location.hash.slice(1);
-      const parts = hash.split("/");
-      if (parts.length >= 3 && parts[1] === "run") {
-        const rawId = decodeURIComponent(parts.slice(2).join("/"));
-        url += `?id=${encodeURIComponent(rawId)}`;
-      }
+      const params = new URLSearchParams(currentQuery());
+      const rawId = currentRunId();
+      if (rawId) params.set("id", rawId);
+      const query = params.toString();
+      if (query) url += `?${query}`;
     }
 
-    const res = await fetch(url);
+    const res = await fetch(withSource(url));
     if (!res.ok) {
       document.getElementById("content").innerHTML =
         `<p class='text-danger'>Error ${res.status} loading ${sec}.</p>`;
@@ -65,6 +223,11 @@ async function loadSection(sec) {
     });
 
     if (sec === "overview") {
+      const showOverview = (query) => {
+        window.location.hash = query ? `/overview?${query}` : "/overview";
+        loadSection("overview");
+      };
+
       document.querySelectorAll("#overview tbody tr").forEach((row) => {
         const dateCell = row.querySelector("td[data-timestamp]");
         if (dateCell) {
@@ -76,14 +239,171 @@ async function loadSection(sec) {
         }
       });
 
-      attachFilter({
-        inputId: "searchOverview",
-        rowSelector: "#overview tbody tr",
-        getText: (row) => row.querySelector("td:nth-child(1)").textContent,
+      // sort headers and page links carry the query of their target page
+      document
+        .querySelectorAll("#overview th[data-query], .pagination a[data-query]")
+        .forEach((el) => {
+          el.addEventListener("click", (e) => {
+            e.preventDefault();
+            if (el.closest(".disabled")) return;
+            showOverview(el.dataset.query);
+          });
+        });
+
+      const form = document.getElementById("overviewFilter");
+      if (form) {
+        form.addEventListener("change", () => {
+          const params = new URLSearchParams(currentQuery());
+          new FormData(form).forEach((value, key) => {
+            if (value) {
+              params.set(key, value);
+            } else {
+              params.delete(key);
+            }
+          });
+          params.delete("page");
+          showOverview(params.toString());
+        });
+      }
+    }
+
+    if (sec === "sources") {
+      document.querySelectorAll("#sources tr[data-source]").forEach((row) => {
+        row.style.cursor = "pointer";
+        row.addEventListener("click", () => {
+          selectSource(row.dataset.source);
+          const cell = row.querySelector("td[data-timestamp]");
+          if (cell) {
+            goToRun(cell.dataset.timestamp);
+          } else {
+            window.location.hash = "/overview";
+            loadSection("overview");
+          }
+        });
       });
+    }
+
+    if (sec === "schedule") {
+      // run files belong to the source of their job
+      document.querySelectorAll("#schedule a[data-run-id]").forEach((link) => {
+        link.addEventListener("click", (e) => {
+          e.preventDefault();
+          selectSource(link.closest("[data-source]").dataset.source);
+          goToRun(link.dataset.runId);
+        });
+      });
+
+      document
+        .querySelectorAll("#schedule button[data-job-action]")
+        .forEach((btn) => {
+          btn.addEventListener("click", async () => {
+            const job = encodeURIComponent(btn.closest("tr").dataset.job);
+            const action = btn.dataset.jobAction;
+            btn.disabled = true;
+            const res = await fetch(`/api/v1/schedule/${job}/${action}`, {
+              method: "POST",
+            });
+            if (!res.ok) console.error(`${action} ${job}: ${res.status}`);
+            loadSection("schedule");
+          });
+        });
+    }
+
+    if (sec === "actions") {
+      // run files belong to the source of their action
+      document.querySelectorAll("#actions a[data-run-id]").forEach((link) => {
+        link.addEventListener("click", (e) => {
+          e.preventDefault();
+          selectSource(link.closest("[data-source]").dataset.source);
+          goToRun(link.dataset.runId);
+        });
+      });
+
+      document
+        .querySelectorAll("#actions button[data-action-run]")
+        .forEach((btn) => {
+          btn.addEventListener("click", async () => {
+            const name = btn.closest("tr").dataset.action;
+            btn.disabled = true;
+            const res = await fetch(
+              `/api/v1/actions/${encodeURIComponent(name)}/run`,
+              { method: "POST" },
+            );
+            watchedAction = { name, finished: false };
+            if (!res.ok) {
+              const body = await res.json().catch(() => ({}));
+              watchedAction.error = body.error || `Error ${res.status}.`;
+            }
+            loadSection("actions");
+          });
+        });
+
+      document
+        .querySelectorAll("#actions button[data-action-output]")
+        .forEach((btn) => {
+          btn.addEventListener("click", () => {
+            const name = btn.closest("tr").dataset.action;
+            watchedAction = { name, finished: false };
+            showActionOutput();
+          });
+        });
+
+      showActionOutput();
+    }
+
+    if (sec === "compare") {
+      const form = document.getElementById("compareForm");
+      if (form) {
+        form.addEventListener("change", () => {
+          const params = new URLSearchParams(new FormData(form));
+          window.location.hash = `/compare?${params.toString()}`;
+          loadSection("compare");
+        });
+      }
+    }
+
+    if (sec === "trends") {
+      const bucketSelect = document.getElementById("trendBucket");
+      if (bucketSelect) {
+        bucketSelect.addEventListener("change", (e) => {
+          const bucket = encodeURIComponent(e.target.value);
+          window.location.hash = `/trends?bucket=${bucket}`;
+          loadSection("trends");
+        });
+      }
+      const trends = document.getElementById("trends");
+      if (trends) await loadTrends(trends.dataset.bucket);
+    }
 
-      const table = document.querySelector("#overview table");
-      if (table && window.Tablesort) new Tablesort(table);
+    if (sec === "file") {
+      document
+        .querySelectorAll("#fileTimeline td[data-timestamp]")
+        .forEach((cell) => {
+          cell.style.cursor = "pointer";
+          cell.addEventListener("click", () => goToRun(cell.dataset.timestamp));
+        });
+    }
+
+    bindFileLinks(document.getElementById("content"));
+
+    if (sec === "search") {
+      const form = document.getElementById("searchForm");
+      if (form) {
+        form.addEventListener("submit", (e) => {
+          e.preventDefault();
+          const params = new URLSearchParams(new FormData(form));
+          window.location.hash = `/search?${params.toString()}`;
+          loadSection("search");
+        });
+        form.querySelector("input[name=q]").focus();
+      }
+
+      document
+        .querySelectorAll("#searchResults td[data-timestamp]")
+        .forEach((cell) => {
+          cell.style.cursor = "pointer";
+          cell.addEventListener("click", () => goToRun(cell.dataset.timestamp));
+        });
     }
 
     if (sec === "run") {
@@ -91,9 +411,58 @@ async function loadSection(sec) {
       if (selector) {
         selector.addEventListener("change", (e) => {
           const newId = e.target.value;
-          goToRun(newId);
+          goToRun(newId, currentQuery());
         });
       }
+
+      const runView = document.getElementById("runView");
+      if (runView) {
+        const runId = runView.dataset.runId;
+        runView.querySelectorAll("[data-run-view]").forEach((btn) => {
+          btn.addEventListener("click", () => {
+            const view = btn.dataset.runView;
+            goToRun(runId, view === "tree" ? "view=tree" : "");
+          });
+        });
+        document.querySelectorAll("[data-tree-path]").forEach((link) => {
+          link.addEventListener("click", (e) => {
+            e.preventDefault();
+            const params = new URLSearchParams({ view: "tree" });
+            const treePath = link.dataset.treePath;
+            if (treePath) params.set("path", treePath);
+            goToRun(runId, params.toString());
+          });
+        });
+      }
+
+      // file lists are loaded when their category is opened
+      document
+        .querySelectorAll("#runFiles details[data-category]")
+        .forEach((details) => {
+          const filter = details.querySelector(".run-files-filter");
+          if (!filter) return;
+
+          const firstPage = () => {
+            const params = new URLSearchParams({
+              id: details.dataset.runId,
+              category: details.dataset.category,
+            });
+            if (filter.value) params.set("q", filter.value);
+            return params.toString();
+          };
+
+          details.addEventListener("toggle", () => {
+            if (details.open && !details.dataset.loaded) {
+              loadRunFiles(details, firstPage());
+            }
+          });
+
+          let timer;
+          filter.addEventListener("input", () => {
+            clearTimeout(timer);
+            timer = setTimeout(() => loadRunFiles(details, firstPage()), 300);
+          });
+        });
     }
   } catch (err) {
     console.error("loadSection error:", err);
@@ -102,12 +471,197 @@ async function loadSection(sec) {
   }
 }
 
-async function goToRun(id) {
-  window.location.hash = `/run/${encodeURIComponent(id)}`;
+// bindFileLinks links every file path below root to its timeline and lets
+// copy buttons put the shell-escaped path into the clipboard.
+function bindFileLinks(root) {
+  root.querySelectorAll("[data-file-path]").forEach((el) => {
+    el.style.cursor = "pointer";
+    el.addEventListener("click", (e) => {
+      e.preventDefault();
+      goToFile(el.dataset.filePath);
+    });
+  });
+
+  root.querySelectorAll(".copy-path").forEach((btn) => {
+    btn.addEventListener("click", async (e) => {
+      e.preventDefault();
+      e.stopPropagation();
+      try {
+        await navigator.clipboard.writeText(btn.dataset.copy);
+        btn.classList.add("copied");
+        setTimeout(() => btn.classList.remove("copied"), 1000);
+      } catch (err) {
+        console.error("copy path:", err);
+      }
+    });
+  });
+}
+
+// loadRunFiles loads a page of the file list of a run category into its
+// details element. Page links load the next page in place.
+async function loadRunFiles(details, query) {
+  const target = details.querySelector(".run-files-page");
+  try {
+    const res = await fetch(withSource(`/partials/files?${query}`));
+    if (!res.ok) {
+      target.innerHTML =
+        `<p class='text-danger'>Error ${res.status} loading files.</p>`;
+      return;
+    }
+    target.innerHTML = await res.text();
+    details.dataset.loaded = "true";
+
+    bindFileLinks(target);
+    target.querySelectorAll(".pagination a[data-query]").forEach((link) => {
+      link.addEventListener("click", (e) => {
+        e.preventDefault();
+        if (link.closest(".disabled")) return;
+        loadRunFiles(details, link.dataset.query);
+      });
+    });
+  } catch (err) {
+    console.error("loadRunFiles error:", err);
+    target.innerHTML = "<p class='text-danger'>Unexpected error.</p>";
+  }
+}
+
+// watchedAction is the action whose output the Actions section shows:
+// { name, finished, error }, where error is set if it could not be started.
+let watchedAction;
+
+// actionOutput is the output stream of the watched action.
+let actionOutput;
+
+// showActionOutput streams the output of the watched action into the
+// Actions section. The server replays the lines written so far, so the
+// output survives reloads of the section. Once the command exits, the
+// section is reloaded to show its outcome.
+function showActionOutput() {
+  if (actionOutput) actionOutput.close();
+  const pane = document.getElementById("actionOutput");
+  const watched = watchedAction;
+  if (!pane || !watched) return;
+
+  pane.classList.remove("d-none");
+  pane.querySelector(".action-output-name").textContent = watched.name;
+  const pre = pane.querySelector(".action-output");
+  pre.textContent = "";
+
+  // append adds a line, following the end unless the user scrolled up
+  const append = (text, className) => {
+    const follow = pre.scrollTop + pre.clientHeight >= pre.scrollHeight - 4;
+    const span = document.createElement("span");
+    span.className = className;
+    span.textContent = `${text}\n`;
+    pre.appendChild(span);
+    if (follow) pre.scrollTop = pre.scrollHeight;
+  };
+
+  if (watched.error) {
+    append(watched.error, "action-stderr");
+    return;
+  }
+
+  const name = encodeURIComponent(watched.name);
+  const stream = new EventSource(`/api/v1/actions/${name}/output`);
+  actionOutput = stream;
+  stream.addEventListener("output", (e) => {
+    const line = JSON.parse(e.data);
+    append(line.text, `action-${line.stream}`);
+  });
+  stream.addEventListener("done", (e) => {
+    stream.close();
+    const exec = JSON.parse(e.data);
+    const reason = exec.error ? `: ${exec.error}` : "";
+    append(`Finished with ${exec.status}${reason}.`, "action-done");
+    if (!watched.finished) {
+      watched.finished = true;
+      loadSection("actions");
+    }
+  });
+}
+
+// goToRun shows the given run, optionally with a query such as "view=tree".
+async function goToRun(id, query = "") {
+  const route = `/run/${encodeURIComponent(id)}`;
+  window.location.hash = query ? `${route}?${query}` : route;
   await loadSection("run");
 }
 
+// goToFile shows the timeline of the given file path.
+async function goToFile(path) {
+  const params = new URLSearchParams({ path });
+  window.location.hash = `/file?${params.toString()}`;
+  await loadSection("file");
+}
+
+// currentSection returns the section of the current hash route.
+function currentSection() {
+  const hash = window.location.hash.slice(1);
+  return hash.split(/[/?]/)[1] || "overview";
+}
+
+// currentRunId returns the run ID of a "#/run/<id>" route, if any.
+function currentRunId() {
+  const hash = window.location.hash.slice(1).split("?")[0];
+  const parts = hash.split("/");
+  return parts.length >= 3 && parts[1] === "run"
+    ? decodeURIComponent(parts.slice(2).join("/"))
+    : "";
+}
+
+// runEvents is the event stream of the selected source.
+let runEvents;
+
+// subscribeRunEvents reloads the current section whenever runs of the
+// selected source are added, changed or removed on the server.
+function subscribeRunEvents() {
+  if (!window.EventSource) return;
+  if (runEvents) runEvents.close();
+
+  let timer;
+  runEvents = new EventSource(withSource("/events"));
+  runEvents.addEventListener("run", (e) => {
+    const ev = JSON.parse(e.data);
+    const sec = currentSection();
+    const live = [
+      "overview",
+      "run",
+      "trends",
+      "file",
+      "sources",
+      "schedule",
+      "actions",
+    ];
+    if (!live.includes(sec)) return;
+
+    // the displayed run is gone, fall back to the latest one
+    if (sec === "run" && ev.type === "removed" && ev.id === currentRunId()) {
+      window.location.hash = "/run";
+    }
+
+    // coalesce bursts, e.g. when several files are copied at once
+    clearTimeout(timer);
+    timer = setTimeout(() => loadSection(sec), 500);
+  });
+}
+
 document.addEventListener("DOMContentLoaded", () => {
+  const selector = document.getElementById("sourceSelector");
+  if (selector) {
+    const stored = localStorage.getItem("source");
+    if ([...selector.options].some((o) => o.value === stored)) {
+      selector.value = stored;
+    }
+    selector.addEventListener("change", () => {
+      selectSource(selector.value);
+      window.location.hash = "/overview";
+      loadSection("overview");
+    });
+  }
+
+  subscribeRunEvents();
+
   document.querySelectorAll("nav .nav-link").forEach((a) => {
     a.addEventListener("click", (e) => {
       e.preventDefault();
@@ -120,11 +674,27 @@ document.addEventListener("DOMContentLoaded", () => {
   });
 
   const initial = window.location.hash.slice(1);
-  if (!initial || initial === "/overview") {
+  if (!initial) {
     window.location.hash = "/overview";
     loadSection("overview");
+  } else if (initial.startsWith("/overview")) {
+    loadSection("overview");
   } else if (initial.startsWith("/run")) {
     loadSection("run");
+  } else if (initial.startsWith("/file")) {
+    loadSection("file");
+  } else if (initial.startsWith("/compare")) {
+    loadSection("compare");
+  } else if (initial.startsWith("/trends")) {
+    loadSection("trends");
+  } else if (initial.startsWith("/search")) {
+    loadSection("search");
+  } else if (initial.startsWith("/sources")) {
+    loadSection("sources");
+  } else if (initial.startsWith("/schedule")) {
+    loadSection("schedule");
+  } else if (initial.startsWith("/actions")) {
+    loadSection("actions");
   } else {
     window.location.hash = "/overview";
     loadSection("overview");